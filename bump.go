@@ -0,0 +1,86 @@
+package release
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Bump is the kind of semantic version increment a set of changes calls for
+type Bump int
+
+const (
+	// BumpNone means no conventional commits were found
+	BumpNone Bump = iota
+	// BumpPatch is a bug fix (fix:)
+	BumpPatch
+	// BumpMinor is a new feature (feat:)
+	BumpMinor
+	// BumpMajor is a breaking change (BREAKING CHANGE or feat!:)
+	BumpMajor
+)
+
+func (b Bump) String() string {
+	switch b {
+	case BumpPatch:
+		return "patch"
+	case BumpMinor:
+		return "minor"
+	case BumpMajor:
+		return "major"
+	}
+	return "none"
+}
+
+// ParseBump parses one of "patch", "minor" or "major" into a Bump
+func ParseBump(name string) (Bump, error) {
+	for _, bump := range []Bump{BumpPatch, BumpMinor, BumpMajor} {
+		if bump.String() == name {
+			return bump, nil
+		}
+	}
+	return BumpNone, fmt.Errorf("unknown bump '%s', must be one of patch, minor or major", name)
+}
+
+// patConventional matches the header of a conventional commit, for example
+// "feat(api)!: drop the v1 endpoints"
+var patConventional = regexp.MustCompile(`^(?P<type>[a-zA-Z]+)(?:\([^)]*\))?(?P<breaking>!)?: `)
+
+// ConventionalBump returns the bump a single commit message calls for
+// according to the conventional commits spec, BumpNone is returned for
+// messages that aren't conventional commits (or types like docs: and chore:)
+func ConventionalBump(msg string) Bump {
+	results := patConventional.FindStringSubmatch(msg)
+	if results == nil {
+		return BumpNone
+	}
+	if results[2] == "!" || strings.Contains(msg, "\nBREAKING CHANGE:") || strings.Contains(msg, "\nBREAKING-CHANGE:") {
+		return BumpMajor
+	}
+	switch strings.ToLower(results[1]) {
+	case "feat":
+		return BumpMinor
+	case "fix":
+		return BumpPatch
+	}
+	return BumpNone
+}
+
+// AutoBump analyzes every commit between fromTag and toRef and returns the
+// largest bump any of them call for, along with a description of the commit
+// that caused it. BumpNone is returned if no conventional commits were found
+func (r *Manager) AutoBump(fromTag, toRef string) (Bump, string, error) {
+	commits, err := r.commitsBetween(fromTag, toRef, false, false)
+	if err != nil {
+		return BumpNone, "", err
+	}
+	bump := BumpNone
+	reason := ""
+	for _, c := range commits {
+		if commitBump := ConventionalBump(c.Message); commitBump > bump {
+			bump = commitBump
+			reason = fmt.Sprintf("commit %s \"%s\"", c.Hash.String()[:7], commitSubject(c.Message))
+		}
+	}
+	return bump, reason, nil
+}
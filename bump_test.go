@@ -0,0 +1,77 @@
+package release
+
+import "testing"
+
+func TestConventionalBump(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want Bump
+	}{
+		{msg: "fix: handle empty tags", want: BumpPatch},
+		{msg: "fix(api): handle empty tags", want: BumpPatch},
+		{msg: "feat: add --select", want: BumpMinor},
+		{msg: "Feat(cli): add --select", want: BumpMinor},
+		{msg: "feat!: drop --fmt", want: BumpMajor},
+		{msg: "refactor(api)!: rename the endpoints", want: BumpMajor},
+		{msg: "fix: rename a flag\n\nBREAKING CHANGE: --old is gone", want: BumpMajor},
+		{msg: "feat: rename a flag\n\nBREAKING-CHANGE: --old is gone", want: BumpMajor},
+		{msg: "docs: explain --select", want: BumpNone},
+		{msg: "chore(deps): update go-git", want: BumpNone},
+		{msg: "Fix the build", want: BumpNone},
+		{msg: "feat:missing space", want: BumpNone},
+		{msg: "Updated version number to 1.2.3-1", want: BumpNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			if got := ConventionalBump(tt.msg); got != tt.want {
+				t.Errorf("ConventionalBump(%q) = %s, want %s", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoBump(t *testing.T) {
+	tests := []struct {
+		name    string
+		commits []string
+		want    Bump
+	}{
+		{name: "nothing conventional", commits: []string{"Fix the build", "docs: typo"}, want: BumpNone},
+		{name: "fixes", commits: []string{"fix: one", "fix: two"}, want: BumpPatch},
+		{name: "feature wins over fixes", commits: []string{"fix: one", "feat: two", "fix: three"}, want: BumpMinor},
+		{name: "breaking wins", commits: []string{"feat: one", "fix!: two", "chore: three"}, want: BumpMajor},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			// Commits from before the release don't count
+			commitFile(t, repo, "main.txt", "feat!: already released")
+			tagHead(t, repo, "1.0.0-1", "")
+			for _, msg := range tt.commits {
+				commitFile(t, repo, "main.txt", msg)
+			}
+			rm := newTestManager(t, dir)
+			got, reason, err := rm.AutoBump("1.0.0-1", "HEAD")
+			if err != nil {
+				t.Fatalf("AutoBump() error = %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("AutoBump() = %s (%s), want %s", got, reason, tt.want)
+			}
+			if (reason == "") != (tt.want == BumpNone) {
+				t.Errorf("AutoBump() reason = %q", reason)
+			}
+		})
+	}
+}
+
+func TestParseBump(t *testing.T) {
+	for _, bump := range []Bump{BumpPatch, BumpMinor, BumpMajor} {
+		if got, err := ParseBump(bump.String()); err != nil || got != bump {
+			t.Errorf("ParseBump(%q) = %s, %v", bump.String(), got, err)
+		}
+	}
+	if _, err := ParseBump("none"); err == nil {
+		t.Error("ParseBump(\"none\") succeeded")
+	}
+}
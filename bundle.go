@@ -0,0 +1,44 @@
+package release
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// BundleTags writes a git bundle containing the given tags and every object
+// they need, so releases created on a disconnected machine can be moved
+// elsewhere and fetched or pushed from there. go-git can't write bundles so
+// this uses the git command, the bundle is verified after it is written.
+func (r *Manager) BundleTags(path string, tags []string) error {
+	if len(tags) == 0 {
+		return fmt.Errorf("no tags to bundle")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.cwd, path)
+	}
+	args := []string{"bundle", "create", path}
+	for _, tag := range tags {
+		args = append(args, fmt.Sprintf("refs/tags/%s", tag))
+	}
+	if _, err := r.runGit(args...); err != nil {
+		return fmt.Errorf("failed to create bundle %s: %w", path, err)
+	}
+	if _, err := r.runGit("bundle", "verify", path); err != nil {
+		return fmt.Errorf("bundle %s failed verification: %w", path, err)
+	}
+	return nil
+}
+
+// runGit runs a git command in the repository and returns its output, the
+// output is included in the error if it fails
+func (r *Manager) runGit(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.repoDir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
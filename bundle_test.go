@@ -0,0 +1,96 @@
+package release
+
+import (
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+func TestBundleTags(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("bundles are written with the git command")
+	}
+	dir, repo := newTestRepo(t)
+	tagHead(t, repo, "2024.06.001", "")
+	commitFile(t, repo, "file.txt", "Fix the build")
+	tagHead(t, repo, "2024.06.002-api", "Release 2024.06.002-api")
+	tagHead(t, repo, "unrelated", "")
+
+	rm := newTestManager(t, dir)
+	path := filepath.Join(t.TempDir(), "releases.bundle")
+	if err := rm.BundleTags(path, []string{"2024.06.001", "2024.06.002-api"}); err != nil {
+		t.Fatalf("BundleTags() error = %s", err)
+	}
+
+	heads, err := exec.Command("git", "bundle", "list-heads", path).Output()
+	if err != nil {
+		t.Fatalf("failed to list the heads of the bundle: %s", err)
+	}
+	refs := []string{}
+	for _, line := range strings.Split(strings.TrimSpace(string(heads)), "\n") {
+		refs = append(refs, strings.Fields(line)[1])
+	}
+	sort.Strings(refs)
+	if want := "refs/tags/2024.06.001 refs/tags/2024.06.002-api"; strings.Join(refs, " ") != want {
+		t.Errorf("bundle heads = %q, want %s", refs, want)
+	}
+
+	// Everything the tags need is in the bundle, a repository without the
+	// history can fetch them
+	other, err := git.PlainInit(t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := other.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetch := exec.Command("git", "fetch", path, "refs/tags/*:refs/tags/*")
+	fetch.Dir = w.Filesystem.Root()
+	if output, err := fetch.CombinedOutput(); err != nil {
+		t.Fatalf("failed to fetch from the bundle: %s\n%s", err, output)
+	}
+	for _, tag := range []string{"2024.06.001", "2024.06.002-api"} {
+		local, err := repo.Tag(tag)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fetched, err := other.Tag(tag)
+		if err != nil {
+			t.Errorf("%s wasn't fetched from the bundle: %s", tag, err)
+			continue
+		}
+		if fetched.Hash() != local.Hash() {
+			t.Errorf("fetched %s points at %s, want %s", tag, fetched.Hash(), local.Hash())
+		}
+	}
+}
+
+func TestBundleTagsErrors(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("bundles are written with the git command")
+	}
+	dir, _ := newTestRepo(t)
+	rm := newTestManager(t, dir)
+	path := filepath.Join(t.TempDir(), "releases.bundle")
+	tests := []struct {
+		name    string
+		tags    []string
+		wantErr string
+	}{
+		{name: "no tags", wantErr: "no tags to bundle"},
+		{name: "missing tag", tags: []string{"2024.06.001"}, wantErr: "failed to create bundle"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := rm.BundleTags(path, tt.tags)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("BundleTags() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package release
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// resolveCommit resolves a revision (tag, branch, hash, HEAD, etc) to the
+// commit it points at, annotated tags are peeled to their target commit
+func (r *Manager) resolveCommit(rev string) (*object.Commit, error) {
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve %s: %w", rev, err)
+	}
+	return r.repo.CommitObject(*hash)
+}
+
+// ResolveCommit returns the hash of the commit a revision (tag, branch, hash,
+// HEAD, etc) points at
+func (r *Manager) ResolveCommit(rev string) (string, error) {
+	c, err := r.resolveCommit(rev)
+	if err != nil {
+		return "", err
+	}
+	return c.Hash.String(), nil
+}
+
+// CheckRange makes sure fromTag..toRef is a usable range, both must resolve
+// and fromTag must be an ancestor of toRef (otherwise the range would include
+// unrelated history or be reversed)
+func (r *Manager) CheckRange(fromTag, toRef string) error {
+	to, err := r.resolveCommit(toRef)
+	if err != nil {
+		return err
+	}
+	if fromTag == "" {
+		return nil
+	}
+	from, err := r.resolveCommit(fromTag)
+	if err != nil {
+		return err
+	}
+	ancestor, err := from.IsAncestor(to)
+	if err != nil {
+		return err
+	}
+	if !ancestor {
+		if reversed, _ := to.IsAncestor(from); reversed {
+			return fmt.Errorf("invalid range, %s is after %s", fromTag, toRef)
+		}
+		return fmt.Errorf("invalid range, %s is not reachable from %s", fromTag, toRef)
+	}
+	return nil
+}
+
+// commitsBetween returns the commits reachable from toRef that are not
+// reachable from fromTag, newest first. If fromTag is empty every commit
+// reachable from toRef is returned. If firstParent is set only the first
+// parent of merges is followed and if mergesOnly is set only merge commits are
+// returned.
+func (r *Manager) commitsBetween(fromTag, toRef string, firstParent, mergesOnly bool) ([]*object.Commit, error) {
+	to, err := r.resolveCommit(toRef)
+	if err != nil {
+		return nil, err
+	}
+
+	// Everything reachable from the previous release has already been
+	// released, so mark it as seen and the walk below will stop there.
+	seen := map[plumbing.Hash]bool{}
+	if fromTag != "" {
+		from, err := r.resolveCommit(fromTag)
+		if err != nil {
+			return nil, err
+		}
+		err = object.NewCommitPreorderIter(from, nil, nil).ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	commits := []*object.Commit{}
+	include := func(c *object.Commit) {
+		if mergesOnly && c.NumParents() < 2 {
+			return
+		}
+		commits = append(commits, c)
+	}
+
+	if firstParent {
+		// Only follow the mainline, for merge based workflows this is one
+		// commit per merged branch/PR
+		for c := to; !seen[c.Hash]; {
+			include(c)
+			if c.NumParents() == 0 {
+				break
+			}
+			if c, err = c.Parent(0); err != nil {
+				return nil, err
+			}
+		}
+		return commits, nil
+	}
+
+	err = object.NewCommitPreorderIter(to, seen, nil).ForEach(func(c *object.Commit) error {
+		include(c)
+		return nil
+	})
+	return commits, err
+}
+
+// CountCommits returns how many commits are reachable from toRef but not from
+// fromTag (every commit reachable from toRef if fromTag is empty)
+func (r *Manager) CountCommits(fromTag, toRef string) (int, error) {
+	commits, err := r.commitsBetween(fromTag, toRef, false, false)
+	if err != nil {
+		return 0, err
+	}
+	return len(commits), nil
+}
+
+// CommitAsOf returns the hash of the latest commit on rev's first parent
+// history (the branch itself, not what was merged into it) committed at or
+// before asOf, i.e. what the branch pointed at back then
+func (r *Manager) CommitAsOf(rev string, asOf time.Time) (string, error) {
+	c, err := r.resolveCommit(rev)
+	if err != nil {
+		return "", err
+	}
+	for {
+		if !c.Committer.When.After(asOf) {
+			return c.Hash.String(), nil
+		}
+		if c.NumParents() == 0 {
+			return "", fmt.Errorf("no commit on %s at or before %s", rev, asOf.Format(time.RFC3339))
+		}
+		if c, err = c.Parent(0); err != nil {
+			return "", err
+		}
+	}
+}
+
+// commitSubject returns the first line of a commit message
+func commitSubject(msg string) string {
+	return strings.TrimSpace(strings.SplitN(msg, "\n", 2)[0])
+}
+
+// changelogCommits returns the commits that belong in the changelog between
+// fromTag and toRef, applying the Changelog settings on the Manager and
+// leaving out release commits (see ReleaseCommitPrefix) and, with
+// ChangelogPaths, commits that didn't touch those paths
+func (r *Manager) changelogCommits(fromTag, toRef string) ([]*object.Commit, error) {
+	commits, err := r.commitsBetween(fromTag, toRef, r.ChangelogFirstParent, r.ChangelogMergesOnly)
+	if err != nil {
+		return nil, err
+	}
+	filtered := []*object.Commit{}
+	for _, c := range commits {
+		// Commits made by the release itself aren't changes worth noting and
+		// would otherwise show up in the next release's changelog
+		if strings.HasPrefix(c.Message, ReleaseCommitPrefix) {
+			continue
+		}
+		if r.ChangelogPaths != nil {
+			touched, err := commitTouches(c, r.ChangelogPaths)
+			if err != nil {
+				return nil, err
+			}
+			if !touched {
+				continue
+			}
+		}
+		filtered = append(filtered, c)
+	}
+	return filtered, nil
+}
+
+// Changelog returns the subject lines of every commit between fromTag and
+// toRef, newest first. If fromTag is empty (there is no previous release) all
+// commits reachable from toRef are included. Release commits (see
+// ReleaseCommitPrefix) are left out.
+func (r *Manager) Changelog(fromTag, toRef string) ([]string, error) {
+	commits, err := r.changelogCommits(fromTag, toRef)
+	if err != nil {
+		return nil, err
+	}
+	subjects := []string{}
+	for _, c := range commits {
+		subjects = append(subjects, commitSubject(c.Message))
+	}
+	return subjects, nil
+}
+
+// ReleaseNotes builds curated notes from the given trailer (e.g.
+// Release-Note) of every commit between fromTag and toRef. The subjects of
+// commits without the trailer are returned in other.
+func (r *Manager) ReleaseNotes(fromTag, toRef, trailer string) (notes []string, other []string, err error) {
+	commits, err := r.changelogCommits(fromTag, toRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	notes, other = []string{}, []string{}
+	for _, c := range commits {
+		values := []string{}
+		// Trailer keys are case insensitive, like git treats them
+		for key, keyValues := range ParseTrailers(c.Message) {
+			if strings.EqualFold(key, trailer) {
+				values = append(values, keyValues...)
+			}
+		}
+		if len(values) == 0 {
+			other = append(other, commitSubject(c.Message))
+		}
+		notes = append(notes, values...)
+	}
+	return notes, other, nil
+}
+
+// FormatChangelog formats changelog entries into a message suitable for an
+// annotated tag
+func FormatChangelog(entries []string) string {
+	lines := []string{}
+	for _, entry := range entries {
+		lines = append(lines, fmt.Sprintf("- %s", entry))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatReleaseNotes formats release notes into a message suitable for an
+// annotated tag, other entries (if any) are listed under their own heading
+func FormatReleaseNotes(notes, other []string) string {
+	message := FormatChangelog(notes)
+	if len(other) > 0 {
+		message = strings.TrimSpace(fmt.Sprintf("%s\n\nOther:\n%s", message, FormatChangelog(other)))
+	}
+	return message
+}
@@ -0,0 +1,411 @@
+package release
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// mergeFeature commits the messages on a branch off HEAD, one commit each
+// in feature.txt, while main gets a commit of its own, and merges the branch
+// back with a merge commit
+func mergeFeature(t *testing.T, repo *git.Repository, mainMessage, mergeMessage string, messages ...string) {
+	t.Helper()
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := head.Hash()
+	var feature plumbing.Hash
+	for _, message := range messages {
+		feature = commitFile(t, repo, "feature.txt", message)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reset(&git.ResetOptions{Commit: base, Mode: git.HardReset}); err != nil {
+		t.Fatalf("failed to reset to the branch point: %s", err)
+	}
+	mainline := commitFile(t, repo, "main.txt", mainMessage)
+	_, err = w.Commit(mergeMessage, &git.CommitOptions{
+		Author:            testSignature,
+		Committer:         testSignature,
+		Parents:           []plumbing.Hash{mainline, feature},
+		AllowEmptyCommits: true,
+	})
+	if err != nil {
+		t.Fatalf("failed to merge: %s", err)
+	}
+}
+
+func TestChangelogMergesOnly(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	tagHead(t, repo, "2024.06.001", "")
+	mergeFeature(t, repo, "Fix the build", "Merge pull request #1", "Add the feature", "Test the feature")
+	commitFile(t, repo, "main.txt", "Bump the docs")
+
+	tests := []struct {
+		name        string
+		mergesOnly  bool
+		firstParent bool
+		want        []string
+	}{
+		{name: "full", want: []string{"Bump the docs", "Merge pull request #1", "Fix the build", "Test the feature", "Add the feature"}},
+		{name: "merges only", mergesOnly: true, want: []string{"Merge pull request #1"}},
+		{name: "first parent", firstParent: true, want: []string{"Bump the docs", "Merge pull request #1", "Fix the build"}},
+		{name: "first parent merges only", mergesOnly: true, firstParent: true, want: []string{"Merge pull request #1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := newTestManager(t, dir)
+			rm.ChangelogMergesOnly = tt.mergesOnly
+			rm.ChangelogFirstParent = tt.firstParent
+			got, err := rm.Changelog("2024.06.001", "HEAD")
+			if err != nil {
+				t.Fatalf("Changelog() error = %s", err)
+			}
+			if !sameEntries(got, tt.want) {
+				t.Errorf("Changelog() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// sameEntries compares changelogs ignoring the order, the walk order of the
+// two sides of a merge with the same commit time isn't defined
+func sameEntries(got, want []string) bool {
+	count := map[string]int{}
+	for _, entry := range got {
+		count[entry]++
+	}
+	for _, entry := range want {
+		count[entry]--
+	}
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+	return len(got) == len(want)
+}
+
+func TestFormatChangelog(t *testing.T) {
+	got := FormatChangelog([]string{"Add the feature", "Fix the build"})
+	if want := "- Add the feature\n- Fix the build"; got != want {
+		t.Errorf("FormatChangelog() = %q, want %q", got, want)
+	}
+}
+
+func TestChangelogSkipsReleaseCommits(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []string
+		want     []string
+	}{
+		{name: "release commit", messages: []string{"Add the feature", ReleaseCommitPrefix + "2024.06.002", "Fix the build"}, want: []string{"Fix the build", "Add the feature"}},
+		{name: "consecutive release commits", messages: []string{ReleaseCommitPrefix + "2024.06.002", ReleaseCommitPrefix + "2024.06.003"}, want: []string{}},
+		{name: "prefix only at the start", messages: []string{"Revert \"" + ReleaseCommitPrefix + "2024.06.002\""}, want: []string{"Revert \"" + ReleaseCommitPrefix + "2024.06.002\""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			commitFile(t, repo, "VERSION", ReleaseCommitPrefix+"2024.06.001")
+			tagHead(t, repo, "2024.06.001", "")
+			for _, message := range tt.messages {
+				commitFile(t, repo, "VERSION", message)
+			}
+			rm := newTestManager(t, dir)
+			got, err := rm.Changelog("2024.06.001", "HEAD")
+			if err != nil {
+				t.Fatalf("Changelog() error = %s", err)
+			}
+			if !sameEntries(got, tt.want) {
+				t.Errorf("Changelog() = %q, want %q", got, tt.want)
+			}
+			// The first release's changelog doesn't list its own release
+			// commit either
+			first, err := rm.Changelog("", "2024.06.001")
+			if err != nil {
+				t.Fatalf("Changelog() error = %s", err)
+			}
+			if !sameEntries(first, []string{"initial commit"}) {
+				t.Errorf("first Changelog() = %q, want only the initial commit", first)
+			}
+		})
+	}
+}
+
+func TestReleaseNotes(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	tagHead(t, repo, "2024.06.001", "")
+	commitFile(t, repo, "file.txt", "Fix the login\n\nRelease-Note: Logging in works again")
+	commitFile(t, repo, "file.txt", "Refactor the handlers")
+	commitFile(t, repo, "file.txt", "Add exports\n\nLonger description.\n\nrelease-note: Export to CSV\nRelease-Note: Export to JSON\nSigned-off-by: Test")
+	commitFile(t, repo, "file.txt", "Bump the deps\n\nSigned-off-by: Test")
+
+	rm := newTestManager(t, dir)
+	notes, other, err := rm.ReleaseNotes("2024.06.001", "HEAD", "Release-Note")
+	if err != nil {
+		t.Fatalf("ReleaseNotes() error = %s", err)
+	}
+	if want := []string{"Export to CSV", "Export to JSON", "Logging in works again"}; !sameEntries(notes, want) {
+		t.Errorf("notes = %q, want %q", notes, want)
+	}
+	if want := []string{"Bump the deps", "Refactor the handlers"}; !sameEntries(other, want) {
+		t.Errorf("other = %q, want %q", other, want)
+	}
+}
+
+func TestFormatReleaseNotes(t *testing.T) {
+	tests := []struct {
+		name  string
+		notes []string
+		other []string
+		want  string
+	}{
+		{name: "notes", notes: []string{"Export to CSV", "Logging in works again"}, want: "- Export to CSV\n- Logging in works again"},
+		{name: "with other", notes: []string{"Export to CSV"}, other: []string{"Bump the deps"}, want: "- Export to CSV\n\nOther:\n- Bump the deps"},
+		{name: "only other", other: []string{"Bump the deps"}, want: "Other:\n- Bump the deps"},
+		{name: "nothing", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatReleaseNotes(tt.notes, tt.other); got != tt.want {
+				t.Errorf("FormatReleaseNotes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckRange(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	tagHead(t, repo, "1.0.0", "")
+	commitFile(t, repo, "file.txt", "Add the feature")
+	tagHead(t, repo, "1.1.0", "")
+	base := branchOff(t, repo, "feature")
+	tagHead(t, repo, "feature-1", "")
+	resetTo(t, repo, base)
+	commitFile(t, repo, "file.txt", "Fix the build")
+
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		wantMsg string
+	}{
+		{name: "window", from: "1.0.0", to: "1.1.0"},
+		{name: "to HEAD", from: "1.1.0", to: "HEAD"},
+		{name: "from the start", to: "1.1.0"},
+		{name: "empty", from: "1.1.0", to: "1.1.0"},
+		{name: "reversed", from: "1.1.0", to: "1.0.0", wantMsg: "1.1.0 is after 1.0.0"},
+		{name: "unreachable", from: "feature-1", to: "HEAD", wantMsg: "feature-1 is not reachable from HEAD"},
+		{name: "unknown until", from: "1.0.0", to: "2.0.0", wantMsg: "2.0.0"},
+		{name: "unknown since", from: "0.9.0", to: "HEAD", wantMsg: "0.9.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := newTestManager(t, dir)
+			err := rm.CheckRange(tt.from, tt.to)
+			if tt.wantMsg == "" {
+				if err != nil {
+					t.Fatalf("CheckRange() error = %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantMsg) {
+				t.Fatalf("CheckRange() error = %v, want one containing %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestChangelogWindow(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	tagHead(t, repo, "1.0.0", "")
+	commitFile(t, repo, "file.txt", "Add the feature")
+	commitFile(t, repo, "file.txt", "Document the feature")
+	tagHead(t, repo, "1.1.0", "")
+	commitFile(t, repo, "file.txt", "Fix the build")
+	tagHead(t, repo, "1.2.0", "")
+	commitFile(t, repo, "file.txt", "Unreleased work")
+
+	tests := []struct {
+		name  string
+		since string
+		until string
+		want  []string
+	}{
+		{name: "first window", since: "1.0.0", until: "1.1.0", want: []string{"Document the feature", "Add the feature"}},
+		{name: "second window", since: "1.1.0", until: "1.2.0", want: []string{"Fix the build"}},
+		{name: "spanning", since: "1.0.0", until: "1.2.0", want: []string{"Fix the build", "Document the feature", "Add the feature"}},
+		{name: "up to the first", until: "1.0.0", want: []string{"initial commit"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := newTestManager(t, dir)
+			got, err := rm.Changelog(tt.since, tt.until)
+			if err != nil {
+				t.Fatalf("Changelog() error = %s", err)
+			}
+			if !sameEntries(got, tt.want) {
+				t.Errorf("Changelog() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountCommits(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	tagHead(t, repo, "2024.06.001", "")
+	commitFile(t, repo, "file.txt", "Add the feature")
+	tagHead(t, repo, "2024.06.002", "")
+	mergeFeature(t, repo, "Fix the build", "Merge pull request #1", "Add the export", "Test the export")
+
+	tests := []struct {
+		from string
+		to   string
+		want int
+	}{
+		{from: "2024.06.002", to: "2024.06.002", want: 0},
+		{from: "2024.06.001", to: "2024.06.002", want: 1},
+		{from: "2024.06.002", to: "HEAD", want: 4}, // Both sides of the merge and the merge
+		{to: "2024.06.001", want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+".."+tt.to, func(t *testing.T) {
+			rm := newTestManager(t, dir)
+			got, err := rm.CountCommits(tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("CountCommits() error = %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("CountCommits() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangelogPaths(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	tagHead(t, repo, "2024.06.001", "")
+	commitFile(t, repo, "services/api/main.go", "Add an endpoint")
+	commitFile(t, repo, "web/app.js", "Restyle the page")
+	// A commit touching both components
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "web", "client.js"), []byte("client\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add("web/client.js"); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, repo, "services/api/schema.json", "Rename a field")
+	commitFile(t, repo, "README.md", "Document the setup")
+
+	tests := []struct {
+		name  string
+		paths []string
+		want  []string
+	}{
+		{name: "unscoped", want: []string{"Document the setup", "Rename a field", "Restyle the page", "Add an endpoint"}},
+		{name: "api", paths: []string{"services/api"}, want: []string{"Rename a field", "Add an endpoint"}},
+		{name: "web", paths: []string{"web"}, want: []string{"Rename a field", "Restyle the page"}},
+		{name: "several paths", paths: []string{"web", "README.md"}, want: []string{"Document the setup", "Rename a field", "Restyle the page"}},
+		{name: "prefix isn't a directory", paths: []string{"services/ap"}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := newTestManager(t, dir)
+			rm.ChangelogPaths = tt.paths
+			got, err := rm.Changelog("2024.06.001", "HEAD")
+			if err != nil {
+				t.Fatalf("Changelog() error = %s", err)
+			}
+			if !sameEntries(got, tt.want) {
+				t.Errorf("Changelog() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// commitOn makes an empty commit dated when on HEAD, or with the given
+// parents
+func commitOn(t *testing.T, repo *git.Repository, message string, when time.Time, parents ...plumbing.Hash) plumbing.Hash {
+	t.Helper()
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := &object.Signature{Name: testSignature.Name, Email: testSignature.Email, When: when}
+	hash, err := w.Commit(message, &git.CommitOptions{Author: signature, Committer: signature, Parents: parents, AllowEmptyCommits: true})
+	if err != nil {
+		t.Fatalf("failed to commit %q: %s", message, err)
+	}
+	return hash
+}
+
+func TestCommitAsOf(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial := head.Hash()
+	second := commitOn(t, repo, "Add the feature", time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC))
+	// Committed a minute after the second commit, but only merged later
+	branchOff(t, repo, "feature")
+	feature, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resetTo(t, repo, second)
+	third := commitOn(t, repo, "Fix the build", time.Date(2024, 6, 4, 12, 0, 0, 0, time.UTC))
+	merge := commitOn(t, repo, "Merge feature", time.Date(2024, 6, 6, 12, 0, 0, 0, time.UTC), third, feature.Hash())
+	latest := commitOn(t, repo, "Bump the docs", time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name    string
+		rev     string
+		asOf    time.Time
+		want    plumbing.Hash
+		wantMsg string
+	}{
+		{name: "initial", rev: "HEAD", asOf: time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC), want: initial},
+		{name: "exactly at a commit", rev: "HEAD", asOf: time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC), want: second},
+		{name: "merged commits are skipped", rev: "HEAD", asOf: time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC), want: second},
+		{name: "before the merge", rev: "master", asOf: time.Date(2024, 6, 5, 0, 0, 0, 0, time.UTC), want: third},
+		{name: "merge", rev: "HEAD", asOf: time.Date(2024, 6, 7, 0, 0, 0, 0, time.UTC), want: merge},
+		{name: "in another zone", rev: "HEAD", asOf: time.Date(2024, 6, 10, 14, 0, 0, 0, time.FixedZone("", 2*60*60)), want: latest},
+		{name: "after the latest", rev: "HEAD", asOf: time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), want: latest},
+		{name: "branch", rev: "feature", asOf: time.Date(2024, 6, 3, 12, 0, 0, 0, time.UTC), want: feature.Hash()},
+		{name: "before the first commit", rev: "HEAD", asOf: time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC), wantMsg: "no commit on HEAD at or before 2024-05-31T00:00:00Z"},
+		{name: "unknown rev", rev: "nope", asOf: time.Date(2024, 6, 7, 0, 0, 0, 0, time.UTC), wantMsg: "nope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := newTestManager(t, dir)
+			got, err := rm.CommitAsOf(tt.rev, tt.asOf)
+			if tt.wantMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantMsg) {
+					t.Fatalf("CommitAsOf() error = %v, want one containing %q", err, tt.wantMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CommitAsOf() error = %s", err)
+			}
+			if got != tt.want.String() {
+				t.Errorf("CommitAsOf() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package release
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// TagCheck is the result of checking the integrity of a release tag
+type TagCheck struct {
+	Tag      string
+	Commit   string   // The commit the tag points at
+	Exists   bool     // False if the commit is missing from the repository
+	Branches []string // Local and remote tracking branches the commit is reachable from
+}
+
+// Orphaned is true if the tag's commit is missing or isn't reachable from any
+// branch, usually the result of a force-push rewriting history
+func (t *TagCheck) Orphaned() bool {
+	return !t.Exists || len(t.Branches) == 0
+}
+
+// CheckTag confirms the commit a tag points at still exists and finds every
+// branch (local and remote tracking) it is reachable from
+func (r *Manager) CheckTag(name string) (*TagCheck, error) {
+	ref, err := r.repo.Tag(name)
+	if err != nil {
+		return nil, fmt.Errorf("tag %s does not exist: %w", name, err)
+	}
+	check := &TagCheck{Tag: name, Commit: ref.Hash().String()}
+
+	target := ref.Hash()
+	if tag, err := r.repo.TagObject(ref.Hash()); err == nil {
+		target = tag.Target
+		check.Commit = target.String()
+	} else if err != plumbing.ErrObjectNotFound {
+		return nil, err
+	}
+	commit, err := r.repo.CommitObject(target)
+	if err == plumbing.ErrObjectNotFound {
+		return check, nil
+	} else if err != nil {
+		return nil, err
+	}
+	check.Exists = true
+
+	refs, err := r.repo.References()
+	if err != nil {
+		return nil, err
+	}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference || !(ref.Name().IsBranch() || ref.Name().IsRemote()) {
+			return nil
+		}
+		head, err := r.repo.CommitObject(ref.Hash())
+		if err != nil {
+			return nil
+		}
+		reachable, err := commit.IsAncestor(head)
+		if err != nil {
+			return err
+		}
+		if reachable {
+			check.Branches = append(check.Branches, ref.Name().Short())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return check, nil
+}
@@ -0,0 +1,131 @@
+package release
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestCheckTag(t *testing.T) {
+	tests := []struct {
+		name         string
+		setup        func(t *testing.T, repo *git.Repository)
+		wantBranches []string
+		wantExists   bool
+		wantOrphaned bool
+		wantErr      string
+	}{
+		{
+			name: "reachable",
+			setup: func(t *testing.T, repo *git.Repository) {
+				tagHead(t, repo, "2024.06.001", "")
+				commitFile(t, repo, "file.txt", "After the release")
+			},
+			wantBranches: []string{"master"},
+			wantExists:   true,
+		},
+		{
+			name: "annotated",
+			setup: func(t *testing.T, repo *git.Repository) {
+				tagHead(t, repo, "2024.06.001", "Release 2024.06.001")
+			},
+			wantBranches: []string{"master"},
+			wantExists:   true,
+		},
+		{
+			name: "on another branch",
+			setup: func(t *testing.T, repo *git.Repository) {
+				base := branchOff(t, repo, "feature")
+				tagHead(t, repo, "2024.06.001", "")
+				resetTo(t, repo, base)
+			},
+			wantBranches: []string{"feature"},
+			wantExists:   true,
+		},
+		{
+			name: "history rewritten",
+			setup: func(t *testing.T, repo *git.Repository) {
+				head, err := repo.Head()
+				if err != nil {
+					t.Fatal(err)
+				}
+				commitFile(t, repo, "file.txt", "Released, then force-pushed away")
+				tagHead(t, repo, "2024.06.001", "Release 2024.06.001")
+				resetTo(t, repo, head.Hash())
+			},
+			wantExists:   true,
+			wantOrphaned: true,
+		},
+		{
+			name: "commit missing",
+			setup: func(t *testing.T, repo *git.Repository) {
+				missing := plumbing.NewHash("1234567890123456789012345678901234567890")
+				if err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewTagReferenceName("2024.06.001"), missing)); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantOrphaned: true,
+		},
+		{
+			name:    "no tag",
+			setup:   func(t *testing.T, repo *git.Repository) {},
+			wantErr: "tag 2024.06.001 does not exist",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			tt.setup(t, repo)
+			check, err := newTestManager(t, dir).CheckTag("2024.06.001")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CheckTag() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CheckTag() error = %s", err)
+			}
+			if check.Exists != tt.wantExists || check.Orphaned() != tt.wantOrphaned || !reflect.DeepEqual(check.Branches, tt.wantBranches) {
+				t.Errorf("CheckTag() = %+v (orphaned %t), want exists %t, orphaned %t and branches %q", check, check.Orphaned(), tt.wantExists, tt.wantOrphaned, tt.wantBranches)
+			}
+		})
+	}
+}
+
+// branchOff creates branch at HEAD and checks it out, returning the commit it
+// starts from
+func branchOff(t *testing.T, repo *git.Repository, branch string) plumbing.Hash {
+	t.Helper()
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(branch), Create: true}); err != nil {
+		t.Fatalf("failed to create branch %s: %s", branch, err)
+	}
+	commitFile(t, repo, "branch.txt", "Work on "+branch)
+	return head.Hash()
+}
+
+// resetTo checks out master and hard resets it to commit
+func resetTo(t *testing.T, repo *git.Repository, commit plumbing.Hash) {
+	t.Helper()
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Checkout(&git.CheckoutOptions{Branch: plumbing.Master, Force: true}); err != nil {
+		t.Fatalf("failed to check out master: %s", err)
+	}
+	if err := w.Reset(&git.ResetOptions{Commit: commit, Mode: git.HardReset}); err != nil {
+		t.Fatalf("failed to reset to %s: %s", commit, err)
+	}
+}
@@ -0,0 +1,37 @@
+package release
+
+import "time"
+
+// Now returns the current time in the manager's Location, the date of new
+// releases and the period their counter is scanned in are taken from it
+func (r *Manager) Now() time.Time {
+	if r.Location == nil {
+		return time.Now()
+	}
+	return time.Now().In(r.Location)
+}
+
+// FutureRelease returns the newest date release dated after now, which means
+// the clock is behind or the release was made on a machine whose clock was
+// ahead. Either way a release made now would sort before it. The tag is empty
+// if no release is in the future.
+func (r *Manager) FutureRelease(now time.Time) string {
+	current, ok := r.parseVersion(r.getNextDateString("", now))
+	if !ok {
+		return ""
+	}
+	// Only the date decides, the release number is the last part of the key
+	date := current.key[:len(current.key)-1]
+	future := ""
+	var futureKey []uint64
+	for _, release := range r.releases {
+		version, ok := r.parseVersion(release.Tag)
+		if !ok || compareKeys(version.key[:len(version.key)-1], date) <= 0 {
+			continue
+		}
+		if futureKey == nil || compareKeys(version.key, futureKey) > 0 {
+			future, futureKey = release.Tag, version.key
+		}
+	}
+	return future
+}
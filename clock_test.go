@@ -0,0 +1,34 @@
+package release
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFutureRelease(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		tags []string
+		want string
+	}{
+		{name: "no releases"},
+		{name: "this month", tags: []string{"2024.05.002", "2024.06.001", "2024.06.009"}},
+		{name: "next month", tags: []string{"2024.06.001", "2024.07.001"}, want: "2024.07.001"},
+		{name: "newest of several", tags: []string{"2024.07.003", "2025.01.001", "2024.12.004"}, want: "2025.01.001"},
+		{name: "component", tags: []string{"2024.06.001-api", "2024.08.002-api"}, want: "2024.08.002-api"},
+		{name: "not releases", tags: []string{"2024.06.001", "v9.0.0", "nightly"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			for _, tag := range tt.tags {
+				tagHead(t, repo, tag, "")
+			}
+			rm := newTestManager(t, dir)
+			if got := rm.FutureRelease(now); got != tt.want {
+				t.Errorf("FutureRelease() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// homeWithGitConfig returns a HOME whose ~/.gitconfig has the content
+func homeWithGitConfig(t *testing.T, content string) string {
+	t.Helper()
+	home := t.TempDir()
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return home
+}
+
+func TestGitConfigLoad(t *testing.T) {
+	const gitconfig = "[user]\n\tname = Config User\n\temail = config@example.com\n"
+	tests := []struct {
+		name        string
+		gitconfig   string // Empty for no ~/.gitconfig
+		env         []string
+		args        []string
+		wantCode    int
+		wantTagger  string
+		wantLoadLog bool // The debug log of the ~/.gitconfig that couldn't be loaded
+	}{
+		{name: "annotated from gitconfig", gitconfig: gitconfig, args: []string{"--annotate"}, wantTagger: "Config User <config@example.com>"},
+		{name: "annotated without gitconfig", env: []string{"RELEASE_USER=Env User", "RELEASE_EMAIL=env@example.com"}, args: []string{"--annotate"}, wantTagger: "Env User <env@example.com>"},
+		{name: "annotated without any identity", args: []string{"--annotate"}, wantCode: 1},
+		{name: "no-gitconfig ignores it", gitconfig: gitconfig, args: []string{"--annotate", "--no-gitconfig"}, wantCode: 1},
+		{name: "broken gitconfig is loaded for annotated", gitconfig: "[user\n", env: []string{"RELEASE_USER=Env User"}, args: []string{"--annotate"}, wantCode: 1, wantLoadLog: true},
+		{name: "lightweight skips the load", gitconfig: "[user\n", args: []string{"--lightweight"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, _ := newTestRepo(t)
+			env := tt.env
+			if tt.gitconfig != "" {
+				env = append(env, "HOME="+homeWithGitConfig(t, tt.gitconfig))
+			}
+			result := runRelease(t, dir, env, append(tt.args, "--verbose")...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, result.stderr)
+			}
+			if gotLoadLog := strings.Contains(result.stderr, "unable to load git config"); gotLoadLog != tt.wantLoadLog {
+				t.Errorf("logged the failed git config load %t, want %t:\n%s", gotLoadLog, tt.wantLoadLog, result.stderr)
+			}
+			if tt.wantCode != 0 {
+				return
+			}
+			tag := period() + "001"
+			if tt.wantTagger == "" {
+				if isAnnotated(t, dir, tag) {
+					t.Errorf("%s is annotated", tag)
+				}
+				return
+			}
+			if got := taggerOf(t, dir, tag); got != tt.wantTagger {
+				t.Errorf("tagger = %s, want %s", got, tt.wantTagger)
+			}
+		})
+	}
+}
+
+func TestResolveIdentity(t *testing.T) {
+	gitconfig := func() (string, string) { return "Config User", "config@example.com" }
+	tests := []struct {
+		name        string
+		user, email string // The flags
+		env         map[string]string
+		noGitConfig bool
+		wantUser    string
+		wantEmail   string
+		wantErr     bool
+	}{
+		{name: "gitconfig", wantUser: "Config User", wantEmail: "config@example.com"},
+		{name: "env over gitconfig", env: map[string]string{"RELEASE_USER": "Env User", "RELEASE_EMAIL": "env@example.com"}, wantUser: "Env User", wantEmail: "env@example.com"},
+		{name: "env user only", env: map[string]string{"RELEASE_USER": "Env User"}, wantUser: "Env User", wantEmail: "config@example.com"},
+		{name: "tagger over env", env: map[string]string{"RELEASE_TAGGER": "Tagger <tagger@example.com>", "RELEASE_USER": "Env User", "RELEASE_EMAIL": "env@example.com"}, wantUser: "Tagger", wantEmail: "tagger@example.com"},
+		{name: "flags over env", user: "Flag User", email: "flag@example.com", env: map[string]string{"RELEASE_TAGGER": "Tagger <tagger@example.com>", "RELEASE_USER": "Env User", "RELEASE_EMAIL": "env@example.com"}, wantUser: "Flag User", wantEmail: "flag@example.com"},
+		{name: "flag user, env email", user: "Flag User", env: map[string]string{"RELEASE_EMAIL": "env@example.com"}, wantUser: "Flag User", wantEmail: "env@example.com"},
+		{name: "flag email, gitconfig user", email: "flag@example.com", wantUser: "Config User", wantEmail: "flag@example.com"},
+		{name: "no gitconfig", noGitConfig: true, env: map[string]string{"RELEASE_USER": "Env User"}, wantUser: "Env User"},
+		{name: "bad tagger", env: map[string]string{"RELEASE_TAGGER": "no email"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(name string) string { return tt.env[name] }
+			config := gitconfig
+			if tt.noGitConfig {
+				config = nil
+			}
+			user, email, err := resolveIdentity(tt.user, tt.email, getenv, config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveIdentity() error = %v, want error %t", err, tt.wantErr)
+			}
+			if user != tt.wantUser || email != tt.wantEmail {
+				t.Errorf("resolveIdentity() = %q, %q, want %q, %q", user, email, tt.wantUser, tt.wantEmail)
+			}
+		})
+	}
+}
+
+func TestResolveIdentitySkipsGitConfig(t *testing.T) {
+	getenv := func(name string) string {
+		return map[string]string{"RELEASE_USER": "Env User", "RELEASE_EMAIL": "env@example.com"}[name]
+	}
+	gitconfig := func() (string, string) {
+		t.Error("~/.gitconfig was read with both the user and email set")
+		return "", ""
+	}
+	if _, _, err := resolveIdentity("", "", getenv, gitconfig); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseTagger(t *testing.T) {
+	tests := []struct {
+		tagger    string
+		wantUser  string
+		wantEmail string
+		wantErr   bool
+	}{
+		{tagger: "Release Bot <bot@example.com>", wantUser: "Release Bot", wantEmail: "bot@example.com"},
+		{tagger: "  Bot   < bot@example.com > ", wantErr: true},
+		{tagger: "Bot < bot@example.com >", wantUser: "Bot", wantEmail: "bot@example.com"},
+		{tagger: "Bot <a> <bot@example.com>", wantUser: "Bot <a>", wantEmail: "bot@example.com"},
+		{tagger: "Release Bot", wantErr: true},
+		{tagger: "<bot@example.com>", wantErr: true},
+		{tagger: "Release Bot <>", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tagger, func(t *testing.T) {
+			user, email, err := parseTagger(tt.tagger)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTagger(%q) error = %v, want error %t", tt.tagger, err, tt.wantErr)
+			}
+			if user != tt.wantUser || email != tt.wantEmail {
+				t.Errorf("parseTagger(%q) = %q, %q, want %q, %q", tt.tagger, user, email, tt.wantUser, tt.wantEmail)
+			}
+		})
+	}
+}
+
+func TestTaggerFlag(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        []string
+		args       []string
+		wantCode   int
+		wantTagger string
+		wantErr    string
+	}{
+		{name: "flag", args: []string{"--tagger", "Release Bot <bot@example.com>"}, wantTagger: "Release Bot <bot@example.com>"},
+		{name: "env", env: []string{"RELEASE_TAGGER=Env Bot <env-bot@example.com>"}, wantTagger: "Env Bot <env-bot@example.com>"},
+		{name: "flag over env", env: []string{"RELEASE_TAGGER=Env Bot <env-bot@example.com>"}, args: []string{"--tagger", "Release Bot <bot@example.com>"}, wantTagger: "Release Bot <bot@example.com>"},
+		{name: "env over user env", env: []string{"RELEASE_TAGGER=Env Bot <env-bot@example.com>", "RELEASE_USER=User", "RELEASE_EMAIL=user@example.com"}, wantTagger: "Env Bot <env-bot@example.com>"},
+		{name: "with --user", args: []string{"--tagger", "Release Bot <bot@example.com>", "--user", "Someone"}, wantCode: 1, wantErr: "--tagger sets both the user and email, it can't be combined with --user or --email"},
+		{name: "bad flag", args: []string{"--tagger", "Release Bot"}, wantCode: 1, wantErr: "bad --tagger"},
+		{name: "bad env", env: []string{"RELEASE_TAGGER=Release Bot"}, wantCode: 1, wantErr: "bad $RELEASE_TAGGER"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			head := commitFile(t, repo, "file.txt", "Fix the build")
+			env := append([]string{"HOME=" + t.TempDir()}, tt.env...)
+			result := runRelease(t, dir, env, append([]string{"--annotate"}, tt.args...)...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, result.stderr)
+			}
+			if !strings.Contains(result.stderr, tt.wantErr) {
+				t.Errorf("stderr doesn't contain %q:\n%s", tt.wantErr, result.stderr)
+			}
+			if tt.wantCode != 0 {
+				return
+			}
+			tag := period() + "001"
+			if got := taggerOf(t, dir, tag); got != tt.wantTagger {
+				t.Errorf("tagger = %s, want %s", got, tt.wantTagger)
+			}
+			// The released commit keeps its own author, tagging creates no
+			// commit of its own
+			if got := commitOf(t, dir, tag); got != head {
+				t.Fatalf("%s points at %s, want HEAD %s", tag, got, head)
+			}
+			commit, err := repo.CommitObject(head)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if author := commit.Author.Name + " <" + commit.Author.Email + ">"; author == tt.wantTagger || author != "Test <test@example.com>" {
+				t.Errorf("commit author = %s, want the test author", author)
+			}
+		})
+	}
+}
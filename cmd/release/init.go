@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"release"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	go_git_ssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// checklist collects the results of the "release init" checks
+type checklist struct {
+	w       io.Writer
+	missing int
+}
+
+func (c *checklist) ok(format string, args ...interface{}) {
+	fmt.Fprintf(c.w, "[ok]      %s\n", fmt.Sprintf(format, args...))
+}
+
+// fail records something that has to be fixed before releasing works, hint
+// says how
+func (c *checklist) fail(hint, format string, args ...interface{}) {
+	c.missing++
+	fmt.Fprintf(c.w, "[missing] %s\n          %s\n", fmt.Sprintf(format, args...), hint)
+}
+
+// initOptions are the settings "release init" checks and writes to the
+// starter config
+type initOptions struct {
+	cwd        string
+	repoConfig release.RepoConfig
+	remote     string
+	sshKeyPath string
+	token      string
+	user       string
+	email      string
+	gitconfig  func() (string, string) // nil with --no-gitconfig
+	format     string
+	semVer     bool // Given with --semver or in the config, otherwise detected
+}
+
+// runInit is "release init", it checks the remote, the credentials for it and
+// the tagger identity without stopping at the first problem, and writes a
+// starter RepoConfigFile with what it found if the repository doesn't have
+// one. It returns the exit code, 1 if anything is missing.
+func runInit(w io.Writer, rm *release.Manager, opts initOptions) int {
+	list := &checklist{w: w}
+
+	url, err := rm.RemoteURL(opts.remote)
+	if err != nil {
+		list.fail("add it with `git remote add "+opts.remote+" <url>` or pick another with --remote", "remote %s: %s", opts.remote, err)
+	} else {
+		list.ok("remote %s is %s", opts.remote, url)
+		checkInitAuth(list, url, opts.sshKeyPath, opts.token)
+	}
+
+	user, email, err := resolveIdentity(opts.user, opts.email, os.Getenv, opts.gitconfig)
+	switch {
+	case err != nil:
+		list.fail("fix $RELEASE_TAGGER, it has to be 'Name <email>'", "tagger: %s", err)
+	case user == "" || email == "":
+		list.fail("set `git config --global user.name` and user.email, or $RELEASE_TAGGER in CI", "no tagger identity, annotated tags can't be created")
+	default:
+		list.ok("annotated tags are created by %s <%s>", user, email)
+	}
+
+	if opts.repoConfig.Path != "" {
+		list.ok("%s already exists, leaving it as it is", opts.repoConfig.Path)
+	} else if repoDir, err := release.FindRepoDir(opts.cwd); err != nil {
+		list.fail("run release init inside the repository", "can't find the repository root: %s", err)
+	} else {
+		path := filepath.Join(repoDir, release.RepoConfigFile)
+		if err := os.WriteFile(path, []byte(starterConfig(rm, opts)), 0644); err != nil {
+			list.fail("check the permissions of "+repoDir, "failed to write %s: %s", path, err)
+		} else {
+			list.ok("wrote %s, review it and commit it for the rest of the team", path)
+		}
+	}
+
+	if list.missing > 0 {
+		fmt.Fprintf(w, "%d thing(s) to fix before releasing with --push\n", list.missing)
+		return 1
+	}
+	fmt.Fprintf(w, "ready to release\n")
+	return 0
+}
+
+// checkInitAuth checks that the credentials remoteAuth would use for the
+// remote's URL load, without exiting or prompting like it does
+func checkInitAuth(list *checklist, url, sshKeyPath, token string) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		list.fail("fix the remote's URL with `git remote set-url`", "can't parse the remote URL: %s", err)
+		return
+	}
+	switch endpoint.Protocol {
+	case "http", "https":
+		if token != "" {
+			list.ok("pushing over %s with a token", endpoint.Protocol)
+		} else if endpoint.Password != "" {
+			list.ok("pushing over %s with the credentials in the remote URL", endpoint.Protocol)
+		} else {
+			list.fail("set $RELEASE_TOKEN or pass --token", "no token to push over %s with", endpoint.Protocol)
+		}
+	case "ssh":
+		if os.Getenv("SSH_AUTH_SOCK") != "" {
+			if _, err := go_git_ssh.NewSSHAgentAuth("git"); err == nil {
+				list.ok("pushing over ssh with ssh-agent")
+				return
+			}
+		}
+		key, err := os.ReadFile(sshKeyPath)
+		if err != nil {
+			list.fail("start ssh-agent or pass the key with --ssh-key", "no ssh-agent and can't read ssh key %s: %s", sshKeyPath, err)
+			return
+		}
+		_, err = ssh.ParsePrivateKey(key)
+		if _, ok := err.(*ssh.PassphraseMissingError); ok {
+			passphrase := os.Getenv("RELEASE_SSH_PASSPHRASE")
+			if passphrase == "" {
+				list.ok("pushing over ssh with key %s, it is encrypted and the passphrase will be asked for", sshKeyPath)
+				return
+			}
+			_, err = ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+		}
+		if err != nil {
+			list.fail("check the key, or $RELEASE_SSH_PASSPHRASE if it is encrypted", "can't load ssh key %s: %s", sshKeyPath, err)
+			return
+		}
+		list.ok("pushing over ssh with key %s", sshKeyPath)
+	default:
+		list.ok("pushing to %s needs no credentials", url)
+	}
+}
+
+// starterConfig returns the RepoConfigFile written by "release init", with
+// the scheme of the existing releases and the known components
+func starterConfig(rm *release.Manager, opts initOptions) string {
+	var b strings.Builder
+	b.WriteString("# Defaults for release, flags given on the command line override them\n")
+	fmt.Fprintf(&b, "format: %q\n", opts.format)
+	if !opts.semVer {
+		// Existing semver releases and no date ones mean the repo uses
+		// semver. Copies of the manager list each scheme's releases, like
+		// ForComponent, rm itself is left as it is.
+		semVerMgr, dateMgr := *rm, *rm
+		semVerMgr.SemVer, dateMgr.SemVer = true, false
+		opts.semVer = len(semVerMgr.ListReleases("")) > 0 && len(dateMgr.ListReleases("")) == 0
+	}
+	fmt.Fprintf(&b, "semver: %t\n", opts.semVer)
+	fmt.Fprintf(&b, "remote: %s\n", opts.remote)
+	if components := rm.Components(); len(components) > 0 {
+		fmt.Fprintf(&b, "components: [%s]\n", strings.Join(components, ", "))
+	}
+	return b.String()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"release"
+)
+
+func TestStarterConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		tags       []string
+		semVer     bool // Given with --semver
+		wantSemVer bool
+	}{
+		{name: "no releases"},
+		{name: "date releases", tags: []string{"2024.06.001"}},
+		{name: "semver releases", tags: []string{"1.0.0", "1.1.0"}, wantSemVer: true},
+		{name: "both schemes", tags: []string{"1.0.0", "2024.06.001"}},
+		{name: "given", tags: []string{"2024.06.001"}, semVer: true, wantSemVer: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			for _, tag := range tt.tags {
+				tagHead(t, repo, tag)
+			}
+			rm, err := release.NewManager(dir, release.DefaultDateFormat, "%03d")
+			if err != nil {
+				t.Fatal(err)
+			}
+			rm.SemVer = tt.semVer
+			config := starterConfig(rm, initOptions{format: release.DefaultDateFormat, remote: "origin", semVer: tt.semVer})
+			if want := fmt.Sprintf("semver: %t\n", tt.wantSemVer); !strings.Contains(config, want) {
+				t.Errorf("starter config doesn't contain %q:\n%s", want, config)
+			}
+			// Detecting the scheme doesn't switch the manager's
+			if rm.SemVer != tt.semVer {
+				t.Errorf("rm.SemVer = %t after starterConfig, want %t", rm.SemVer, tt.semVer)
+			}
+		})
+	}
+}
@@ -1,35 +1,197 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/exec"
 	"os/user"
+	"regexp"
 	"release"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
 
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
 	go_git_ssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	flag "github.com/spf13/pflag"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 const (
-	incrementFormat = "%03d"
+	defaultIncWidth = 3
+	// noReleaseExitCode is the exit code of "release current" when there is
+	// no release yet
+	noReleaseExitCode = 3
 )
 
-var version = "dev"
+// These are set at build time with -ldflags "-X main.version=..." etc, see the
+// Makefile
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// loadedKeys caches loadKeys so a passphrase is only asked for once
+var loadedKeys = map[string]transport.AuthMethod{}
+
+// sshKeyConfig is the ssh key to push with and where it came from
+type sshKeyConfig struct {
+	path     string
+	given    bool // By --ssh-key, $RELEASE_SSH_KEY or ssh_key in the config, rather than the default
+	explicit bool // By --ssh-key itself, only that is an error for https remotes
+}
+
+// configDefault returns the value of the flag if it was given on the command
+// line, otherwise the one in the repository's config if that sets it
+func configDefault(name, flagValue, configValue string) string {
+	if configValue == "" || flag.CommandLine.Changed(name) {
+		return flagValue
+	}
+	return configValue
+}
+
+// resolveSSHKey returns the ssh key to push with, from --ssh-key (explicit),
+// then $RELEASE_SSH_KEY, then ssh_key in the config. A key given in any of
+// them has to exist, otherwise path is the default and ssh-agent is tried
+// first.
+func resolveSSHKey(path string, explicit bool, getenv func(string) string, repoConfig release.RepoConfig) sshKeyConfig {
+	key := sshKeyConfig{path: path, given: explicit, explicit: explicit}
+	if key.given {
+		return key
+	}
+	if envKey := getenv("RELEASE_SSH_KEY"); envKey != "" {
+		key.path, key.given = envKey, true
+	} else if repoConfig.SSHKey != "" {
+		key.path, key.given = repoConfig.SSHKey, true
+	}
+	return key
+}
+
+// loadKeys loads the ssh key to authenticate with the remote, an encrypted
+// key is decrypted with $RELEASE_SSH_PASSPHRASE or a passphrase asked for on
+// the terminal. Unless a key was given ssh-agent is used if it's running
+// ($SSH_AUTH_SOCK). It's an error if there is neither, go-git would otherwise
+// try a missing agent and fail with a less helpful message.
+func loadKeys(key sshKeyConfig) (transport.AuthMethod, error) {
+	path := key.path
+	if auth, ok := loadedKeys[path]; ok {
+		return auth, nil
+	}
+	if !key.given && os.Getenv("SSH_AUTH_SOCK") != "" {
+		agentAuth, err := go_git_ssh.NewSSHAgentAuth("git")
+		if err == nil {
+			loadedKeys[path] = agentAuth
+			return agentAuth, nil
+		}
+		log.Debug().Err(err).Msgf("failed to use ssh-agent from $SSH_AUTH_SOCK, trying ssh key %s", path)
+	}
+	sshKey, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("ssh key %s does not exist, pass the key with --ssh-key or $RELEASE_SSH_KEY or use ssh-agent", path)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read ssh key %s: %w", path, err)
+	}
+	signer, err := ssh.ParsePrivateKey(sshKey)
+	if _, ok := err.(*ssh.PassphraseMissingError); ok {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase(sshKey, readPassphrase("ssh key "+path, "RELEASE_SSH_PASSPHRASE", " or use ssh-agent"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load ssh key %s: %w", path, err)
+	}
+	auth := &go_git_ssh.PublicKeys{User: "git", Signer: signer}
+	loadedKeys[path] = auth
+	return auth, nil
+}
+
+// tokenConfig is the token to push to https remotes with and what else uses it
+type tokenConfig struct {
+	value    string
+	explicit bool // By --token itself, only that is an error for ssh remotes
+	api      bool // Also for the GitHub API, where ssh remotes are fine
+}
+
+// tokenUser is the user name sent with a token, GitHub expects it for
+// $GITHUB_TOKEN and GitLab and friends accept any user name with a token
+const tokenUser = "x-access-token"
+
+// remoteAuth returns the auth for the remote based on its URL: the token
+// (--token or $RELEASE_TOKEN) as basic auth for https remotes, the ssh key
+// (see loadKeys) for ssh remotes and nothing for anything else (local paths,
+// git://). Explicitly given credentials that can't work with the remote are an
+// error, rather than a confusing failure from the remote later.
+func remoteAuth(rm *release.Manager, remote string, sshKey sshKeyConfig, token tokenConfig) transport.AuthMethod {
+	url, err := rm.RemoteURL(remote)
+	checkIfError(err, fmt.Sprintf("problem with remote '%s'", remote))
+	endpoint, err := transport.NewEndpoint(url)
+	checkIfError(err, fmt.Sprintf("failed to parse the URL of remote '%s'", remote))
+	switch endpoint.Protocol {
+	case "http", "https":
+		// A key from $RELEASE_SSH_KEY or the config may be meant for other
+		// remotes, only --ssh-key itself is a mistake here
+		if sshKey.explicit {
+			log.Fatal().Msgf("remote %s is an %s remote, --ssh-key only works with ssh remotes, use --token or $RELEASE_TOKEN instead", remote, endpoint.Protocol)
+		}
+		if token.value == "" {
+			// Credentials in the URL are still used
+			return nil
+		}
+		return &githttp.BasicAuth{Username: tokenUser, Password: token.value}
+	case "ssh":
+		if token.explicit && !token.api {
+			log.Fatal().Msgf("remote %s is an ssh remote, --token only works with https remotes, use --ssh-key or ssh-agent instead", remote)
+		}
+		auth, err := loadKeys(sshKey)
+		checkIfError(err, fmt.Sprintf("cannot authenticate to ssh remote %s", remote))
+		return auth
+	default:
+		return nil
+	}
+}
+
+// describeAuth names the credentials remoteAuth picked, for the dry run
+func describeAuth(auth transport.AuthMethod, sshKeyPath string) string {
+	switch auth.(type) {
+	case nil:
+		return "no credentials"
+	case *githttp.BasicAuth:
+		return "token"
+	case *go_git_ssh.PublicKeysCallback:
+		return "ssh-agent"
+	case *go_git_ssh.PublicKeys:
+		return "ssh key " + sshKeyPath
+	default:
+		return auth.Name()
+	}
+}
 
-func loadKeys(path string) transport.AuthMethod {
-	var auth transport.AuthMethod
-	sshKey, _ := ioutil.ReadFile(path)
-	signer, _ := ssh.ParsePrivateKey([]byte(sshKey))
-	auth = &go_git_ssh.PublicKeys{User: "git", Signer: signer}
-	return auth
+// readPassphrase returns the passphrase of an encrypted key from the
+// environment variable, or asks for it if stdin is a terminal. hint follows
+// the error if there is neither.
+func readPassphrase(key, envVar, hint string) []byte {
+	if passphrase := os.Getenv(envVar); passphrase != "" {
+		return []byte(passphrase)
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		log.Fatal().Msgf("%s is encrypted, set $%s%s", key, envVar, hint)
+	}
+	fmt.Fprintf(os.Stderr, "Enter passphrase for %s: ", key)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	checkIfError(err, "failed to read the passphrase")
+	return passphrase
 }
 
 func homeDir() string {
@@ -40,12 +202,579 @@ func homeDir() string {
 	return usr.HomeDir
 }
 
+// gitConfigIdentity reads the user and email from ~/.gitconfig
+func gitConfigIdentity() (string, string) {
+	cfg, err := config.LoadConfig(config.GlobalScope)
+	if err != nil {
+		// At this point, we might be in a CI environment and might not have
+		// gitconfig setup. CreateTag will complain about the missing identity
+		// so we only log this at debug.
+		log.Debug().Err(err).Msg("unable to load git config, this is only a problem if you're using annotated tags")
+		return "", ""
+	}
+	return cfg.User.Name, cfg.User.Email
+}
+
+// parseTagger splits a "Name <email>" identity as given to --tagger or
+// $RELEASE_TAGGER
+func parseTagger(tagger string) (string, string, error) {
+	open := strings.LastIndex(tagger, "<")
+	if open < 0 || !strings.HasSuffix(tagger, ">") {
+		return "", "", fmt.Errorf("tagger %q isn't of the form 'Name <email>'", tagger)
+	}
+	user := strings.TrimSpace(tagger[:open])
+	email := strings.TrimSpace(tagger[open+1 : len(tagger)-1])
+	if user == "" || email == "" {
+		return "", "", fmt.Errorf("tagger %q needs both a name and an email", tagger)
+	}
+	return user, email, nil
+}
+
+// resolveIdentity works out the identity used for annotated tags. The user and
+// email are each taken from the first of these that sets them:
+//
+//  1. the --user and --email flags (or --tagger, which sets both)
+//  2. the RELEASE_TAGGER environment variable, 'Name <email>'
+//  3. the RELEASE_USER and RELEASE_EMAIL environment variables
+//  4. ~/.gitconfig, gitconfig is nil with --no-gitconfig
+func resolveIdentity(user, email string, getenv func(string) string, gitconfig func() (string, string)) (string, string, error) {
+	if tagger := getenv("RELEASE_TAGGER"); tagger != "" && (user == "" || email == "") {
+		envUser, envEmail, err := parseTagger(tagger)
+		if err != nil {
+			return "", "", fmt.Errorf("bad $RELEASE_TAGGER: %w", err)
+		}
+		if user == "" {
+			user = envUser
+		}
+		if email == "" {
+			email = envEmail
+		}
+	}
+	if user == "" {
+		user = getenv("RELEASE_USER")
+	}
+	if email == "" {
+		email = getenv("RELEASE_EMAIL")
+	}
+	if (user == "" || email == "") && gitconfig != nil {
+		cfgUser, cfgEmail := gitconfig()
+		if user == "" {
+			user = cfgUser
+		}
+		if email == "" {
+			email = cfgEmail
+		}
+	}
+	return user, email, nil
+}
+
+// parseTagDate reads a --tag-date, either seconds since the epoch (like
+// $SOURCE_DATE_EPOCH) or RFC 3339. The UTC offset is part of the tag object,
+// so epoch seconds are pinned to UTC.
+func parseTagDate(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("tag date %q is neither seconds since the epoch nor RFC 3339 (2024-06-01T12:00:00Z)", value)
+	}
+	return date, nil
+}
+
+// deleteReleases deletes the tags locally, and with push from every remote
+// first. Unless force is set only release tags are deleted, and unless
+// assumeYes is set the user has to confirm.
+func deleteReleases(rm *release.Manager, tags, remotes []string, push, force, dryRun, assumeYes bool, authFor func(remote string) transport.AuthMethod) {
+	// Don't take out tags the tool didn't make by accident
+	if !force {
+		unrelated := []string{}
+		for _, tag := range tags {
+			if !rm.IsReleaseTag(tag) {
+				unrelated = append(unrelated, tag)
+			}
+		}
+		if len(unrelated) > 0 {
+			log.Fatal().Msgf("refusing to delete %s, not named like a release (pass --force to delete anyway)", strings.Join(unrelated, ", "))
+		}
+	}
+	where := ""
+	if push {
+		where = fmt.Sprintf(" (locally and from %s)", strings.Join(remotes, ", "))
+	}
+	fmt.Printf("%d tag(s) to delete%s:\n %s\n", len(tags), where, strings.Join(tags, "\n "))
+	if dryRun {
+		return
+	}
+	if !assumeYes {
+		if !isTerminal(os.Stdin) {
+			log.Fatal().Msg("refusing to delete tags without confirmation, pass --yes")
+		}
+		if !confirm(fmt.Sprintf("delete %d tag(s)?", len(tags)), os.Stdin, os.Stderr) {
+			log.Fatal().Msg("aborted, nothing was deleted")
+		}
+	}
+	for _, tag := range tags {
+		if push {
+			// The remote goes first, a failure leaves the local tag to
+			// retry with
+			for _, remote := range remotes {
+				msg, err := rm.DeleteRemoteTag(tag, remote, authFor(remote))
+				checkIfError(err, msg)
+				fmt.Println(msg)
+			}
+		}
+		err := rm.DeleteTag(tag)
+		checkIfError(err, "failed to delete tag")
+		fmt.Printf("deleted tag %s\n", tag)
+	}
+}
+
+// syncRemoteTags compares the release tags with the remote's and lists the
+// differences, with pullMissing it fetches the tags the local repository is
+// missing and with pushExtra pushes the ones the remote is missing. The
+// credentials are only loaded if reading the remote anonymously fails, or for
+// pushing.
+func syncRemoteTags(rm *release.Manager, remote string, authFor func(remote string) transport.AuthMethod, dryRun, pullMissing, pushExtra bool, jobs int) {
+	// Like --list, try reading anonymously before loading the ssh key
+	diff, err := rm.CompareRemoteTags(remote, nil)
+	readAuth := transport.AuthMethod(nil)
+	if err != nil {
+		log.Debug().Err(err).Msgf("anonymous listing of remote %s failed, retrying with credentials", remote)
+		readAuth = authFor(remote)
+		diff, err = rm.CompareRemoteTags(remote, readAuth)
+	}
+	checkIfError(err, fmt.Sprintf("failed to compare tags with remote %s", remote))
+	if diff.InSync() {
+		fmt.Printf("release tags are in sync with remote %s\n", remote)
+		return
+	}
+	printTagList := func(heading string, tags []string) {
+		if len(tags) > 0 {
+			fmt.Printf("%s (%d):\n %s\n", heading, len(tags), strings.Join(tags, "\n "))
+		}
+	}
+	printTagList(fmt.Sprintf("only in the local repository, missing from %s", remote), diff.MissingRemote)
+	printTagList(fmt.Sprintf("only in remote %s, missing locally", remote), diff.MissingLocal)
+	printTagList("pointing at different objects locally and in the remote", diff.Differ)
+	for _, tag := range diff.Differ {
+		rm.Warnf("tag %s differs between the local repository and remote %s, this needs to be resolved by hand", tag, remote)
+	}
+	if dryRun {
+		return
+	}
+	if pullMissing && len(diff.MissingLocal) > 0 {
+		err := rm.FetchTags(diff.MissingLocal, remote, readAuth)
+		checkIfError(err, fmt.Sprintf("failed to fetch tags from remote %s", remote))
+		fmt.Printf("fetched %d tag(s) from remote %s\n", len(diff.MissingLocal), remote)
+	}
+	failedPush := false
+	if pushExtra && len(diff.MissingRemote) > 0 {
+		for _, result := range rm.PushTagsToRemote(diff.MissingRemote, remote, authFor(remote), jobs) {
+			if result.Err == nil {
+				fmt.Println(result.Message)
+			} else {
+				log.Error().Err(result.Err).Msg(result.Message)
+				failedPush = true
+			}
+		}
+	}
+	if failedPush {
+		printWarnings(rm)
+		log.Fatal().Msg("at least one tag failed to push, see above. exiting...")
+	}
+}
+
+// pushReleases pushes the created tags to every remote, a failing mirror
+// doesn't stop the others. It returns the tags each remote got (for a
+// rollback) and the remotes each tag failed to push to, recording the pushes
+// in the JSON results. prefix names a tag's component in the output of
+// concurrent jobs, withHints explains how to deal with a tag that failed.
+func pushReleases(rm *release.Manager, created, remotes []string, authFor func(remote string) transport.AuthMethod, jobs int, withHints bool, prefix func(tag string) string, results map[string]*resultJSON) (pushed, failedOn map[string][]string) {
+	pushed = map[string][]string{}
+	failedOn = map[string][]string{}
+	for _, remote := range remotes {
+		for _, result := range rm.PushTagsToRemote(created, remote, authFor(remote), jobs) {
+			if tagResult := results[result.Tag]; tagResult != nil {
+				push := pushJSON{Remote: remote, Pushed: result.Err == nil}
+				if result.Err != nil {
+					push.Error = fmt.Sprintf("%s: %s", result.Message, result.Err)
+				}
+				tagResult.Pushes = append(tagResult.Pushes, push)
+			}
+			if result.Err != nil {
+				log.Error().Err(result.Err).Msg(prefix(result.Tag) + result.Message)
+				if withHints {
+					fmt.Printf("the tag will still be in the local repo you can delete it with `git tag -d %s` or push it with `git push %s %s` once you have resolved the issue preventing push\n", result.Tag, remote, result.Tag)
+				}
+				failedOn[result.Tag] = append(failedOn[result.Tag], remote)
+				continue
+			}
+			// Great Success!
+			fmt.Println(prefix(result.Tag) + result.Message)
+			if result.Pushed {
+				pushed[remote] = append(pushed[remote], result.Tag)
+			}
+		}
+	}
+	return pushed, failedOn
+}
+
+// rollbackRelease undoes an --atomic release that failed part way, deleting
+// the tags this run pushed from each remote and then the tags it created
+// locally. The steps that failed are returned, their tags are still released.
+func rollbackRelease(rm *release.Manager, created []string, pushed map[string][]string, authFor func(remote string) transport.AuthMethod) (failed []string) {
+	remotes := []string{}
+	for remote := range pushed {
+		remotes = append(remotes, remote)
+	}
+	sort.Strings(remotes)
+	log.Warn().Msgf("rolling back %d created tag(s) and the pushes to %d remote(s) (--atomic)", len(created), len(remotes))
+	failed = []string{}
+	for _, remote := range remotes {
+		auth := authFor(remote)
+		for _, tag := range pushed[remote] {
+			msg, err := rm.DeleteRemoteTag(tag, remote, auth)
+			if err != nil {
+				log.Error().Err(err).Msgf("rollback: %s", msg)
+				failed = append(failed, fmt.Sprintf("%s: %s", msg, err))
+				continue
+			}
+			fmt.Printf("rollback: %s\n", msg)
+		}
+	}
+	for _, tag := range created {
+		if err := rm.DeleteTag(tag); err != nil {
+			log.Error().Err(err).Msgf("rollback: failed to delete local tag %s", tag)
+			failed = append(failed, fmt.Sprintf("failed to delete local tag %s: %s", tag, err))
+			continue
+		}
+		fmt.Printf("rollback: deleted local tag %s\n", tag)
+	}
+	return failed
+}
+
+// createGitHubReleases creates a GitHub release of each pushed tag in every
+// GitHub remote it was pushed to, with the tag's message as the notes and the
+// assets uploaded to it. The tags are already released, a failure is reported
+// but doesn't fail the run.
+func createGitHubReleases(rm *release.Manager, created, components, remotes []string, failedOn map[string][]string, messages map[string]string, assets []string, token string, prerelease, draft bool, results map[string]*resultJSON) {
+	for _, remote := range remotes {
+		url, err := rm.RemoteURL(remote)
+		if err != nil {
+			rm.Warnf("not creating GitHub releases in remote %s: %s", remote, err)
+			continue
+		}
+		owner, repo, ok := release.GitHubRepo(url)
+		if !ok {
+			rm.Warnf("not creating GitHub releases in remote %s, %s isn't a GitHub repository", remote, url)
+			continue
+		}
+		if token == "" {
+			rm.Warnf("not creating GitHub releases in %s/%s, the API needs --token or $RELEASE_TOKEN", owner, repo)
+			continue
+		}
+		for idx, tag := range created {
+			failed := false
+			for _, failedRemote := range failedOn[tag] {
+				failed = failed || failedRemote == remote
+			}
+			if failed {
+				continue
+			}
+			htmlURL, err := release.CreateGitHubRelease(nil, owner, repo, token, release.GitHubRelease{
+				Tag:        tag,
+				Name:       tag,
+				Body:       messages[components[idx]],
+				Prerelease: prerelease,
+				Draft:      draft,
+				Assets:     assets,
+			})
+			if errors.Is(err, release.ErrGitHubAssetUpload) {
+				log.Error().Err(err).Msgf("failed to upload the assets of the GitHub release of %s", tag)
+				rm.Warnf("the GitHub release of %s in %s/%s is missing assets: %s", tag, owner, repo, err)
+			} else if err != nil {
+				log.Error().Err(err).Msgf("failed to create the GitHub release of %s, the tag is pushed", tag)
+				rm.Warnf("the GitHub release of %s in %s/%s wasn't created: %s", tag, owner, repo, err)
+				continue
+			}
+			if draft {
+				fmt.Printf("created GitHub draft release %s\n", htmlURL)
+			} else {
+				fmt.Printf("created GitHub release %s\n", htmlURL)
+			}
+			if result := results[tag]; result != nil {
+				for pushIdx := range result.Pushes {
+					if result.Pushes[pushIdx].Remote == remote {
+						result.Pushes[pushIdx].GitHubRelease = htmlURL
+					}
+				}
+			}
+		}
+	}
+}
+
+// publishGitHubDrafts publishes the draft release of tag (see --draft)
+// in every GitHub remote, remotes that aren't on GitHub are skipped
+func publishGitHubDrafts(rm *release.Manager, tag string, remotes []string, token string, dryRun bool) {
+	for _, remote := range remotes {
+		url, err := rm.RemoteURL(remote)
+		checkIfError(err, fmt.Sprintf("can't publish the draft release of %s", tag))
+		owner, repo, ok := release.GitHubRepo(url)
+		if !ok {
+			rm.Warnf("not publishing in remote %s, %s isn't a GitHub repository", remote, url)
+			continue
+		}
+		if dryRun {
+			fmt.Printf("would publish the GitHub draft release of %s in %s/%s\n", tag, owner, repo)
+			continue
+		}
+		if token == "" {
+			log.Fatal().Msgf("publishing the draft release of %s in %s/%s needs --token or $RELEASE_TOKEN", tag, owner, repo)
+		}
+		htmlURL, err := release.PublishGitHubDraft(nil, owner, repo, token, tag)
+		checkIfError(err, "failed to publish the draft release")
+		fmt.Printf("published GitHub release %s\n", htmlURL)
+	}
+}
+
+// parseAsOf reads an --as-of date, RFC 3339 or a plain date (2024-05-17)
+// which means the end of that day in loc
+func parseAsOf(value string, loc *time.Location) (time.Time, error) {
+	if day, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return day.Add(24*time.Hour - time.Second), nil
+	}
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date %q is neither YYYY-MM-DD nor RFC 3339 (2024-05-17T18:00:00Z)", value)
+	}
+	return date, nil
+}
+
+// parseSinceDate reads the --since date of --list, unlike parseAsOf a day
+// starts at its midnight
+func parseSinceDate(value string, loc *time.Location) (time.Time, error) {
+	if day, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return day, nil
+	}
+	return parseAsOf(value, loc)
+}
+
+// filterReleaseDates returns the releases made between since and until
+// (inclusive), a zero time doesn't limit that end
+func filterReleaseDates(releases []release.Release, since, until time.Time) []release.Release {
+	filtered := []release.Release{}
+	for _, rel := range releases {
+		date := rel.ReleaseDate()
+		if (!since.IsZero() && date.Before(since)) || (!until.IsZero() && date.After(until)) {
+			continue
+		}
+		filtered = append(filtered, rel)
+	}
+	return filtered
+}
+
+// showRelease prints the details of a release, including how it was produced
+// if the tag recorded its scheme trailers
+func showRelease(rel *release.Release) {
+	fmt.Printf("tag:         %s\n", rel.Tag)
+	fmt.Printf("commit:      %s\n", rel.Hash)
+	fmt.Printf("released by: %s\n", rel.ReleasedByString(true))
+	info := release.ParseSchemeInfo(rel.ReleaseMessage)
+	if info.Scheme != "" {
+		fmt.Printf("scheme:      %s\n", info.Scheme)
+	}
+	if info.Format != "" {
+		fmt.Printf("format:      %s\n", info.Format)
+	}
+	if info.Increment != "" {
+		fmt.Printf("increment:   %s\n", info.Increment)
+	}
+	fmt.Printf("message:\n%s\n", strings.TrimSpace(rel.Message()))
+}
+
+// printReleases prints a table of releases with their date and message
+func printReleases(releases []release.Release) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, rel := range releases {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", rel.Tag, rel.ReleaseDate().Format(time.RFC3339), strings.SplitN(rel.Message(), "\n", 2)[0])
+	}
+	w.Flush()
+}
+
+// printVerifications prints a table of releases with their signature status
+func printVerifications(releases []release.Release, verifications map[string]release.TagVerification) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(w, "TAG\tSIGNATURE\tSIGNER\tDETAIL\n")
+	for _, rel := range releases {
+		result := verifications[rel.Tag]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", rel.Tag, result.Status, result.Signer, result.Detail)
+	}
+	w.Flush()
+}
+
+// listRemoteTags lists the tags in the remote. Reading doesn't need the same
+// credentials as pushing, so anonymous access (or ssh-agent for ssh remotes)
+// is tried first and the credentials are only loaded if that fails.
+func listRemoteTags(rm *release.Manager, remote string, sshKey sshKeyConfig, token tokenConfig) (map[string]string, error) {
+	tags, err := rm.RemoteTags(remote, nil)
+	if err == nil {
+		return tags, nil
+	}
+	log.Debug().Err(err).Msgf("anonymous listing of remote %s failed, retrying with credentials", remote)
+	return rm.RemoteTags(remote, remoteAuth(rm, remote, sshKey, token))
+}
+
+// printWarnings summarizes every warning recorded during the run on stderr
+func printWarnings(rm *release.Manager) {
+	warnings := rm.Warnings()
+	if len(warnings) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "completed with %d warning(s):\n", len(warnings))
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, " - %s\n", warning)
+	}
+}
+
+// checkIfError logs the message and exits if err isn't nil, the release
+// package returns its errors and leaves exiting to the command
+func checkIfError(err error, msg string) {
+	if err == nil {
+		return
+	}
+	log.Fatal().Err(err).Msg(msg)
+}
+
+// finish summarizes the warnings and exits successfully
+func finish(rm *release.Manager) {
+	printWarnings(rm)
+	os.Exit(0)
+}
+
+// printSummary prints a table of which components changed. since is the
+// release each component was compared against, nil if they all were compared
+// against the same one.
+func printSummary(changes []release.ComponentChange, since map[string]string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	if since != nil {
+		fmt.Fprintf(w, "COMPONENT\tSINCE\tSTATUS\tCOMMITS\tPATHS\n")
+	} else {
+		fmt.Fprintf(w, "COMPONENT\tSTATUS\tCOMMITS\tPATHS\n")
+	}
+	for _, change := range changes {
+		status := "unchanged"
+		if change.Changed() {
+			status = "changed"
+		}
+		paths := "(any)"
+		if change.Paths != nil {
+			paths = strings.Join(change.Paths, ",")
+		}
+		if since != nil {
+			previous := since[change.Component]
+			if previous == "" {
+				previous = "(never released)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", change.Component, previous, status, change.Commits, paths)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", change.Component, status, change.Commits, paths)
+	}
+	w.Flush()
+}
+
+// previousRelease returns the tag of the component's latest release, the one
+// a new release of it follows, or "" if it hasn't been released yet
+func previousRelease(rm *release.Manager, component string) string {
+	// The only error is ErrNoRelease
+	tag, err := rm.GetLatestRelease(component)
+	if err != nil {
+		return ""
+	}
+	return tag
+}
+
+// filterTags returns the tags matching the --match glob, all tags if it's empty
+func filterTags(tags []string, match string) []string {
+	if match == "" {
+		return tags
+	}
+	glob, err := release.MatchGlob(match)
+	checkIfError(err, "invalid --match pattern")
+	filtered := []string{}
+	for _, tag := range tags {
+		if glob.MatchString(tag) {
+			filtered = append(filtered, tag)
+		}
+	}
+	return filtered
+}
+
+// setFlags returns the names of the given boolean flags that were set, in the
+// order they were given
+func setFlags(names []string, values ...bool) []string {
+	set := []string{}
+	for idx, name := range names {
+		if values[idx] {
+			set = append(set, "--"+name)
+		}
+	}
+	return set
+}
+
+// getVersionString describes the build, e.g. release v1.2.3 (abc1234,
+// 2024-05-21). The commit and build date are left out if they weren't set
+// when building.
 func getVersionString() string {
-	return fmt.Sprintf("release %s", version)
+	info := []string{}
+	for _, value := range []string{commit, buildDate} {
+		if value != "" {
+			info = append(info, value)
+		}
+	}
+	if len(info) == 0 {
+		return fmt.Sprintf("release %s", version)
+	}
+	return fmt.Sprintf("release %s (%s)", version, strings.Join(info, ", "))
+}
+
+var patVersionString = regexp.MustCompile(`^(\S+) (\S+)(?: \(.*\))?$`)
+
+// parseVersionString reads the version back out of --version output in the
+// getVersionString format ("<program> <version> (<build info>)")
+func parseVersionString(output string) (string, error) {
+	line := strings.TrimSpace(strings.SplitN(strings.TrimSpace(output), "\n", 2)[0])
+	results := patVersionString.FindStringSubmatch(line)
+	if results == nil {
+		return "", fmt.Errorf("unexpected --version output '%s', expected '<program> <version>'", line)
+	}
+	return results[2], nil
+}
+
+// checkBinaryVersion runs the binary with --version and confirms it reports
+// the expected version
+func checkBinaryVersion(path, expect string) (string, error) {
+	output, err := exec.Command(path, "--version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("failed to run %s --version: %w", path, err)
+	}
+	found, err := parseVersionString(string(output))
+	if err != nil {
+		return "", err
+	}
+	if found != expect {
+		return found, fmt.Errorf("%s reports version %s but %s was expected", path, found, expect)
+	}
+	return found, nil
 }
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "usage: release [component] [options]\n\n")
+	fmt.Fprintf(os.Stderr, "usage: release [component] [options]\n")
+	fmt.Fprintf(os.Stderr, "       release list [component] [options]\n")
+	fmt.Fprintf(os.Stderr, "       release current [component] [options]\n")
+	fmt.Fprintf(os.Stderr, "       release init [options]\n")
+	fmt.Fprintf(os.Stderr, "       release delete <tag>... [options]\n\n")
 	flag.PrintDefaults()
 }
 
@@ -53,43 +782,211 @@ func main() {
 
 	modules := []string{}
 	var remote, message string
-	var verbose, dryRun, doPush, semVer, incMajor, incMinor, incPatch bool
-	var user, email, sshKeyPath string
-	format := "%Y.%m."
+	var remotes []string
+	var useUpstreamRemote bool
+	var githubRelease, draft bool
+	var publishDraft string
+	var verbose, dryRun, doPush, semVer, incMajor, incMinor, incPatch, incRC bool
+	var changelog, changelogMergesOnly, firstParent bool
+	var annotate, lightweight, doSelect, autoBump, noGitConfig bool
+	var autoBumpDefault, onEmptyChangelog, since, until, show, checkTag, listFrom, listTo, changedSince, notesTrailer, match string
+	var schemeTrailers, list, rename, assumeYes, autoComponent, summary, githubOutput, reachableOnly bool
+	var notesIncludeOther, notesFromPRs, deleteTags, exportShell, includeDiffstat, includeTreeHash, allowUnknown, printChangelog bool
+	var syncTags, pullMissing, pushExtra, nightly, nightlyFloating, force, forcePush, counter bool
+	var counterSeparator, checkBinary, expectVersion, branchSeparator, branchSlash, export, keyRingPath string
+	var verify, jsonOutput, current, allowDuplicate, noColor bool
+	var stableBranches []string
+	var maxComponents, jobs, pushJobs, pushRetries, incWidth, minCommits, counterWidth, emptyExitCode int
+	var ifChanged []string
+	var allowWidthOverflow, failOnClockSkew, sign, allowDirty, requireSigned, printSummaryLine, atomic bool
+	var user, email, tagger, tagDate, ref, asOf, asOfRef, sshKeyPath, token, numberPrefix, numberSuffix, incFmt, tagPrefix, bundlePath, planDot, planYAML, sbomPath, signingKey string
+	var format, output, timeZone, preHook, msgTemplate, branchName string
 	defaultRemote := "origin"
 	flag.StringArrayVarP(&modules, "component", "c", []string{}, "component to release, if not set will use 'release' which triggers all components to build and deploy, can also be specified as the first argument")
-	flag.StringVarP(&remote, "remote", "r", defaultRemote, "git remote to push to (if --push)")
+	flag.StringArrayVarP(&remotes, "remote", "r", []string{defaultRemote}, "git remote to push to (if --push), repeat it to push to several remotes")
+	flag.BoolVar(&useUpstreamRemote, "use-upstream-remote", false, "push to the remote of the current branch's upstream instead of --remote, falls back to --remote if the branch doesn't track one")
 	flag.StringVarP(&message, "msg", "m", "", "optional release message, will create an annotated git tag")
-	flag.StringVar(&user, "user", "", "override user in ~/.gitconfig")
-	flag.StringVar(&email, "email", "", "override email in ~/.gitconfig")
-	// flag.StringVarP(&format, "fmt", "f", "%Y.%m.", "date format to use")
-	flag.BoolVar(&semVer, "semver", false, "use semantic versioning <major>.<minor>.<patch>-<rc>")
+	flag.StringVar(&msgTemplate, "msg-template", "", "Go text/template of the annotated tag message, with .Tag, .Component, .Date, .Branch, .Commit, .PrevTag and .Changelog (the --changelog message)")
+	flag.BoolVar(&annotate, "annotate", false, "create an annotated tag, a message is generated if --msg is not set (default from git config release.annotate)")
+	flag.BoolVar(&lightweight, "lightweight", false, "create a lightweight tag, overrides git config release.annotate")
+	flag.StringVar(&user, "user", "", "user for annotated tags, overrides $RELEASE_USER and ~/.gitconfig")
+	flag.StringVar(&email, "email", "", "email for annotated tags, overrides $RELEASE_EMAIL and ~/.gitconfig")
+	flag.StringVar(&tagDate, "tag-date", "", "tagger date of annotated tags, seconds since the epoch or RFC 3339, for reproducible tag objects (default $SOURCE_DATE_EPOCH, or now)")
+	flag.StringVar(&tagger, "tagger", "", "'Name <email>' for annotated tags, shorthand for --user and --email, overrides $RELEASE_TAGGER")
+	flag.StringVarP(&format, "fmt", "f", release.DefaultDateFormat, "strftime format of the date part of date releases, supports %Y, %y, %m, %d and %j (a . is added if it doesn't end in . - or _)")
+	flag.StringVar(&timeZone, "tz", "", "time zone the date of date and nightly releases is taken in, e.g. UTC or America/New_York (default local time)")
+	flag.IntVar(&incWidth, "inc-width", defaultIncWidth, "minimum number of digits in the release number of date releases")
+	flag.BoolVar(&failOnClockSkew, "fail-on-clock-skew", false, "fail instead of warning when an existing date release is dated after today")
+	flag.StringVar(&incFmt, "inc-fmt", "", "printf format of the release number of date releases, %d or %0<width>d (default %0<inc-width>d)")
+	flag.BoolVar(&allowWidthOverflow, "allow-width-overflow", false, "let the release number grow wider than --inc-width instead of failing")
+	flag.StringVar(&tagPrefix, "prefix", "", "text to start date and semver release names with, e.g. 'v' for v2024.05.003 or v1.2.3-1, only tags with it are considered releases")
+	flag.StringVar(&numberPrefix, "number-prefix", "", "text to put before the release number of date releases, e.g. 'b' for 2024.05.b003")
+	flag.StringVar(&numberSuffix, "number-suffix", "", "text to put after the release number of date releases, e.g. '-build' for 2024.05.003-build")
+	flag.BoolVar(&includeTreeHash, "include-tree-hash", false, "add a short hash of HEAD's tree after the release number, e.g. 2024.05.003-t1a2b3c")
+	flag.BoolVar(&nightly, "nightly", false, "create today's nightly release (nightly-YYYY.MM.DD), does nothing if it already exists so retried jobs are safe")
+	flag.BoolVar(&nightlyFloating, "nightly-floating", false, "with --nightly, also move the floating nightly tag to today's nightly release (force pushed with --push)")
+	flag.BoolVar(&counter, "counter", false, "use a plain per-component counter with no date (<component>-<NNNN>)")
+	flag.IntVar(&counterWidth, "counter-width", 4, "minimum number of digits in the --counter number")
+	flag.StringVar(&counterSeparator, "counter-separator", "-", "separator between the component and the --counter number")
+	flag.BoolVar(&semVer, "semver", false, "use semantic versioning <major>.<minor>.<patch>-<release>, see --inc-rc for release candidates")
+	flag.StringVar(&branchSeparator, "branch-separator", "-", "separator between the branch and the version of semver releases on non-stable branches")
+	flag.StringVar(&branchSlash, "branch-slash", "", "replace / in branch names of semver releases with this (e.g. - for feature/x -> feature-x), by default the / is kept")
+	flag.StringArrayVar(&stableBranches, "stable-branch", []string{"main", "master"}, "branch whose semver releases get no branch segment, can be given more than once")
 	flag.BoolVar(&incMajor, "inc-major", false, "increment major version of semantic version")
 	flag.BoolVar(&incMinor, "inc-minor", false, "increment minor version of semantic version")
 	flag.BoolVar(&incPatch, "inc-patch", false, "increment patch version of semantic version")
+	flag.BoolVar(&incRC, "inc-rc", false, "release a release candidate (1.2.0-rc1) instead of a numbered release, the next one of the latest version or, with an --inc-* increment, the first one of the new version")
+	flag.BoolVar(&changelog, "changelog", false, "use the commits since the component's last release as the annotated tag message (if --msg is not set), limited to commits touching the component's configured paths (release.<component>.path)")
+	flag.BoolVar(&changelogMergesOnly, "changelog-merges-only", false, "only include merge commits in the changelog")
+	flag.StringVar(&onEmptyChangelog, "on-empty-changelog", "note", "what to do when the changelog is empty: block the release, warn and create a lightweight tag, or note 'No changes' in the message")
+	flag.StringVar(&since, "since", "", "start the changelog and diffstat after this tag or ref instead of the component's latest release, with --list only list releases made on or after this date (YYYY-MM-DD or RFC 3339)")
+	flag.StringVar(&ref, "ref", "", "release this commit, branch or tag instead of HEAD")
+	flag.StringVar(&branchName, "branch", "", "the branch being released, for semver release names when HEAD is detached (default the checked out branch, or the one --ref names)")
+	flag.StringVar(&asOf, "as-of", "", "release the latest commit of --as-of-ref at or before this date (YYYY-MM-DD for the end of that day, or RFC 3339) instead of HEAD")
+	flag.StringVar(&asOfRef, "as-of-ref", "HEAD", "branch whose (first parent) history --as-of searches")
+	flag.StringVar(&until, "until", "HEAD", "end the changelog and diffstat at this tag or ref, anything but HEAD requires --print-changelog, with --list only list releases made on or before this date")
+	flag.BoolVar(&printChangelog, "print-changelog", false, "print the changelog for --since..--until and exit without creating anything, for back-generating notes of past releases")
+	flag.BoolVar(&firstParent, "first-parent", false, "only follow the first parent of merge commits when building the changelog")
+	flag.BoolVar(&includeDiffstat, "include-diffstat", false, "append the diffstat since the previous release to the annotated tag message")
+	flag.BoolVar(&doSelect, "select", false, "interactively select which of the known components to release")
+	flag.BoolVar(&autoBump, "auto-bump", false, "infer the semantic version increment from the conventional commits since the last release")
+	flag.StringVar(&autoBumpDefault, "auto-bump-default", "patch", "increment to use with --auto-bump when no conventional commits are found (patch, minor, major or error)")
+	flag.BoolVar(&noGitConfig, "no-gitconfig", false, "don't read git config for the user, email or release.annotate, annotated tags then need --user and --email (or $RELEASE_USER and $RELEASE_EMAIL)")
+	flag.BoolVar(&schemeTrailers, "scheme-trailers", false, "record the release scheme, format and increment as trailers in annotated tag messages")
+	flag.StringVar(&show, "show", "", "show the details of an existing release tag and exit")
+	flag.StringVar(&checkTag, "check", "", "check that an existing release tag's commit still exists and is reachable from a branch and exit, exits 1 if it is orphaned")
+	flag.StringVar(&checkBinary, "check-binary", "", "run this binary with --version and check it reports the --expect version, then exit")
+	flag.StringVar(&expectVersion, "expect", "", "with --check-binary, the release tag the binary should report")
+	flag.StringVar(&export, "export", "", "export every release (of the component if given) with its metadata in this format (csv) and exit")
+	flag.BoolVar(&list, "list", false, "list the existing releases (of the component if given) and exit, lists the remote's releases if --remote is given")
+	flag.BoolVar(&verify, "verify", false, "with --list, verify the signature of each release against --keyring")
+	flag.StringVar(&keyRingPath, "keyring", "", "file of armored public keys (gpg --export --armor) to verify signatures with")
+	flag.BoolVar(&jsonOutput, "json", false, "with --list, print the releases as JSON")
+	flag.StringVar(&output, "output", "text", "text, or json to print a JSON document of the releases (created, proposed with --dry-run) and their pushes on stdout, everything else goes to stderr")
+	flag.StringVar(&listFrom, "from", "", "with --list, only show releases at or after this version")
+	flag.StringVar(&listTo, "to", "", "with --list, only show releases at or before this version")
+	flag.BoolVar(&syncTags, "sync", false, "compare the local release tags with the ones in --remote and exit, see --pull-missing and --push-extra to reconcile them")
+	flag.BoolVar(&pullMissing, "pull-missing", false, "with --sync, fetch the release tags only the remote has")
+	flag.BoolVar(&pushExtra, "push-extra", false, "with --sync, push the release tags only the local repository has")
+	flag.BoolVar(&rename, "rename", false, "rename an existing tag, takes the old and new names as arguments (also renames it in the remote with --push)")
+	flag.IntVar(&maxComponents, "max-components", 0, "refuse to release more than this many components in one run (0 means no limit)")
+	flag.IntVar(&minCommits, "min-commits", 0, "refuse to release unless there are at least this many commits since the latest release (of each component being released)")
+	flag.StringArrayVar(&ifChanged, "if-changed", []string{}, "only release if this path changed since the latest release, can be given more than once (any change triggers the release). Each component is compared against its own latest release and skipped if unchanged, one that was never released is always released")
+	flag.IntVar(&emptyExitCode, "empty-exit-code", 0, "exit code to use when there is nothing to release (e.g. with --if-changed)")
+	flag.BoolVar(&forcePush, "force-push", false, "with --push, overwrite tags that already exist in the remote pointing at something else (the default is to refuse)")
+	flag.StringVar(&preHook, "pre-hook", "", "shell command to run in the repository before creating the tags, the release is aborted if it fails. $RELEASE_TAG has the new tags (separated by spaces) and $RELEASE_COMMIT the released commit")
+	flag.BoolVar(&allowDuplicate, "allow-duplicate", false, "release a commit that already has a release of the component, by default that is refused")
+	flag.BoolVar(&force, "force", false, "release anyway when a guard like --min-commits would refuse, with --delete delete tags that aren't named like a release")
+	flag.BoolVarP(&assumeYes, "yes", "y", false, "proceed past safety limits like --max-components")
+	flag.BoolVar(&autoComponent, "auto-component", false, "infer the component from the current directory using the paths in git config (release.<component>.path)")
+	flag.BoolVar(&allowUnknown, "allow-unknown-components", false, "release components that aren't configured in git config (release.<component>.path) or found in existing tags")
+	flag.IntVarP(&jobs, "jobs", "j", 1, "number of component tags to create concurrently, and to push to each remote unless --push-jobs is given")
+	flag.IntVar(&pushRetries, "push-retries", 2, "how often to retry a push that failed with a network error, waiting 1s, 2s, 4s... in between (failures like an existing tag or bad credentials aren't retried)")
+	flag.IntVar(&pushJobs, "push-jobs", 0, "number of tags to push to each remote concurrently (if --push), to throttle pushes below --jobs (default --jobs)")
+	flag.BoolVar(&summary, "summary", false, "print which components changed (and by how many commits) since --changed-since and exit")
+	flag.BoolVar(&atomic, "atomic", false, "release all components or none, if any tag fails to be created or pushed the tags already created and pushed are deleted again")
+	flag.BoolVar(&printSummaryLine, "summary-line", false, "print a one line summary of each created release (component, tag, commit, branch and commits since the previous release) for posting in chat")
+	flag.StringVar(&changedSince, "changed-since", "", "release tag to compare every component against with --summary (default is each component's own latest release)")
+	flag.BoolVar(&githubOutput, "github-output", false, "write the created tag, component and commit to $GITHUB_OUTPUT for later GitHub Actions steps")
+	flag.BoolVar(&reachableOnly, "reachable-only", false, "only consider release tags reachable from HEAD, ignoring tags on unrelated branches")
+	flag.StringVar(&notesTrailer, "notes-from-trailer", "", "build the changelog from this commit trailer (e.g. Release-Note) instead of commit subjects")
+	flag.BoolVar(&notesFromPRs, "notes-from-prs", false, "build the changelog from the titles of the GitHub pull requests the commits were merged in, grouped by label, looked up in --remote with the --token (commit subjects without a token)")
+	flag.BoolVar(&notesIncludeOther, "notes-include-other", false, "with --notes-from-trailer or --notes-from-prs, list commits without the trailer (or pull request) under 'Other'")
+	flag.BoolVar(&deleteTags, "delete", false, "delete the tags given as arguments and/or matching --match (asks for confirmation unless --yes), also from --remote with --push. Tags not named like a release need --force")
+	flag.StringVar(&match, "match", "", "only act on tags matching this glob pattern (with --list or --delete), e.g. '2023.*'")
+	flag.BoolVar(&exportShell, "export-shell", false, "print the computed release as shell exports (RELEASE_TAG etc) and exit without creating anything")
+	flag.BoolVar(&allowDirty, "allow-dirty", false, "release even if the working tree has uncommitted changes or untracked files")
+	flag.BoolVarP(&sign, "sign", "s", false, "create gpg signed annotated tags, see --signing-key")
+	flag.BoolVar(&requireSigned, "require-signed", false, "refuse to push tags that aren't validly signed, verified against --keyring or the --signing-key")
+	flag.StringVar(&signingKey, "signing-key", "", "key to sign tags with, an armored secret key file or a key ID in the gpg keyring (default git config user.signingkey, then the tagger's email), an encrypted key uses $RELEASE_SIGNING_PASSPHRASE")
+	flag.StringVar(&sbomPath, "sbom", "", "record the file name and sha256 digest of this SBOM in a Release-SBOM trailer of the (annotated) tag, and upload it to the release with --github-release")
+	flag.StringVar(&bundlePath, "bundle", "", "write the created tags (and the objects they need) to a git bundle at this path, for moving releases off a disconnected machine")
+	flag.StringVar(&planYAML, "plan-yaml", "", "write the planned releases (components, tags, commit, remote and whether they'd be pushed) as YAML to this path (- for stdout) and exit without creating anything")
+	flag.StringVar(&planDot, "plan-dot", "", "write the planned releases (components, tags, commit and remote) as a Graphviz DOT file to this path (- for stdout) and exit without creating anything")
 	flag.BoolVarP(&verbose, "verbose", "v", false, "enable more output")
+	flag.BoolVar(&noColor, "no-color", false, "don't color the log output (the default if $NO_COLOR is set or stderr isn't a terminal)")
 	flag.BoolVar(&doPush, "push", false, "push tag to default remote (does 'git push')")
+	flag.BoolVar(&githubRelease, "github-release", false, "after pushing, create a GitHub release of each new tag with the --token, the tag message as its notes")
+	flag.BoolVar(&draft, "draft", false, "create the --github-release releases as drafts, to be reviewed and published with --publish-draft (only GitHub releases are supported, not GitLab)")
+	// The flag's name before GitLab was considered, still accepted
+	flag.BoolVar(&draft, "github-draft", false, "same as --draft")
+	checkIfError(flag.CommandLine.MarkDeprecated("github-draft", "use --draft instead"), "failed to set up the flags")
+	flag.StringVar(&publishDraft, "publish-draft", "", "publish the GitHub draft release of this tag (see --draft) in each --remote with the --token")
 	flag.BoolVarP(&dryRun, "dry-run", "n", false, "don't create a release, just print what would be released")
 	defaultSSHKeyPath := fmt.Sprintf("%s/.ssh/id_rsa", homeDir())
-	flag.StringVar(&sshKeyPath, "ssh-key", defaultSSHKeyPath, "specify path to ssh key")
+	flag.StringVar(&sshKeyPath, "ssh-key", defaultSSHKeyPath, "specify path to ssh key ($RELEASE_SSH_KEY)")
+	flag.StringVar(&token, "token", "", "token to push to https remotes with, e.g. a personal access token or $GITHUB_TOKEN (default $RELEASE_TOKEN)")
 	showVersion := flag.Bool("version", false, "display the version and exit")
 	flag.Usage = usage
 	flag.Parse()
 
 	if *showVersion {
 		fmt.Fprintf(os.Stderr, "%s\n", getVersionString())
+		if verbose {
+			fmt.Fprintf(os.Stderr, "built with %s for %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		}
 		os.Exit(0)
 	}
 
-	for idx := 0; idx < len(flag.Args()); idx++ {
-		modules = append(modules, flag.Arg(idx))
+	if token == "" {
+		token = os.Getenv("RELEASE_TOKEN")
+	}
+	pushToken := tokenConfig{
+		value:    token,
+		explicit: flag.CommandLine.Changed("token"),
+		api:      githubRelease || publishDraft != "" || notesFromPRs,
+	}
+
+	args := flag.Args()
+	// "release list [component]" is --list, a component called list can
+	// still be given with --component
+	if len(args) > 0 && args[0] == "list" {
+		list = true
+		args = args[1:]
+	}
+	// Likewise "release delete <tag>..." is --delete
+	if len(args) > 0 && args[0] == "delete" {
+		deleteTags = true
+		args = args[1:]
+	}
+	// "release current [component]" only prints the latest release
+	if len(args) > 0 && args[0] == "current" {
+		current = true
+		args = args[1:]
+		if len(args) > 1 {
+			fmt.Fprintf(os.Stderr, "release current takes at most one component\n")
+			os.Exit(1)
+		}
+	}
+	// "release init" checks the setup and writes a starter config
+	initRepo := false
+	if len(args) > 0 && args[0] == "init" {
+		initRepo = true
+		args = args[1:]
+		if len(args) > 0 {
+			fmt.Fprintf(os.Stderr, "release init takes no components\n")
+			os.Exit(1)
+		}
+	}
+	modules = append(modules, args...)
+
+	if doSelect && len(modules) > 0 {
+		fmt.Fprintf(os.Stderr, "--select cannot be combined with components given on the command line\n")
+		os.Exit(1)
+	}
+	if autoComponent && (doSelect || len(modules) > 0) {
+		fmt.Fprintf(os.Stderr, "--auto-component cannot be combined with --select or components given on the command line\n")
+		os.Exit(1)
 	}
 
 	if len(modules) == 0 {
 		modules = append(modules, "")
 	}
 
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	// Colors only garble logs that aren't read on a terminal, NO_COLOR is
+	// https://no-color.org
+	noColor = noColor || os.Getenv("NO_COLOR") != "" || !isTerminal(os.Stderr)
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr, NoColor: noColor})
 	// If we want UTC use this
 	// zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
@@ -98,104 +995,1334 @@ func main() {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	}
 
-	cfg, err := config.LoadConfig(config.GlobalScope)
-	if err == nil {
-		if user == "" {
-			user = cfg.User.Name
-		}
-		if email == "" {
-			email = cfg.User.Email
+	if expectVersion != "" && checkBinary == "" {
+		log.Fatal().Msg("--expect can only be used with --check-binary")
+	}
+	if checkBinary != "" {
+		if expectVersion == "" {
+			log.Fatal().Msg("--check-binary needs the expected release tag, pass --expect")
 		}
-	} else {
-		// At this point, we might be in a CI environment and might not have gitconfig
-		// setup. If we're not using heavy tags, we don't even care about this error,
-		// so we'll log a warning (only visible at debug) and if the user tries to create
-		// an annotated tag, we'll deal with it then.
-		log.Debug().Err(err).Msg("unable to load git config, this is only a problem if you're using annotated tags")
+		found, err := checkBinaryVersion(checkBinary, expectVersion)
+		checkIfError(err, "binary version check failed")
+		fmt.Printf("%s reports version %s as expected\n", checkBinary, found)
+		os.Exit(0)
 	}
 
 	cwd, err := os.Getwd()
-	release.CheckIfError(err, "failed to get current dir")
+	checkIfError(err, "failed to get current dir")
+
+	// The repository's .release.yaml overrides the built-in defaults, flags
+	// override both
+	repoConfig, err := release.LoadRepoConfig(cwd)
+	checkIfError(err, "failed to load "+release.RepoConfigFile)
+	if repoConfig.Path != "" {
+		log.Debug().Msgf("using defaults from %s", repoConfig.Path)
+	}
+	format = configDefault("fmt", format, repoConfig.Format)
+	tagPrefix = configDefault("prefix", tagPrefix, repoConfig.Prefix)
+	timeZone = configDefault("tz", timeZone, repoConfig.TZ)
+	preHook = configDefault("pre-hook", preHook, repoConfig.PreHook)
+	if repoConfig.SemVer && !flag.CommandLine.Changed("semver") {
+		semVer = true
+	}
+	if repoConfig.Remote != "" && !flag.CommandLine.Changed("remote") {
+		remotes = []string{repoConfig.Remote}
+	}
+	sshKey := resolveSSHKey(sshKeyPath, flag.CommandLine.Changed("ssh-key"), os.Getenv, repoConfig)
+
+	if incWidth < 1 {
+		log.Fatal().Msg("--inc-width must be at least 1")
+	}
+	incrementFormat := fmt.Sprintf("%%0%dd", incWidth)
+	if incFmt != "" {
+		if flag.CommandLine.Changed("inc-width") {
+			log.Fatal().Msg("--inc-fmt and --inc-width both set the release number format, give only one")
+		}
+		checkIfError(release.ValidateIncrementFormat(incFmt), "bad --inc-fmt")
+		incrementFormat = incFmt
+	}
 
 	// Create a new Release Manager
 	rm, err := release.NewManager(cwd, format, incrementFormat)
+	checkIfError(err, "failed to set up the release manager")
+	// Report tags that can't be written up front, also for --dry-run, listing
+	// and current, rather than only when the first one is created
+	checkIfError(rm.CheckRefStorage(), "tags can't be written")
+
+	if useUpstreamRemote {
+		if len(remotes) > 1 {
+			log.Fatal().Msg("--use-upstream-remote picks a single remote, it can't be combined with more than one --remote")
+		}
+		upstream, err := rm.UpstreamRemote()
+		checkIfError(err, "failed to find the current branch's upstream")
+		if upstream != "" {
+			log.Debug().Msgf("using remote %s of the current branch's upstream", upstream)
+			remotes = []string{upstream}
+		} else {
+			log.Debug().Msgf("the current branch has no upstream, using remote %s", remotes[0])
+		}
+	}
+	// Pushing goes to every remote, everything else (listing, syncing) reads
+	// a single remote
+	remote = remotes[0]
+	// authFor loads the credentials for a remote, see remoteAuth
+	authFor := func(remote string) transport.AuthMethod {
+		return remoteAuth(rm, remote, sshKey, pushToken)
+	}
+	if len(remotes) > 1 && (syncTags || list) {
+		log.Fatal().Msg("--sync and --list work with a single --remote")
+	}
+	if initRepo {
+		gitconfig := gitConfigIdentity
+		if noGitConfig {
+			gitconfig = nil
+		}
+		if tagger != "" {
+			user, email, err = parseTagger(tagger)
+			checkIfError(err, "bad --tagger")
+		}
+		os.Exit(runInit(os.Stdout, rm, initOptions{
+			cwd:        cwd,
+			repoConfig: repoConfig,
+			remote:     remote,
+			sshKeyPath: sshKey.path,
+			token:      token,
+			user:       user,
+			email:      email,
+			gitconfig:  gitconfig,
+			format:     format,
+			semVer:     semVer,
+		}))
+	}
 
 	if doPush {
-		err := rm.CheckRemote(remote)
-		release.CheckIfError(err, fmt.Sprintf("problem with remote '%s', cannot push, omit --push or fix the remote", remote))
+		for _, remote := range remotes {
+			err := rm.CheckRemote(remote)
+			checkIfError(err, fmt.Sprintf("problem with remote '%s', cannot push, omit --push or fix the remote", remote))
+			// Load the credentials now, a bad key should fail before any
+			// tag is created
+			authFor(remote)
+		}
 	}
 
 	// This is customizable, but for now, we always want a release number
 	rm.AlwaysIncludeNumber = true
+	rm.SemVer = semVer
+	rm.BranchSeparator = branchSeparator
+	rm.BranchSlash = branchSlash
+	rm.StableBranches = stableBranches
+	rm.NumberPrefix = numberPrefix
+	rm.NumberSuffix = numberSuffix
+	if err := release.ValidateTagPrefix(tagPrefix); err != nil {
+		log.Fatal().Msgf("bad --prefix: %s", err)
+	}
+	rm.TagPrefix = tagPrefix
+	if timeZone != "" {
+		rm.Location, err = time.LoadLocation(timeZone)
+		checkIfError(err, "bad --tz")
+	}
+	if jobs < 1 {
+		log.Fatal().Msg("--jobs must be at least 1")
+	}
+	if !flag.CommandLine.Changed("push-jobs") {
+		pushJobs = jobs
+	} else if pushJobs < 1 {
+		log.Fatal().Msg("--push-jobs must be at least 1")
+	}
+	if pushRetries < 0 {
+		log.Fatal().Msg("--push-retries can't be negative")
+	}
+	rm.PushRetries = pushRetries
+	rm.KnownComponents = repoConfig.Components
+	rm.ComponentSchemes = repoConfig.ComponentSchemes
+	// Releases are tagged at HEAD unless --ref or --as-of pick another commit
+	target := "HEAD"
+	if ref != "" && asOf != "" {
+		log.Fatal().Msg("--ref and --as-of can't be combined, use --as-of-ref to pick the branch --as-of searches")
+	}
+	if ref != "" {
+		target, err = rm.ResolveCommit(ref)
+		checkIfError(err, fmt.Sprintf("failed to find the commit --ref %s points at", ref))
+	}
+	if asOf != "" {
+		date, err := parseAsOf(asOf, rm.Now().Location())
+		checkIfError(err, "bad --as-of")
+		target, err = rm.CommitAsOf(asOfRef, date)
+		checkIfError(err, "failed to find the commit to release")
+		log.Info().Msgf("releasing commit %s, the latest of %s as of %s", target[:7], asOfRef, date.Format(time.RFC3339))
+	} else if flag.CommandLine.Changed("as-of-ref") {
+		log.Fatal().Msg("--as-of-ref can only be used with --as-of")
+	}
+	if target != "HEAD" {
+		rm.Target = target
+		if !flag.CommandLine.Changed("until") {
+			until = target
+		}
+	}
+	if tagDate == "" {
+		tagDate = os.Getenv("SOURCE_DATE_EPOCH")
+	}
+	if tagDate != "" {
+		rm.TagDate, err = parseTagDate(tagDate)
+		checkIfError(err, "bad --tag-date")
+	}
+	rm.ChangelogMergesOnly = changelogMergesOnly
+	rm.ChangelogFirstParent = firstParent
 
-	release.CheckIfError(err, "failed to load release manager")
-
-	newReleases := []string{}
-	if semVer {
-		proposedSemVer := rm.GetProposedSemName()
-		proposedSemVer.IncrementVersion(incMajor, incMinor, incPatch)
+	if reachableOnly {
+		err := rm.OnlyReachableFrom(target)
+		checkIfError(err, "failed to find the releases reachable from HEAD")
+	}
+
+	if current {
+		tag, err := rm.GetLatestRelease(modules[0])
+		if errors.Is(err, release.ErrNoRelease) {
+			// Nothing on stdout, $(release current) is empty
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(noReleaseExitCode)
+		}
+		checkIfError(err, "failed to find the latest release")
+		fmt.Println(tag)
+		os.Exit(0)
+	}
+
+	if rename {
+		if len(flag.Args()) != 2 {
+			log.Fatal().Msg("--rename takes exactly two arguments, the old and new tag names")
+		}
+		oldName, newName := flag.Arg(0), flag.Arg(1)
+		if dryRun {
+			fmt.Printf("would rename tag %s to %s\n", oldName, newName)
+			finish(rm)
+		}
+		err := rm.RenameTag(oldName, newName)
+		checkIfError(err, "failed to rename tag")
+		fmt.Printf("renamed tag %s to %s\n", oldName, newName)
+		if doPush {
+			for _, remote := range remotes {
+				msg, err := rm.PushTagRename(oldName, newName, remote, authFor(remote))
+				checkIfError(err, msg)
+				fmt.Println(msg)
+			}
+		}
+		finish(rm)
+	}
+
+	if publishDraft != "" {
+		publishGitHubDrafts(rm, publishDraft, remotes, token, dryRun)
+		finish(rm)
+	}
+
+	if summary {
+		components := rm.Components()
+		if len(flag.Args()) > 0 || flag.CommandLine.Changed("component") {
+			components = modules
+		}
+		if changedSince != "" {
+			changes, err := rm.ComponentChanges(changedSince, target, components)
+			checkIfError(err, "failed to summarize changes")
+			fmt.Printf("changes since %s:\n", changedSince)
+			printSummary(changes, nil)
+			finish(rm)
+		}
+		// Without a common base each component is compared against its own
+		// latest release, what its next release would contain. One that was
+		// never released counts every commit.
+		changes := []release.ComponentChange{}
+		since := map[string]string{}
+		for _, component := range components {
+			since[component] = previousRelease(rm, component)
+			change, err := rm.ComponentChanges(since[component], target, []string{component})
+			checkIfError(err, "failed to summarize changes")
+			changes = append(changes, change...)
+		}
+		fmt.Println("changes since each component's latest release:")
+		printSummary(changes, since)
+		finish(rm)
+	}
+	if changedSince != "" {
+		log.Fatal().Msg("--changed-since can only be used with --summary")
+	}
+
+	if deleteTags {
+		tags := args
+		for _, tag := range tags {
+			if !rm.TagExists(tag) {
+				log.Fatal().Msgf("tag %s does not exist in the local repository", tag)
+			}
+		}
+		if match != "" {
+			matched, err := rm.TagsMatching(match)
+			checkIfError(err, "invalid --match pattern")
+			tags = append(tags, matched...)
+		}
+		if len(tags) == 0 {
+			log.Fatal().Msg("no tags to delete, give the tags as arguments or use --match")
+		}
+		deleteReleases(rm, tags, remotes, doPush, force, dryRun, assumeYes, authFor)
+		finish(rm)
+	}
+	if match != "" && !list {
+		log.Fatal().Msg("--match can only be used with --list or --delete")
+	}
+
+	if show != "" {
+		rel, err := rm.GetRelease(show)
+		checkIfError(err, "failed to show release")
+		showRelease(rel)
+		finish(rm)
+	}
+
+	if checkTag != "" {
+		check, err := rm.CheckTag(checkTag)
+		checkIfError(err, "failed to check tag")
+		fmt.Printf("tag:         %s\n", check.Tag)
+		fmt.Printf("commit:      %s\n", check.Commit)
+		if check.Orphaned() {
+			reason := "is not reachable from any branch"
+			if !check.Exists {
+				reason = "no longer exists in the repository"
+			}
+			fmt.Printf("ORPHANED: the commit tag %s points at %s, was history rewritten by a force-push?\n", check.Tag, reason)
+			printWarnings(rm)
+			os.Exit(1)
+		}
+		fmt.Printf("reachable:   %s\n", strings.Join(check.Branches, ", "))
+		finish(rm)
+	}
+
+	if requireSigned {
+		// Tags being created are checked against the key signing them, tags
+		// that already exist need the keys of whoever signed them
+		rm.RequireSigned = true
+		if keyRingPath != "" {
+			rm.SignedKeyRing, err = release.LoadKeyRing(keyRingPath)
+			checkIfError(err, "failed to load --keyring")
+		} else if !sign {
+			log.Fatal().Msg("--require-signed needs the public keys to verify tags against, pass --keyring or --sign")
+		}
+	}
+	if (pullMissing || pushExtra) && !syncTags {
+		log.Fatal().Msg("--pull-missing and --push-extra can only be used with --sync")
+	}
+	if syncTags {
+		syncRemoteTags(rm, remote, authFor, dryRun, pullMissing, pushExtra, pushJobs)
+		finish(rm)
+	}
+
+	if export != "" {
+		if export != "csv" {
+			log.Fatal().Msgf("unknown --export format '%s', only csv is supported", export)
+		}
+		releases := []release.Release{}
 		for _, module := range modules {
-			branch, err := rm.GetBranch()
-			if err != nil {
-				log.Fatal().Msgf("Unable to get current branch: %s", err.Error())
+			releases = append(releases, rm.ForComponent(module).ListReleases(module)...)
+		}
+		err := writeReleasesCSV(os.Stdout, rm, releases)
+		checkIfError(err, "failed to export releases")
+		finish(rm)
+	}
+
+	if list && flag.CommandLine.Changed("remote") {
+		remoteTags, err := listRemoteTags(rm, remote, sshKey, pushToken)
+		checkIfError(err, fmt.Sprintf("failed to list tags in remote %s", remote))
+		tags := []string{}
+		for tag := range remoteTags {
+			tags = append(tags, tag)
+		}
+		for _, module := range modules {
+			for _, tag := range filterTags(rm.ForComponent(module).SortTags(tags, module), match) {
+				fmt.Printf("%s\t%s\n", tag, remoteTags[tag])
 			}
-			newReleases = append(newReleases, proposedSemVer.FormatRelease(module, branch))
 		}
-	} else {
-		proposedDate := rm.GetProposedDate()
+		finish(rm)
+	}
+	switch output {
+	case "text":
+	case "json":
+		// --list already has JSON output
+		jsonOutput = jsonOutput || list
+	default:
+		log.Fatal().Msgf("unknown --output '%s', it can be text or json", output)
+	}
+	if (verify || jsonOutput) && !list {
+		log.Fatal().Msg("--verify and --json can only be used with --list")
+	}
+	if list && flag.CommandLine.Changed("remote") && (verify || jsonOutput) {
+		log.Fatal().Msg("--verify and --json can't be used when listing a remote's releases")
+	}
+	// --since and --until are dates when listing, there is no changelog
+	var listSince, listUntil time.Time
+	if list && (since != "" || flag.CommandLine.Changed("until")) {
+		if flag.CommandLine.Changed("remote") {
+			log.Fatal().Msg("--since and --until can't be used when listing a remote's releases, it only has the tag names")
+		}
+		if since != "" {
+			listSince, err = parseSinceDate(since, rm.Now().Location())
+			checkIfError(err, "bad --since")
+		}
+		if flag.CommandLine.Changed("until") {
+			listUntil, err = parseAsOf(until, rm.Now().Location())
+			checkIfError(err, "bad --until")
+		}
+	}
+	keyRing := ""
+	if verify {
+		if keyRingPath == "" {
+			log.Fatal().Msg("--verify needs the public keys to verify against, pass --keyring")
+		}
+		keyRing, err = release.LoadKeyRing(keyRingPath)
+		checkIfError(err, "failed to load --keyring")
+	}
+	if list {
+		listed := []release.Release{}
+		verifications := map[string]release.TagVerification{}
+		for _, module := range modules {
+			cm := rm.ForComponent(module)
+			releases, err := cm.ReleasesInRange(cm.ListReleases(module), listFrom, listTo)
+			checkIfError(err, "failed to list releases")
+			if match != "" {
+				glob, err := release.MatchGlob(match)
+				checkIfError(err, "invalid --match pattern")
+				matched := []release.Release{}
+				for _, rel := range releases {
+					if glob.MatchString(rel.Tag) {
+						matched = append(matched, rel)
+					}
+				}
+				releases = matched
+			}
+			releases = filterReleaseDates(releases, listSince, listUntil)
+			if verify {
+				for _, rel := range releases {
+					verifications[rel.Tag], err = rm.VerifyTag(rel.Tag, keyRing)
+					checkIfError(err, "failed to verify tag")
+				}
+			}
+			switch {
+			case jsonOutput:
+				listed = append(listed, releases...)
+			case verify:
+				printVerifications(releases, verifications)
+			default:
+				printReleases(releases)
+			}
+		}
+		if jsonOutput {
+			err := writeReleasesJSON(os.Stdout, listed, verifications)
+			checkIfError(err, "failed to write releases")
+		}
+		finish(rm)
+	}
+	if listFrom != "" || listTo != "" {
+		log.Fatal().Msg("--from and --to can only be used with --list")
+	}
+
+	// Only one increment can be applied, --inc-major --inc-minor isn't a
+	// double bump
+	incFlags := setFlags([]string{"inc-major", "inc-minor", "inc-patch"}, incMajor, incMinor, incPatch)
+	if len(incFlags) > 1 {
+		log.Fatal().Msgf("only one increment flag can be used at a time, got: %s", strings.Join(incFlags, ", "))
+	}
+	// These would otherwise be silently ignored by the date scheme
+	semverComponents := false
+	for _, scheme := range rm.ComponentSchemes {
+		semverComponents = semverComponents || scheme.SemVer
+	}
+	if semverFlags := setFlags([]string{"inc-major", "inc-minor", "inc-patch", "inc-rc", "auto-bump"}, incMajor, incMinor, incPatch, incRC, autoBump); len(semverFlags) > 0 && !semVer && !semverComponents {
+		log.Fatal().Msgf("%s can only be used with --semver or components with a semver scheme", strings.Join(semverFlags, ", "))
+	}
+	// And the other way around, the date options mean nothing to semver
+	dateComponents := false
+	for _, scheme := range rm.ComponentSchemes {
+		dateComponents = dateComponents || !scheme.SemVer
+	}
+	changed := flag.CommandLine.Changed
+	if dateFlags := setFlags([]string{"fmt", "tz", "inc-width", "inc-fmt"}, changed("fmt"), changed("tz"), changed("inc-width"), changed("inc-fmt")); len(dateFlags) > 0 && semVer && !dateComponents {
+		log.Fatal().Msgf("%s can only be used with date releases, not with --semver", strings.Join(dateFlags, ", "))
+	}
+	if autoBump && (incMajor || incMinor || incPatch) {
+		log.Fatal().Msg("--auto-bump cannot be combined with --inc-major, --inc-minor or --inc-patch")
+	}
+	if counter && (semVer || nightly || includeTreeHash || numberPrefix != "" || numberSuffix != "" || tagPrefix != "") {
+		log.Fatal().Msg("--counter cannot be combined with --semver, --nightly, --include-tree-hash, --prefix, --number-prefix or --number-suffix")
+	}
+	if counter && counterWidth < 1 {
+		log.Fatal().Msg("--counter-width must be at least 1")
+	}
+	if nightlyFloating && !nightly {
+		log.Fatal().Msg("--nightly-floating can only be used with --nightly")
+	}
+	if requireSigned && doPush && !sign {
+		log.Fatal().Msg("--require-signed would refuse to push the new unsigned tags, add --sign")
+	}
+	if forcePush && !doPush {
+		log.Fatal().Msg("--force-push can only be used with --push")
+	}
+	if githubRelease && !doPush {
+		log.Fatal().Msg("--github-release can only be used with --push, the release needs the tag in the remote")
+	}
+	if draft && !githubRelease {
+		log.Fatal().Msg("--draft can only be used with --github-release, drafts are only supported for GitHub releases")
+	}
+	if forcePush && atomic {
+		log.Fatal().Msg("--force-push can't be combined with --atomic, an overwritten remote tag can't be rolled back")
+	}
+	rm.OverwriteRemoteTags = forcePush
+	rm.AllowDuplicate = allowDuplicate
+	if atomic && (nightlyFloating || bundlePath != "") {
+		log.Fatal().Msg("--atomic can't be combined with --nightly-floating or --bundle, a moved floating tag or a written bundle can't be rolled back")
+	}
+	if nightlyFloating && requireSigned {
+		log.Fatal().Msg("--nightly-floating moves a lightweight tag, it can't be pushed with --require-signed")
+	}
+	if nightly && (semVer || includeTreeHash || numberPrefix != "" || numberSuffix != "" || tagPrefix != "") {
+		log.Fatal().Msg("--nightly cannot be combined with --semver, --include-tree-hash, --prefix, --number-prefix or --number-suffix")
+	}
+	if (numberPrefix != "" || numberSuffix != "") && semVer {
+		log.Fatal().Msg("--number-prefix and --number-suffix can only be used with date releases")
+	}
+	// A digit next to the number would make it impossible to tell where the
+	// number starts or ends when scanning the existing tags
+	if strings.TrimRight(numberPrefix, "0123456789") != numberPrefix || strings.TrimLeft(numberSuffix, "0123456789") != numberSuffix {
+		log.Fatal().Msg("--number-prefix can't end with a digit and --number-suffix can't start with one")
+	}
+
+	if doSelect {
+		// Only prompt when someone is there to answer, CI jobs should list
+		// their components explicitly
+		if !isTerminal(os.Stdin) {
+			log.Fatal().Msg("--select requires an interactive terminal, specify the components to release instead")
+		}
+		modules, err = selectComponents(rm.Components(), os.Stdin, os.Stderr)
+		checkIfError(err, "failed to select components")
+	}
+
+	if autoComponent {
+		component, err := rm.ComponentForDir(cwd)
+		checkIfError(err, "failed to infer the component from the current directory")
+		if component == "" {
+			log.Info().Msg("current directory doesn't belong to any component, using the root release")
+		} else {
+			log.Info().Msgf("current directory belongs to component %s", component)
+		}
+		modules = []string{component}
+	}
+
+	// Catch typos before any tags are minted
+	if unknown := rm.UnknownComponents(modules); len(unknown) > 0 && !allowUnknown {
+		log.Fatal().Msgf("unknown component(s): %s, known components are: %s (pass --allow-unknown-components to release them anyway)", strings.Join(unknown, ", "), strings.Join(rm.Components(), ", "))
+	}
+
+	if counter || nightly {
+		for _, module := range modules {
+			if _, ok := rm.ComponentSchemes[module]; ok {
+				log.Fatal().Msgf("component %s has its own scheme in %s, it can't be released with --counter or --nightly", module, release.RepoConfigFile)
+			}
+		}
+	}
+
+	if maxComponents > 0 && len(modules) > maxComponents {
+		if !assumeYes {
+			log.Fatal().Msgf("refusing to release %d components, the limit is %d (--max-components), pass --yes to release them anyway", len(modules), maxComponents)
+		}
+		rm.Warnf("releasing %d components, over the limit of %d (--max-components) because --yes was given", len(modules), maxComponents)
+	}
+
+	if minCommits > 0 {
+		// Each component is counted from its own previous release, another
+		// component may have been released since
+		for _, module := range modules {
+			fromTag, previous := previousRelease(rm, module), "the start of history"
+			if fromTag != "" {
+				previous = fromTag
+			}
+			count, err := rm.CountCommits(fromTag, target)
+			checkIfError(err, fmt.Sprintf("failed to count the commits since the latest release%s", componentLabel(module)))
+			if count < minCommits {
+				reason := fmt.Sprintf("only %d commit(s) since %s, at least %d are needed (--min-commits)", count, previous, minCommits)
+				if count == 0 {
+					reason = fmt.Sprintf("nothing to release%s, there are no commits since %s", componentLabel(module), previous)
+				}
+				if !force {
+					log.Fatal().Msgf("%s, pass --force to release anyway", reason)
+				}
+				rm.Warnf("%s, releasing anyway because --force was given", reason)
+			}
+		}
+	}
+
+	if len(ifChanged) > 0 {
+		// Each component is compared against its own previous release and
+		// only the changed ones are released. A component that has never
+		// been released has nothing to compare against, its first release
+		// always goes ahead.
+		changedModules := []string{}
+		for _, module := range modules {
+			previous := previousRelease(rm, module)
+			if previous == "" {
+				changedModules = append(changedModules, module)
+				continue
+			}
+			changed, err := rm.PathsChanged(previous, target, ifChanged)
+			checkIfError(err, "failed to check --if-changed paths")
+			if changed {
+				changedModules = append(changedModules, module)
+			} else if len(modules) > 1 {
+				fmt.Printf("not releasing %s, none of %s changed since %s\n", componentName(module), strings.Join(ifChanged, ", "), previous)
+			} else {
+				fmt.Printf("nothing to release, none of %s changed since %s\n", strings.Join(ifChanged, ", "), previous)
+			}
+		}
+		if len(changedModules) == 0 {
+			if len(modules) > 1 {
+				fmt.Println("nothing to release, none of the components changed")
+			}
+			printWarnings(rm)
+			os.Exit(emptyExitCode)
+		}
+		modules = changedModules
+	}
+
+	if annotate && lightweight {
+		log.Fatal().Msg("--annotate and --lightweight are mutually exclusive")
+	}
+	switch onEmptyChangelog {
+	case "block", "warn", "note":
+	default:
+		log.Fatal().Msgf("invalid --on-empty-changelog '%s', must be one of block, warn or note", onEmptyChangelog)
+	}
+	if notesTrailer != "" && notesFromPRs {
+		log.Fatal().Msg("--notes-from-trailer and --notes-from-prs can't be combined")
+	}
+	if notesTrailer != "" || notesFromPRs || printChangelog {
+		changelog = true
+	}
+	// Tags are created at the target (HEAD unless --as-of is given), a
+	// changelog ending anywhere else would describe the wrong commits
+	if until != target && !printChangelog {
+		log.Fatal().Msg("--until can only be used with --print-changelog")
+	}
+	if notesIncludeOther && notesTrailer == "" && !notesFromPRs {
+		log.Fatal().Msg("--notes-include-other requires --notes-from-trailer or --notes-from-prs")
+	}
+	if lightweight && (message != "" || msgTemplate != "" || changelog || includeDiffstat) {
+		log.Fatal().Msg("--lightweight cannot be combined with --msg, --msg-template, --changelog or --include-diffstat, lightweight tags have no message")
+	}
+	var messageTemplate *template.Template
+	if msgTemplate != "" {
+		if message != "" {
+			log.Fatal().Msg("--msg and --msg-template can't be combined")
+		}
+		messageTemplate, err = parseMessageTemplate(msgTemplate)
+		checkIfError(err, "bad --msg-template")
+	}
+	if sign {
+		if lightweight {
+			log.Fatal().Msg("--sign can't be combined with --lightweight, only annotated tags can be signed")
+		}
+		annotate = true
+	}
+	sbomTrailer := ""
+	if sbomPath != "" {
+		if lightweight {
+			log.Fatal().Msg("--sbom records the SBOM's digest in the tag message, it can't be combined with --lightweight")
+		}
+		sbomTrailer, err = release.SBOMTrailer(sbomPath)
+		checkIfError(err, "failed to read --sbom")
+		// The digest needs a tag message to go in
+		annotate = true
+	}
+	if !annotate && !lightweight && !noGitConfig {
+		// Teams that always want annotated tags can set this in their git
+		// config instead of passing --annotate every time
+		if value := rm.GitConfigOption("release", "annotate"); value != "" {
+			annotate, err = strconv.ParseBool(value)
+			checkIfError(err, fmt.Sprintf("invalid value for git config release.annotate: '%s'", value))
+		}
+	}
+
+	// Each component gets its own message, with a changelog of only the
+	// commits touching its configured paths since its previous release
+	messages := map[string]string{}
+	componentPaths := rm.ComponentPaths()
+	var prOwner, prRepo string
+	if notesFromPRs && changelog && (message == "" || printChangelog) {
+		// The notes are still worth having without the API, just plainer
+		url, err := rm.RemoteURL(remote)
+		var ok bool
+		if err != nil {
+			rm.Warnf("not looking up pull requests in remote %s: %s, building the changelog from commit subjects instead", remote, err)
+			notesFromPRs = false
+		} else if prOwner, prRepo, ok = release.GitHubRepo(url); !ok {
+			rm.Warnf("remote %s (%s) isn't a GitHub repository, building the changelog from commit subjects instead of pull requests", remote, url)
+			notesFromPRs = false
+		} else if token == "" {
+			rm.Warnf("looking up pull requests in %s/%s needs --token or $RELEASE_TOKEN, building the changelog from commit subjects instead", prOwner, prRepo)
+			notesFromPRs = false
+		}
+	}
+	for _, module := range modules {
+		fromTag := since
+		if fromTag == "" {
+			// The first release has no previous one, the changelog then
+			// covers every commit
+			if previous := rm.ForComponent(module).ListReleases(module); len(previous) > 0 {
+				fromTag = previous[0].Tag
+			}
+		}
+		if changelog || includeDiffstat {
+			err := rm.CheckRange(fromTag, until)
+			checkIfError(err, "invalid changelog range")
+		}
+		componentMessage := message
+		if changelog && (message == "" || printChangelog) {
+			rm.ChangelogPaths = componentPaths[module]
+			var entries []string
+			if notesFromPRs {
+				prs, other, err := rm.PullRequestNotes(nil, prOwner, prRepo, token, fromTag, until)
+				checkIfError(err, "failed to build release notes from pull requests")
+				if !notesIncludeOther {
+					other = nil
+				}
+				componentMessage = release.FormatPullRequestNotes(prs, other)
+				for _, pr := range prs {
+					entries = append(entries, pr.Title)
+				}
+				entries = append(entries, other...)
+			} else if notesTrailer != "" {
+				notes, other, err := rm.ReleaseNotes(fromTag, until, notesTrailer)
+				checkIfError(err, "failed to generate release notes")
+				if !notesIncludeOther {
+					other = nil
+				}
+				componentMessage = release.FormatReleaseNotes(notes, other)
+				entries = append(notes, other...)
+			} else {
+				entries, err = rm.Changelog(fromTag, until)
+				checkIfError(err, "failed to generate changelog")
+				componentMessage = release.FormatChangelog(entries)
+			}
+			if len(entries) == 0 {
+				// Nothing to put in the message (no commits, or no merges with
+				// --changelog-merges-only)
+				switch onEmptyChangelog {
+				case "block":
+					log.Fatal().Msgf("no commits found for the changelog%s since '%s', refusing to release (--on-empty-changelog block)", componentLabel(module), fromTag)
+				case "warn":
+					rm.Warnf("no commits found for the changelog%s since '%s', tag will not be annotated", componentLabel(module), fromTag)
+				default:
+					componentMessage = "No changes"
+					if fromTag != "" {
+						componentMessage = fmt.Sprintf("No changes since %s", fromTag)
+					}
+				}
+			}
+		}
+
+		if includeDiffstat {
+			stat, err := rm.DiffStat(fromTag, until)
+			checkIfError(err, "failed to compute the diffstat")
+			if componentMessage == "" {
+				componentMessage = stat.String()
+			} else {
+				componentMessage = fmt.Sprintf("%s\n\n%s", strings.TrimSpace(componentMessage), stat)
+			}
+		}
+		messages[module] = componentMessage
+	}
+	rm.ChangelogPaths = nil
+
+	if printChangelog {
+		for _, module := range modules {
+			if len(modules) > 1 {
+				fmt.Printf("%s:\n", componentName(module))
+			}
+			fmt.Println(messages[module])
+		}
+		finish(rm)
+	}
+
+	// The tree hash goes between the release number and the component
+	suffixes := modules
+	if includeTreeHash {
+		segment, err := rm.TreeHashSegment(target)
+		checkIfError(err, "failed to get the tree hash of HEAD")
+		suffixes = []string{}
 		for _, module := range modules {
 			if module == "" {
-				newReleases = append(newReleases, proposedDate)
+				suffixes = append(suffixes, segment)
+			} else {
+				suffixes = append(suffixes, fmt.Sprintf("%s-%s", segment, module))
+			}
+		}
+	}
+
+	// releasedBranch is the branch being released: --branch, the branch rev
+	// names or the checked out one
+	releasedBranch := func(rev string) (string, error) {
+		if branchName != "" {
+			return branchName, nil
+		}
+		if rev != "" {
+			return rm.RefBranch(rev)
+		}
+		return rm.GetBranch()
+	}
+
+	// semverNames names the next semver release of each suffix with the
+	// manager, date names the next date release
+	semverNames := func(cm *release.Manager, suffixes []string) ([]string, release.SchemeInfo) {
+		proposedSemVer := cm.GetProposedSemName()
+		bump := release.BumpNone
+		switch {
+		case incMajor:
+			bump = release.BumpMajor
+		case incMinor:
+			bump = release.BumpMinor
+		case incPatch:
+			bump = release.BumpPatch
+		}
+		if autoBump {
+			latestTag := cm.GetLatestSemTag()
+			var reason string
+			bump, reason, err = cm.AutoBump(latestTag, target)
+			checkIfError(err, "failed to analyze commits for --auto-bump")
+			if bump == release.BumpNone {
+				if autoBumpDefault == "error" {
+					log.Fatal().Msgf("no conventional commits found since '%s', cannot infer the increment", latestTag)
+				}
+				bump, err = release.ParseBump(autoBumpDefault)
+				checkIfError(err, "invalid --auto-bump-default")
+				reason = fmt.Sprintf("no conventional commits found since '%s', using --auto-bump-default", latestTag)
+				rm.Warnf("%s", reason)
+			}
+			log.Info().Msgf("auto-bump chose a %s increment: %s", bump, reason)
+		}
+		proposedSemVer.IncrementVersion(bump)
+		scheme := release.SchemeInfo{Scheme: "semver", Format: cm.TagPrefix + "<major>.<minor>.<patch>-<release>", Increment: "release"}
+		if incRC {
+			// A version bump starts over at rc1
+			err := proposedSemVer.IncrementRC(bump != release.BumpNone)
+			checkIfError(err, "cannot release a release candidate")
+			scheme.Format, scheme.Increment = cm.TagPrefix+"<major>.<minor>.<patch>-rc<rc>", "rc"
+		}
+		if bump != release.BumpNone {
+			scheme.Increment = bump.String()
+		}
+		branch, err := releasedBranch(ref)
+		if errors.Is(err, release.ErrDetachedHead) {
+			log.Fatal().Msg("HEAD is detached, semver releases name the branch they're made on, pass it with --branch")
+		}
+		checkIfError(err, "unable to get the current branch")
+		names := []string{}
+		for _, suffix := range suffixes {
+			names = append(names, proposedSemVer.FormatRelease(suffix, branch))
+		}
+		return names, scheme
+	}
+	dateNames := func(cm *release.Manager, dateFormat string, suffixes []string) ([]string, release.SchemeInfo) {
+		if !allowWidthOverflow {
+			err := cm.CheckNumberWidth()
+			checkIfError(err, "release number overflow")
+		}
+		if future := cm.FutureRelease(rm.Now()); future != "" {
+			if failOnClockSkew {
+				log.Fatal().Msgf("release %s is dated after today, is the clock wrong? refusing to create an out of order release (--fail-on-clock-skew)", future)
+			}
+			rm.Warnf("release %s is dated after today, is the clock wrong? the new release will sort before it", future)
+		}
+		proposedDate := cm.GetProposedDate()
+		names := []string{}
+		for _, suffix := range suffixes {
+			if suffix == "" {
+				names = append(names, proposedDate)
+			} else {
+				names = append(names, fmt.Sprintf("%s-%s", proposedDate, suffix))
+			}
+		}
+		return names, release.SchemeInfo{Scheme: "date", Format: cm.TagPrefix + dateFormat, Increment: numberPrefix + incrementFormat + numberSuffix}
+	}
+
+	newReleases := []string{}
+	schemes := map[string]release.SchemeInfo{}
+	if counter {
+		scheme := release.SchemeInfo{Scheme: "counter", Format: "<component>" + counterSeparator + "<counter>", Increment: fmt.Sprintf("%%0%dd", counterWidth)}
+		for _, module := range modules {
+			name, err := rm.GetProposedCounter(module, counterSeparator, counterWidth)
+			checkIfError(err, "failed to compute the next counter release")
+			newReleases = append(newReleases, name)
+			schemes[module] = scheme
+		}
+	} else if nightly {
+		scheme := release.SchemeInfo{Scheme: "nightly", Format: release.NightlyPrefix + "-%Y.%m.%d"}
+		now := rm.Now()
+		for _, module := range modules {
+			newReleases = append(newReleases, release.NightlyTag(module, now))
+			schemes[module] = scheme
+		}
+	} else {
+		// Components with a scheme of their own (component_schemes in
+		// .release.yaml) are named separately, the others share the
+		// scheme given on the command line
+		owners := []string{}
+		byOwner := map[string][]int{}
+		for idx, module := range modules {
+			owner := ""
+			if _, ok := rm.ComponentSchemes[module]; ok {
+				owner = module
+			}
+			if _, ok := byOwner[owner]; !ok {
+				owners = append(owners, owner)
+			}
+			byOwner[owner] = append(byOwner[owner], idx)
+		}
+		newReleases = make([]string, len(modules))
+		for _, owner := range owners {
+			cm := rm.ForComponent(owner)
+			ownerSuffixes := []string{}
+			for _, idx := range byOwner[owner] {
+				ownerSuffixes = append(ownerSuffixes, suffixes[idx])
+			}
+			var names []string
+			var scheme release.SchemeInfo
+			if cm.SemVer {
+				names, scheme = semverNames(cm, ownerSuffixes)
 			} else {
-				newReleases = append(newReleases, fmt.Sprintf("%s-%s", proposedDate, module))
+				dateFormat := format
+				if componentFormat := rm.ComponentSchemes[owner].Format; componentFormat != "" {
+					dateFormat = componentFormat
+				}
+				names, scheme = dateNames(cm, dateFormat, ownerSuffixes)
+			}
+			for i, idx := range byOwner[owner] {
+				newReleases[idx] = names[i]
+				schemes[modules[idx]] = scheme
 			}
 		}
 	}
 
+	// The release each new one follows, empty for a first release. It has to
+	// be found before the new tags exist.
+	previousReleases := map[string]string{}
+	for _, module := range modules {
+		previous, err := rm.GetLatestRelease(module)
+		if err != nil && !errors.Is(err, release.ErrNoRelease) {
+			checkIfError(err, "failed to find the previous release")
+		}
+		previousReleases[module] = previous
+	}
+
+	if messageTemplate != nil {
+		commit, err := rm.ResolveCommit(target)
+		checkIfError(err, "failed to resolve the commit to release")
+		branchRev := ref
+		if asOf != "" {
+			branchRev = asOfRef
+		}
+		branch, err := releasedBranch(branchRev)
+		if err != nil {
+			log.Debug().Err(err).Msg("unable to find the released branch for --msg-template")
+			branch = ""
+		}
+		now := rm.Now()
+		for idx, newRelease := range newReleases {
+			module := modules[idx]
+			messages[module], err = renderMessage(messageTemplate, messageData{
+				Tag:       newRelease,
+				Component: module,
+				Date:      now,
+				Branch:    branch,
+				Commit:    commit,
+				PrevTag:   previousReleases[module],
+				Changelog: messages[module],
+			})
+			checkIfError(err, fmt.Sprintf("failed to render --msg-template for %s", newRelease))
+		}
+	}
+
 	plural := ""
 	if len(newReleases) > 1 {
 		plural = "s"
 	}
+	if exportShell {
+		if len(newReleases) != 1 {
+			log.Fatal().Msgf("--export-shell needs exactly one component, got %d", len(newReleases))
+		}
+		commit, err := rm.ResolveCommit(target)
+		checkIfError(err, "failed to resolve the commit to release")
+		writeShellExports(os.Stdout, newReleases[0], modules[0], commit, previousReleases[modules[0]])
+		finish(rm)
+	}
+	if planDot != "" {
+		commit, err := rm.ResolveCommit(target)
+		checkIfError(err, "failed to resolve the commit to release")
+		var pushRemotes []string
+		if doPush {
+			pushRemotes = remotes
+		}
+		out := os.Stdout
+		if planDot != "-" {
+			out, err = os.Create(planDot)
+			checkIfError(err, "failed to create --plan-dot file")
+		}
+		err = writePlanDot(out, newReleases, modules, commit, pushRemotes)
+		checkIfError(err, "failed to write --plan-dot file")
+		checkIfError(out.Close(), "failed to write --plan-dot file")
+		finish(rm)
+	}
+	if planYAML != "" {
+		commit, err := rm.ResolveCommit(target)
+		checkIfError(err, "failed to resolve the commit to release")
+		out := os.Stdout
+		if planYAML != "-" {
+			out, err = os.Create(planYAML)
+			checkIfError(err, "failed to create --plan-yaml file")
+		}
+		err = writePlanYAML(out, newReleases, modules, commit, remotes, doPush)
+		checkIfError(err, "failed to write --plan-yaml file")
+		checkIfError(out.Close(), "failed to write --plan-yaml file")
+		finish(rm)
+	}
+	// With --output json stdout only gets the JSON document, everything the
+	// run prints from here on goes to stderr
+	jsonOut := os.Stdout
+	run := runJSON{DryRun: dryRun}
+	results := map[string]*resultJSON{}
+	if output == "json" {
+		os.Stdout = os.Stderr
+		run.Commit, err = rm.ResolveCommit(target)
+		checkIfError(err, "failed to resolve the commit to release")
+		run.Releases = make([]resultJSON, len(newReleases))
+		for idx, newRelease := range newReleases {
+			run.Releases[idx] = resultJSON{Tag: newRelease, Component: modules[idx], Previous: previousReleases[modules[idx]]}
+			results[newRelease] = &run.Releases[idx]
+		}
+	}
+	emitJSON := func() {
+		if output == "json" {
+			run.Warnings = append([]string{}, rm.Warnings()...)
+			checkIfError(writeRunJSON(jsonOut, run), "failed to write the JSON output")
+		}
+	}
+
+	// The tag points at HEAD, uncommitted changes wouldn't be in the release
+	if !allowDirty && rm.Target == "" {
+		dirty, err := rm.DirtyFiles()
+		checkIfError(err, "failed to check the working tree")
+		if len(dirty) > 0 {
+			reason := fmt.Sprintf("the working tree has uncommitted changes that wouldn't be part of the release, commit or stash them (or pass --allow-dirty):\n  %s", strings.Join(dirty, "\n  "))
+			if !dryRun {
+				printWarnings(rm)
+				log.Fatal().Msg(reason)
+			}
+			rm.Warnf("%s", reason)
+		}
+	}
+	// Someone else may have released since our tags were last fetched, find
+	// out now rather than from a rejected push after tagging
+	pushPreviews := []pushPreview{}
+	if doPush {
+		// A nightly that exists locally is skipped, not created
+		proposed := []string{}
+		for _, newRelease := range newReleases {
+			if !rm.TagExists(newRelease) {
+				proposed = append(proposed, newRelease)
+			}
+		}
+		for _, remote := range remotes {
+			auth := authFor(remote)
+			conflicts, err := rm.RemoteConflicts(proposed, remote, auth)
+			if dryRun {
+				// The dry run still reports an unreachable remote as one of
+				// the things the real run would trip over
+				if err != nil {
+					rm.Warnf("failed to list the tags in remote %s: %s", remote, err)
+				}
+				url, _ := rm.RemoteURL(remote)
+				pushPreviews = append(pushPreviews, pushPreview{remote: remote, url: url, auth: describeAuth(auth, sshKey.path), tags: proposed, conflicts: conflicts, err: err})
+			} else {
+				checkIfError(err, fmt.Sprintf("failed to list the tags in remote %s", remote))
+			}
+			if len(conflicts) == 0 {
+				continue
+			}
+			reason := fmt.Sprintf("tag(s) %s already exist in remote %s, was something released in the meantime? Fetch the tags (git fetch %s --tags) and run again", strings.Join(conflicts, ", "), remote, remote)
+			switch {
+			case forcePush:
+				rm.Warnf("tag(s) %s already exist in remote %s and will be overwritten (--force-push)", strings.Join(conflicts, ", "), remote)
+			case dryRun:
+				rm.Warnf("%s", reason)
+			default:
+				printWarnings(rm)
+				log.Fatal().Msg(reason)
+			}
+		}
+	}
+
+	if tagger != "" {
+		if user != "" || email != "" {
+			log.Fatal().Msg("--tagger sets both the user and email, it can't be combined with --user or --email")
+		}
+		var err error
+		user, email, err = parseTagger(tagger)
+		checkIfError(err, "bad --tagger")
+	}
+
+	// The identity is only needed for annotated tags, so only load the git
+	// config when we're going to create one
+	hasMessage := false
+	for _, componentMessage := range messages {
+		hasMessage = hasMessage || componentMessage != ""
+	}
+	if annotate || hasMessage {
+		gitconfig := gitConfigIdentity
+		if noGitConfig {
+			gitconfig = nil
+		}
+		user, email, err = resolveIdentity(user, email, os.Getenv, gitconfig)
+		checkIfError(err, "failed to work out the tagger")
+	}
+
+	signedWith := ""
+	if sign {
+		// Like git, the key defaults to user.signingkey and then the tagger
+		key := signingKey
+		if key == "" && !noGitConfig {
+			key = rm.GitConfigOption("user", "signingkey")
+		}
+		if key == "" {
+			key = email
+		}
+		if key == "" {
+			log.Fatal().Msg("--sign needs a key, pass --signing-key or set git config user.signingkey")
+		}
+		rm.SignKey, err = release.LoadSigningKey(key, func() ([]byte, error) {
+			return readPassphrase("signing key "+key, "RELEASE_SIGNING_PASSPHRASE", ""), nil
+		})
+		checkIfError(err, "failed to load the signing key, refusing to create unsigned tags")
+		// Also for --dry-run, a key that loads can still fail to sign
+		checkIfError(release.CheckSigningKey(rm.SignKey), "the signing key can't sign, refusing to create unsigned tags")
+		signedWith = key
+	}
+
 	if dryRun {
 		fmt.Printf("would create release%s:\n%s\n", plural, strings.Join(newReleases, ", "))
-		os.Exit(0)
+		for idx, newRelease := range newReleases {
+			if (changelog || messageTemplate != nil) && messages[modules[idx]] != "" {
+				fmt.Printf("with message for %s:\n%s\n", newRelease, messages[modules[idx]])
+			}
+		}
+		for _, preview := range pushPreviews {
+			preview.print(forcePush)
+		}
+		if bundlePath != "" {
+			fmt.Printf("would write bundle %s\n", bundlePath)
+		}
+		if signedWith != "" {
+			fmt.Printf("would sign with key %s, a test signature with it worked\n", signedWith)
+		}
+		if sbomTrailer != "" {
+			fmt.Printf("would record SBOM %s\n", sbomTrailer)
+		}
+		if preHook != "" {
+			fmt.Printf("would run pre-hook: %s\n", preHook)
+		}
+		if githubRelease {
+			fmt.Printf("would create GitHub release%s of %s\n", plural, strings.Join(newReleases, ", "))
+			if sbomPath != "" {
+				fmt.Printf("would upload %s to the GitHub release%s\n", sbomPath, plural)
+			}
+		}
+		emitJSON()
+		finish(rm)
+	}
+
+	if preHook != "" {
+		commit, err := rm.ResolveCommit(target)
+		checkIfError(err, "failed to resolve the commit to release")
+		log.Info().Msgf("running pre-hook: %s", preHook)
+		env := []string{"RELEASE_TAG=" + strings.Join(newReleases, " "), "RELEASE_COMMIT=" + commit}
+		if err := rm.RunHook(preHook, env, os.Stdout, os.Stderr); err != nil {
+			printWarnings(rm)
+			log.Fatal().Msgf("the pre-hook failed, nothing was released: %s", err)
+		}
+	}
+
+	// linePrefix names the component in the output of concurrent jobs
+	linePrefix := func(component string) string {
+		if jobs < 2 || component == "" {
+			return ""
+		}
+		return "[" + component + "] "
+	}
+	componentOf := map[string]string{}
+	specs := []release.TagSpec{}
+	for idx, newRelease := range newReleases {
+		componentOf[newRelease] = modules[idx]
+		if nightly && rm.TagExists(newRelease) {
+			// Scheduled jobs get retried, one nightly a day is enough
+			fmt.Printf("nightly release %s already exists, skipping\n", newRelease)
+			if result := results[newRelease]; result != nil {
+				result.Skipped = true
+			}
+			continue
+		}
+		tagMessage := messages[modules[idx]]
+		if annotate && tagMessage == "" {
+			tagMessage = fmt.Sprintf("Release %s", newRelease)
+		}
+		if sbomTrailer != "" {
+			tagMessage = release.AppendTrailers(tagMessage, []string{release.TrailerSBOM}, map[string]string{release.TrailerSBOM: sbomTrailer})
+		}
+		if schemeTrailers && tagMessage != "" {
+			tagMessage = schemes[modules[idx]].AppendTo(tagMessage)
+		}
+		specs = append(specs, release.TagSpec{Name: newRelease, Message: tagMessage})
 	}
 
 	failedCreate := false
-	for _, newRelease := range newReleases {
-		_, err = rm.CreateTag(newRelease, message, user, email)
-		if err != nil {
-			log.Error().Msgf("failed to create tag %s: %s", newRelease, err.Error())
+	created := []string{}
+	createdComponents := []string{}
+	createdPrevious := []string{}
+	for _, result := range rm.CreateTags(specs, user, email, jobs) {
+		module := componentOf[result.Tag]
+		if result.Err != nil {
+			log.Error().Msgf("%sfailed to create tag %s: %s", linePrefix(module), result.Tag, result.Err.Error())
 			failedCreate = true
+			if tagResult := results[result.Tag]; tagResult != nil {
+				tagResult.Error = result.Err.Error()
+			}
 			continue
 		}
 		// Success!
-		fmt.Printf("created release: %s\n", newRelease)
+		fmt.Printf("%screated release: %s\n", linePrefix(module), result.Tag)
+		if tagResult := results[result.Tag]; tagResult != nil {
+			tagResult.Created = true
+		}
+		created = append(created, result.Tag)
+		createdComponents = append(createdComponents, module)
+		createdPrevious = append(createdPrevious, previousReleases[module])
+	}
+	// rollBack undoes the release for --atomic and exits, the JSON output
+	// records whether everything was undone or which steps failed
+	rollBack := func(pushed map[string][]string) {
+		failed := rollbackRelease(rm, created, pushed, authFor)
+		run.RolledBack = len(failed) == 0
+		if !run.RolledBack {
+			run.RollbackErrors = failed
+		}
+		emitJSON()
+		printWarnings(rm)
+		if !run.RolledBack {
+			log.Fatal().Msg("the rollback failed part way, the tags that couldn't be deleted (see above) are still released")
+		}
+		log.Fatal().Msg("the release failed and was rolled back (--atomic), nothing was released")
+	}
+	if atomic && failedCreate {
+		rollBack(nil)
+	}
 
-		if doPush {
-			msg, err := rm.PushTagToRemote(newRelease, remote, loadKeys(sshKeyPath))
-			if err == nil {
-				// Great Success!
+	floating := []string{}
+	if nightlyFloating && !failedCreate {
+		for idx, newRelease := range newReleases {
+			floatingTag := release.NightlyFloatingTag(modules[idx])
+			err := rm.MoveTag(floatingTag, newRelease)
+			checkIfError(err, "failed to move the floating nightly tag")
+			fmt.Printf("moved floating tag %s to %s\n", floatingTag, newRelease)
+			floating = append(floating, floatingTag)
+		}
+	}
+
+	if bundlePath != "" && len(created) > 0 {
+		err := rm.BundleTags(bundlePath, created)
+		checkIfError(err, "failed to write bundle")
+		fmt.Printf("wrote bundle %s, fetch the tags from it with `git fetch %s 'refs/tags/*:refs/tags/*'`\n", bundlePath, bundlePath)
+	}
+
+	if doPush && len(created) > 0 {
+		prefixOf := func(tag string) string {
+			return linePrefix(componentOf[tag])
+		}
+		pushed, failedOn := pushReleases(rm, created, remotes, authFor, pushJobs, !atomic, prefixOf, results)
+		failedCreate = failedCreate || len(failedOn) > 0
+		if atomic && failedCreate {
+			rollBack(pushed)
+		}
+		if len(remotes) > 1 {
+			printPushSummary(created, remotes, failedOn)
+		}
+		if githubRelease {
+			var assets []string
+			if sbomPath != "" {
+				assets = append(assets, sbomPath)
+			}
+			createGitHubReleases(rm, created, createdComponents, remotes, failedOn, messages, assets, token, incRC, draft, results)
+		}
+	}
+	if doPush {
+		for _, remote := range remotes {
+			auth := authFor(remote)
+			for _, floatingTag := range floating {
+				msg, err := rm.PushFloatingTag(floatingTag, remote, auth)
+				if err != nil {
+					log.Error().Err(err).Msg(msg)
+					failedCreate = true
+					continue
+				}
 				fmt.Println(msg)
-			} else {
-				log.Error().Err(err).Msg(msg)
-				fmt.Printf("the tag will still be in the local repo you can delete it with `git tag -d %s` or push it with `git push <REMOTE> %s` once you have resolved the issue preventing push\n", newRelease, newRelease)
-				failedCreate = true
 			}
 		}
 	}
+	if githubOutput && len(created) > 0 {
+		if outputPath := os.Getenv("GITHUB_OUTPUT"); outputPath != "" {
+			commit, err := rm.ResolveCommit(target)
+			checkIfError(err, "failed to resolve the released commit")
+			err = writeGitHubOutput(outputPath, created, createdComponents, createdPrevious, commit)
+			checkIfError(err, "failed to write $GITHUB_OUTPUT")
+		} else {
+			rm.Warnf("--github-output was given but $GITHUB_OUTPUT isn't set, not running in GitHub Actions?")
+		}
+	}
+
+	emitJSON()
 	if failedCreate {
 		// We failed at least one create, exit
 		pushMsg := ""
 		if doPush {
 			pushMsg = "/push"
 		}
+		printWarnings(rm)
 		log.Fatal().Msgf("at least one tag failed to create%s, see above. exiting...", pushMsg)
 		os.Exit(1)
 	}
 
-	if !doPush {
+	if !doPush && bundlePath == "" {
 		fmt.Printf("tag%s (%s) not pushed (--push not set), push it with:\n", plural, strings.Join(newReleases, ", "))
-		fmt.Printf(" git push %s %s\n", remote, strings.Join(newReleases, " "))
+		for _, remote := range remotes {
+			fmt.Printf(" git push %s %s\n", remote, strings.Join(newReleases, " "))
+		}
+	}
+	if printSummaryLine && len(created) > 0 {
+		commit, err := rm.ResolveCommit(target)
+		checkIfError(err, "failed to resolve the released commit")
+		branchRev := ref
+		if asOf != "" {
+			branchRev = asOfRef
+		}
+		branch, err := releasedBranch(branchRev)
+		if err != nil {
+			// Only the headline is missing the branch
+			log.Debug().Err(err).Msg("unable to find the released branch")
+			branch = ""
+		}
+		for idx, tag := range created {
+			component := createdComponents[idx]
+			previous := createdPrevious[idx]
+			changes, err := rm.ComponentChanges(previous, target, []string{component})
+			checkIfError(err, "failed to count the released commits")
+			fmt.Println(summaryLine(tag, component, commit, branch, previous, changes[0].Commits))
+		}
 	}
+	printWarnings(rm)
 }
@@ -0,0 +1,2001 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/rs/zerolog"
+)
+
+// TestMain runs the command instead of the tests when runRelease re-executes
+// the test binary, so the command is tested with its output and exit code
+func TestMain(m *testing.M) {
+	if os.Getenv("RELEASE_TEST_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	// Only the test results, not the debug logging of the managers the
+	// tests open themselves
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// runResult is the outcome of a runRelease
+type runResult struct {
+	stdout, stderr string
+	code           int
+}
+
+// runRelease runs the command with args in dir, with a HOME of its own (no
+// ~/.gitconfig) and none of the RELEASE_ and GITHUB_ variables of the
+// environment. env adds variables.
+func runRelease(t *testing.T, dir string, env []string, args ...string) runResult {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = []string{"RELEASE_TEST_MAIN=1", "HOME=" + t.TempDir(), "NO_COLOR=1"}
+	for _, variable := range os.Environ() {
+		name := strings.SplitN(variable, "=", 2)[0]
+		if name == "HOME" || name == "SSH_AUTH_SOCK" || strings.HasPrefix(name, "RELEASE_") || strings.HasPrefix(name, "GITHUB_") || strings.HasPrefix(name, "GIT_") {
+			continue
+		}
+		cmd.Env = append(cmd.Env, variable)
+	}
+	cmd.Env = append(cmd.Env, env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	result := runResult{stdout: stdout.String(), stderr: stderr.String()}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		result.code = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatalf("failed to run release %s: %s", strings.Join(args, " "), err)
+	}
+	return result
+}
+
+// mustRelease is runRelease for runs that have to succeed
+func mustRelease(t *testing.T, dir string, env []string, args ...string) runResult {
+	t.Helper()
+	result := runRelease(t, dir, env, args...)
+	if result.code != 0 {
+		t.Fatalf("release %s exited with %d:\n%s%s", strings.Join(args, " "), result.code, result.stdout, result.stderr)
+	}
+	return result
+}
+
+// testSignature is the author and committer of the commits of test
+// repositories
+var testSignature = &object.Signature{
+	Name:  "Test",
+	Email: "test@example.com",
+	When:  time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+}
+
+// testTagger is the tagger of annotated tags made by runRelease
+var testTagger = []string{"RELEASE_TAGGER=Test Tagger <tagger@example.com>"}
+
+// newTestRepo creates a repository with a single commit in a temporary
+// directory
+func newTestRepo(t *testing.T) (string, *git.Repository) {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("failed to create the test repository: %s", err)
+	}
+	commitFile(t, repo, "README", "initial commit")
+	return dir, repo
+}
+
+// commitFile commits a change to name in the repository's worktree and
+// returns the hash of the commit, dated a minute after its parent
+func commitFile(t *testing.T, repo *git.Repository, name, message string) plumbing.Hash {
+	t.Helper()
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get the worktree: %s", err)
+	}
+	path := filepath.Join(w.Filesystem.Root(), name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create the directory of %s: %s", name, err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open %s: %s", name, err)
+	}
+	f.WriteString(message + "\n")
+	f.Close()
+	if _, err := w.Add(name); err != nil {
+		t.Fatalf("failed to add %s: %s", name, err)
+	}
+	signature := *testSignature
+	if head, err := repo.Head(); err == nil {
+		if parent, err := repo.CommitObject(head.Hash()); err == nil {
+			signature.When = parent.Committer.When.Add(time.Minute)
+		}
+	}
+	hash, err := w.Commit(message, &git.CommitOptions{Author: &signature, Committer: &signature})
+	if err != nil {
+		t.Fatalf("failed to commit %s: %s", name, err)
+	}
+	return hash
+}
+
+// commitOn makes an empty commit dated when on HEAD, or with the given
+// parents
+func commitOn(t *testing.T, repo *git.Repository, message string, when time.Time, parents ...plumbing.Hash) plumbing.Hash {
+	t.Helper()
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := &object.Signature{Name: testSignature.Name, Email: testSignature.Email, When: when}
+	hash, err := w.Commit(message, &git.CommitOptions{Author: signature, Committer: signature, Parents: parents, AllowEmptyCommits: true})
+	if err != nil {
+		t.Fatalf("failed to commit %q: %s", message, err)
+	}
+	return hash
+}
+
+// tagHead creates a lightweight tag at HEAD of the repository
+func tagHead(t *testing.T, repo *git.Repository, name string) {
+	t.Helper()
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatalf("failed to resolve HEAD: %s", err)
+	}
+	if _, err := repo.CreateTag(name, head.Hash(), nil); err != nil {
+		t.Fatalf("failed to create tag %s: %s", name, err)
+	}
+}
+
+// setGitConfig sets a git config option in the repository's config
+func setGitConfig(t *testing.T, repo *git.Repository, section, option, value string) {
+	t.Helper()
+	cfg, err := repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Raw.Section(section).SetOption(option, value)
+	if err := repo.SetConfig(cfg); err != nil {
+		t.Fatalf("failed to set %s.%s: %s", section, option, err)
+	}
+}
+
+// addComponentPath configures path as belonging to component in the
+// repository's git config (release.<component>.path)
+func addComponentPath(t *testing.T, repo *git.Repository, component, path string) {
+	t.Helper()
+	cfg, err := repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Raw.Section("release").Subsection(component).AddOption("path", path)
+	if err := repo.SetConfig(cfg); err != nil {
+		t.Fatalf("failed to configure the path of %s: %s", component, err)
+	}
+}
+
+// addTestRemote creates a bare repository in a temporary directory and adds
+// it as the remote name of repo, returning the remote repository
+func addTestRemote(t *testing.T, repo *git.Repository, name string) *git.Repository {
+	t.Helper()
+	dir := t.TempDir()
+	remote, err := git.PlainInit(dir, true)
+	if err != nil {
+		t.Fatalf("failed to create the remote repository: %s", err)
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: name, URLs: []string{dir}}); err != nil {
+		t.Fatalf("failed to add remote %s: %s", name, err)
+	}
+	return remote
+}
+
+// pushTags pushes every tag of repo to the remote
+func pushTags(t *testing.T, repo *git.Repository, remote string) {
+	t.Helper()
+	err := repo.Push(&git.PushOptions{RemoteName: remote, RefSpecs: []config.RefSpec{"refs/tags/*:refs/tags/*"}})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		t.Fatalf("failed to push the tags to %s: %s", remote, err)
+	}
+}
+
+// period is the date part of date releases made today
+func period() string {
+	return time.Now().Format("2006.01.")
+}
+
+// isAnnotated reports whether tag is an annotated tag in the repository
+func isAnnotated(t *testing.T, dir, tag string) bool {
+	t.Helper()
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := repo.Tag(tag)
+	if err != nil {
+		t.Fatalf("tag %s doesn't exist: %s", tag, err)
+	}
+	_, err = repo.TagObject(ref.Hash())
+	return err == nil
+}
+
+// countTags returns the number of tags in the repository
+func countTags(t *testing.T, dir string) int {
+	t.Helper()
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := repo.Tags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	tags.ForEach(func(*plumbing.Reference) error {
+		count++
+		return nil
+	})
+	return count
+}
+
+// messageOf returns the message of an annotated tag
+func messageOf(t *testing.T, dir, tag string) string {
+	t.Helper()
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := repo.Tag(tag)
+	if err != nil {
+		t.Fatalf("tag %s doesn't exist: %s", tag, err)
+	}
+	tagObject, err := repo.TagObject(ref.Hash())
+	if err != nil {
+		t.Fatalf("%s isn't an annotated tag: %s", tag, err)
+	}
+	return tagObject.Message
+}
+
+// taggerOf returns the "Name <email>" tagger of an annotated tag
+func taggerOf(t *testing.T, dir, tag string) string {
+	t.Helper()
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := repo.Tag(tag)
+	if err != nil {
+		t.Fatalf("tag %s doesn't exist: %s", tag, err)
+	}
+	tagObject, err := repo.TagObject(ref.Hash())
+	if err != nil {
+		t.Fatalf("%s isn't an annotated tag: %s", tag, err)
+	}
+	return tagObject.Tagger.Name + " <" + tagObject.Tagger.Email + ">"
+}
+
+// writeTestKeys generates a signing key and writes it as an armored secret
+// key file for --signing-key and an armored keyring for --keyring
+func writeTestKeys(t *testing.T) (secret, public string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("Test Tagger", "", "tagger@example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to generate the signing key: %s", err)
+	}
+	dir := t.TempDir()
+	write := func(name, blockType string, serialize func(io.Writer) error) string {
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		w, err := armor.Encode(f, blockType, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := serialize(w); err != nil {
+			t.Fatalf("failed to write %s: %s", name, err)
+		}
+		w.Close()
+		return path
+	}
+	secret = write("secret.asc", openpgp.PrivateKeyType, func(w io.Writer) error { return entity.SerializePrivate(w, nil) })
+	public = write("public.asc", openpgp.PublicKeyType, entity.Serialize)
+	return secret, public
+}
+
+// commitOf returns the commit a lightweight or annotated tag points at
+func commitOf(t *testing.T, dir, tag string) plumbing.Hash {
+	t.Helper()
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := repo.Tag(tag)
+	if err != nil {
+		t.Fatalf("tag %s doesn't exist: %s", tag, err)
+	}
+	if tagObject, err := repo.TagObject(ref.Hash()); err == nil {
+		return tagObject.Target
+	}
+	return ref.Hash()
+}
+
+func TestAnnotateDefault(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        string // git config release.annotate
+		args          []string
+		wantAnnotated bool
+		wantErr       string
+	}{
+		{name: "lightweight by default"},
+		{name: "annotated by config", config: "true", wantAnnotated: true},
+		{name: "lightweight overrides config", config: "true", args: []string{"--lightweight"}},
+		{name: "annotate flag", args: []string{"--annotate"}, wantAnnotated: true},
+		{name: "config off", config: "false"},
+		{name: "bad config", config: "sometimes", wantErr: "invalid value for git config release.annotate"},
+		{name: "both flags", args: []string{"--annotate", "--lightweight"}, wantErr: "mutually exclusive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			if tt.config != "" {
+				setGitConfig(t, repo, "release", "annotate", tt.config)
+			}
+			result := runRelease(t, dir, testTagger, tt.args...)
+			if tt.wantErr != "" {
+				if result.code == 0 || !strings.Contains(result.stderr, tt.wantErr) {
+					t.Fatalf("exit code %d, want an error containing %q:\n%s", result.code, tt.wantErr, result.stderr)
+				}
+				return
+			}
+			if result.code != 0 {
+				t.Fatalf("exit code %d:\n%s", result.code, result.stderr)
+			}
+			tag := period() + "001"
+			if got := isAnnotated(t, dir, tag); got != tt.wantAnnotated {
+				t.Errorf("%s annotated = %t, want %t", tag, got, tt.wantAnnotated)
+			}
+		})
+	}
+}
+
+func TestIncrementFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantTag string
+		wantErr string
+	}{
+		{name: "major", args: []string{"--semver", "--inc-major"}, wantTag: "2.0.0-1"},
+		{name: "minor", args: []string{"--semver", "--inc-minor"}, wantTag: "1.1.0-1"},
+		{name: "patch", args: []string{"--semver", "--inc-patch"}, wantTag: "1.0.1-1"},
+		{name: "rc with an increment", args: []string{"--semver", "--inc-minor", "--inc-rc"}, wantTag: "1.1.0-rc1"},
+		{name: "major and minor", args: []string{"--semver", "--inc-major", "--inc-minor"}, wantErr: "only one increment flag can be used at a time, got: --inc-major, --inc-minor"},
+		{name: "all three", args: []string{"--semver", "--inc-patch", "--inc-minor", "--inc-major"}, wantErr: "got: --inc-major, --inc-minor, --inc-patch"},
+		{name: "auto bump and increment", args: []string{"--semver", "--auto-bump", "--inc-patch"}, wantErr: "--auto-bump cannot be combined"},
+		{name: "without semver", args: []string{"--inc-minor"}, wantErr: "--inc-minor can only be used with --semver"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			tagHead(t, repo, "1.0.0-1")
+			commitFile(t, repo, "file.txt", "Fix the build")
+			result := runRelease(t, dir, testTagger, tt.args...)
+			if tt.wantErr != "" {
+				if result.code == 0 || !strings.Contains(result.stderr, tt.wantErr) {
+					t.Fatalf("exit code %d, want an error containing %q:\n%s", result.code, tt.wantErr, result.stderr)
+				}
+				if count := countTags(t, dir); count != 1 {
+					t.Errorf("%d tags after a rejected run, want only 1.0.0-1", count)
+				}
+				return
+			}
+			if result.code != 0 {
+				t.Fatalf("exit code %d:\n%s", result.code, result.stderr)
+			}
+			if _, err := repo.Tag(tt.wantTag); err != nil {
+				t.Errorf("tag %s wasn't created:\n%s%s", tt.wantTag, result.stdout, result.stderr)
+			}
+		})
+	}
+}
+
+func TestMaxComponents(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantTags int    // Releases created
+		wantOut  string // In the output
+	}{
+		{name: "under the cap", args: []string{"--max-components", "3", "api", "db", "web"}, wantTags: 3},
+		{name: "no cap", args: []string{"api", "db", "web"}, wantTags: 3},
+		{name: "over the cap", args: []string{"--max-components", "2", "api", "db", "web"}, wantCode: 1, wantOut: "refusing to release 3 components, the limit is 2"},
+		{name: "overridden", args: []string{"--max-components", "2", "--yes", "api", "db", "web"}, wantTags: 3, wantOut: "releasing 3 components, over the limit of 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, _ := newTestRepo(t)
+			result := runRelease(t, dir, testTagger, tt.args...)
+			output := result.stdout + result.stderr
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, output)
+			}
+			if !strings.Contains(output, tt.wantOut) {
+				t.Errorf("output doesn't contain %q:\n%s", tt.wantOut, output)
+			}
+			if count := countTags(t, dir); count != tt.wantTags {
+				t.Errorf("%d releases created, want %d:\n%s", count, tt.wantTags, output)
+			}
+		})
+	}
+}
+
+func TestAutoComponent(t *testing.T) {
+	tests := []struct {
+		name    string
+		cwd     string // Relative to the repository
+		args    []string
+		wantTag string
+		wantErr string
+	}{
+		{name: "component directory", cwd: "services/api/handlers", wantTag: period() + "001-api"},
+		{name: "outside of components", cwd: "docs", wantTag: period() + "001"},
+		{name: "with components", cwd: "services/api", args: []string{"web"}, wantErr: "--auto-component cannot be combined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			addComponentPath(t, repo, "api", "services/api")
+			cwd := filepath.Join(dir, tt.cwd)
+			if err := os.MkdirAll(cwd, 0755); err != nil {
+				t.Fatal(err)
+			}
+			result := runRelease(t, cwd, testTagger, append([]string{"--auto-component"}, tt.args...)...)
+			if tt.wantErr != "" {
+				if result.code == 0 || !strings.Contains(result.stderr, tt.wantErr) {
+					t.Fatalf("exit code %d, want an error containing %q:\n%s", result.code, tt.wantErr, result.stderr)
+				}
+				return
+			}
+			if result.code != 0 {
+				t.Fatalf("exit code %d:\n%s", result.code, result.stderr)
+			}
+			if _, err := repo.Tag(tt.wantTag); err != nil {
+				t.Errorf("tag %s wasn't created:\n%s%s", tt.wantTag, result.stdout, result.stderr)
+			}
+		})
+	}
+}
+
+func TestSummary(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	addComponentPath(t, repo, "api", "services/api")
+	addComponentPath(t, repo, "web", "services/web")
+	addComponentPath(t, repo, "docs", "docs")
+	tagHead(t, repo, "2024.06.001")
+	commitFile(t, repo, "services/api/main.go", "Fix the api")
+	commitFile(t, repo, "services/api/main.go", "Speed up the api")
+	commitFile(t, repo, "services/web/index.html", "Restyle the web")
+	tagHead(t, repo, "2024.06.002")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "all components",
+			args: []string{"--changed-since", "2024.06.001"},
+			want: "changes since 2024.06.001:\n" +
+				"COMPONENT  STATUS     COMMITS  PATHS\n" +
+				"api        changed    2        services/api\n" +
+				"docs       unchanged  0        docs\n" +
+				"web        changed    1        services/web\n",
+		},
+		{
+			name: "given components",
+			args: []string{"--changed-since", "2024.06.001", "docs", "web"},
+			want: "changes since 2024.06.001:\n" +
+				"COMPONENT  STATUS     COMMITS  PATHS\n" +
+				"docs       unchanged  0        docs\n" +
+				"web        changed    1        services/web\n",
+		},
+		{
+			// Releases of the whole repository aren't releases of web
+			name: "component never released",
+			args: []string{"web"},
+			want: "changes since each component's latest release:\n" +
+				"COMPONENT  SINCE             STATUS   COMMITS  PATHS\n" +
+				"web        (never released)  changed  1        services/web\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := mustRelease(t, dir, nil, append([]string{"--summary"}, tt.args...)...)
+			if result.stdout != tt.want {
+				t.Errorf("summary:\n%s\nwant:\n%s", result.stdout, tt.want)
+			}
+		})
+	}
+	if count := countTags(t, dir); count != 2 {
+		t.Errorf("%d tags after --summary, want it to create none", count)
+	}
+}
+
+func TestSummaryPerComponent(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	addComponentPath(t, repo, "api", "services/api")
+	addComponentPath(t, repo, "web", "services/web")
+	tagHead(t, repo, "2024.06.001-api")
+	commitFile(t, repo, "services/api/main.go", "Fix the api")
+	commitFile(t, repo, "services/web/index.html", "Restyle the web")
+	tagHead(t, repo, "2024.06.002-web")
+	commitFile(t, repo, "services/web/index.html", "Fix the layout")
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			// The api change is older than the latest (web) release but
+			// hasn't been released yet
+			name: "own latest release",
+			want: "changes since each component's latest release:\n" +
+				"COMPONENT  SINCE            STATUS   COMMITS  PATHS\n" +
+				"api        2024.06.001-api  changed  1        services/api\n" +
+				"web        2024.06.002-web  changed  1        services/web\n",
+		},
+		{
+			name: "common base",
+			args: []string{"--changed-since", "2024.06.002-web"},
+			want: "changes since 2024.06.002-web:\n" +
+				"COMPONENT  STATUS     COMMITS  PATHS\n" +
+				"api        unchanged  0        services/api\n" +
+				"web        changed    1        services/web\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := mustRelease(t, dir, nil, append([]string{"--summary"}, tt.args...)...)
+			if result.stdout != tt.want {
+				t.Errorf("summary:\n%s\nwant:\n%s", result.stdout, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemeFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantTag string
+		wantErr string
+	}{
+		{name: "inc-major without semver", args: []string{"--inc-major"}, wantErr: "--inc-major can only be used with --semver"},
+		{name: "inc-rc without semver", args: []string{"--inc-rc"}, wantErr: "--inc-rc can only be used with --semver"},
+		{name: "auto-bump without semver", args: []string{"--auto-bump"}, wantErr: "--auto-bump can only be used with --semver"},
+		{name: "fmt with semver", args: []string{"--semver", "--fmt", "%Y.%j."}, wantErr: "--fmt can only be used with date releases"},
+		{name: "date options with semver", args: []string{"--semver", "--tz", "UTC", "--inc-width", "4"}, wantErr: "--tz, --inc-width can only be used with date releases"},
+		{name: "number prefix with semver", args: []string{"--semver", "--number-prefix", "r"}, wantErr: "can only be used with date releases"},
+		{name: "semver with an increment", args: []string{"--semver", "--inc-major"}, wantTag: "1.0.0-1"},
+		{name: "date options", args: []string{"--tz", "UTC", "--inc-width", "4"}, wantTag: time.Now().UTC().Format("2006.01.") + "0001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			result := runRelease(t, dir, testTagger, tt.args...)
+			if tt.wantErr != "" {
+				if result.code == 0 || !strings.Contains(result.stderr, tt.wantErr) {
+					t.Fatalf("exit code %d, want an error containing %q:\n%s", result.code, tt.wantErr, result.stderr)
+				}
+				if count := countTags(t, dir); count != 0 {
+					t.Errorf("%d tags after a rejected run", count)
+				}
+				return
+			}
+			if result.code != 0 {
+				t.Fatalf("exit code %d:\n%s", result.code, result.stderr)
+			}
+			if _, err := repo.Tag(tt.wantTag); err != nil {
+				t.Errorf("tag %s wasn't created:\n%s%s", tt.wantTag, result.stdout, result.stderr)
+			}
+		})
+	}
+}
+
+func TestNotesFromTrailer(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{name: "notes", want: "- Export to CSV\n- Logging in works again\n"},
+		{name: "with other", args: []string{"--notes-include-other"}, want: "- Export to CSV\n- Logging in works again\n\nOther:\n- Refactor the handlers\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			tagHead(t, repo, "2024.06.001")
+			commitFile(t, repo, "file.txt", "Fix the login\n\nRelease-Note: Logging in works again")
+			commitFile(t, repo, "file.txt", "Refactor the handlers")
+			commitFile(t, repo, "file.txt", "Add exports\n\nRelease-Note: Export to CSV")
+			args := append([]string{"--annotate", "--changelog", "--notes-from-trailer", "Release-Note"}, tt.args...)
+			mustRelease(t, dir, testTagger, args...)
+			if got := messageOf(t, dir, period()+"001"); got != tt.want {
+				t.Errorf("tag message = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotesFromPRs(t *testing.T) {
+	tests := []struct {
+		name     string
+		remote   string
+		args     []string
+		env      []string
+		wantCode int
+		wantOut  string
+		wantErr  string
+	}{
+		{name: "pull requests", remote: "git@github.com:owner/repo.git", env: []string{"RELEASE_TOKEN=token"}, wantOut: "bug:\n- Fix logging in with SSO (#12)\n\nfeature:\n- Export to CSV (#15)\n"},
+		{name: "with other", remote: "git@github.com:owner/repo.git", args: []string{"--notes-include-other"}, env: []string{"RELEASE_TOKEN=token"}, wantOut: "bug:\n- Fix logging in with SSO (#12)\n\nfeature:\n- Export to CSV (#15)\n\nOther:\n- Bump the version\n"},
+		{name: "no token", remote: "git@github.com:owner/repo.git", wantOut: "- Add exports\n- Bump the version\n- Fix the login\n", wantErr: "needs --token or $RELEASE_TOKEN, building the changelog from commit subjects instead"},
+		{name: "not github", remote: "git@gitlab.com:owner/repo.git", env: []string{"RELEASE_TOKEN=token"}, wantOut: "- Add exports\n- Bump the version\n- Fix the login\n", wantErr: "isn't a GitHub repository"},
+		{name: "rejected token", remote: "git@github.com:owner/repo.git", env: []string{"RELEASE_TOKEN=bad"}, wantCode: 1, wantErr: "invalid or expired"},
+		{name: "with trailer", remote: "git@github.com:owner/repo.git", args: []string{"--notes-from-trailer", "Release-Note"}, wantCode: 1, wantErr: "can't be combined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			tagHead(t, repo, "2024.06.001")
+			login := commitFile(t, repo, "file.txt", "Fix the login")
+			commitFile(t, repo, "file.txt", "Bump the version")
+			export := commitFile(t, repo, "file.txt", "Add exports")
+			if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{tt.remote}}); err != nil {
+				t.Fatalf("failed to add the remote: %s", err)
+			}
+
+			pulls := map[string]string{
+				login.String():  `[{"number":12,"title":"Fix logging in with SSO","merged_at":"2024-06-02T12:00:00Z","labels":[{"name":"bug"}]}]`,
+				export.String(): `[{"number":15,"title":"Export to CSV","merged_at":"2024-06-03T12:00:00Z","labels":[{"name":"feature"}]}]`,
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.Header.Get("Authorization") != "Bearer token" {
+					w.WriteHeader(http.StatusUnauthorized)
+					fmt.Fprint(w, `{"message":"Bad credentials"}`)
+					return
+				}
+				sha := strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/repos/owner/repo/commits/"), "/pulls")
+				if body, ok := pulls[sha]; ok {
+					fmt.Fprint(w, body)
+					return
+				}
+				fmt.Fprint(w, "[]")
+			}))
+			t.Cleanup(server.Close)
+
+			env := append([]string{"GITHUB_API_URL=" + server.URL}, tt.env...)
+			result := runRelease(t, dir, env, append([]string{"--print-changelog", "--notes-from-prs"}, tt.args...)...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, result.stderr)
+			}
+			if tt.wantOut != "" && result.stdout != tt.wantOut {
+				t.Errorf("stdout = %q, want %q", result.stdout, tt.wantOut)
+			}
+			if !strings.Contains(result.stderr, tt.wantErr) {
+				t.Errorf("stderr doesn't contain %q:\n%s", tt.wantErr, result.stderr)
+			}
+		})
+	}
+}
+
+func TestDeleteMatch(t *testing.T) {
+	tags := []string{"2023.01.001", "2023.12.004-api", "2024.01.001", "2023-notes"}
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+		wantLeft int // Tags left afterwards
+	}{
+		{name: "dry run", args: []string{"--match", "2023.*", "--dry-run"}, wantOut: "2 tag(s) to delete:\n 2023.01.001\n 2023.12.004-api\n", wantLeft: 4},
+		{name: "with arguments", args: []string{"--match", "2023.12.*", "--dry-run", "2024.01.001"}, wantOut: "2 tag(s) to delete:\n 2024.01.001\n 2023.12.004-api\n", wantLeft: 4},
+		{name: "deleted", args: []string{"--match", "2023.*", "--yes"}, wantOut: "deleted tag 2023.01.001", wantLeft: 2},
+		{name: "unrelated tags", args: []string{"--match", "2023*", "--dry-run"}, wantCode: 1, wantOut: "refusing to delete 2023-notes", wantLeft: 4},
+		{name: "unrelated tags forced", args: []string{"--match", "2023*", "--force", "--dry-run"}, wantOut: "3 tag(s) to delete", wantLeft: 4},
+		{name: "no match", args: []string{"--match", "2022.*", "--dry-run"}, wantCode: 1, wantOut: "no tags to delete", wantLeft: 4},
+		{name: "no confirmation", args: []string{"--match", "2023.*"}, wantCode: 1, wantOut: "refusing to delete tags without confirmation", wantLeft: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			for _, tag := range tags {
+				tagHead(t, repo, tag)
+			}
+			result := runRelease(t, dir, nil, append([]string{"--delete"}, tt.args...)...)
+			output := result.stdout + result.stderr
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, output)
+			}
+			if !strings.Contains(output, tt.wantOut) {
+				t.Errorf("output doesn't contain %q:\n%s", tt.wantOut, output)
+			}
+			if left := countTags(t, dir); left != tt.wantLeft {
+				t.Errorf("%d tags left, want %d", left, tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagHead(t, repo, "2024.06.001")
+	commitFile(t, repo, "file.txt", "Released, then force-pushed away")
+	tagHead(t, repo, "2024.06.002")
+	if err := w.Reset(&git.ResetOptions{Commit: base.Hash(), Mode: git.HardReset}); err != nil {
+		t.Fatal(err)
+	}
+
+	result := mustRelease(t, dir, nil, "--check", "2024.06.001")
+	if !strings.Contains(result.stdout, "reachable:   master\n") {
+		t.Errorf("2024.06.001 isn't reported reachable:\n%s", result.stdout)
+	}
+	result = runRelease(t, dir, nil, "--check", "2024.06.002")
+	if result.code != 1 || !strings.Contains(result.stdout, "ORPHANED: the commit tag 2024.06.002 points at is not reachable from any branch") {
+		t.Errorf("exit code %d, want 2024.06.002 reported orphaned:\n%s%s", result.code, result.stdout, result.stderr)
+	}
+}
+
+func TestIncludeDiffstat(t *testing.T) {
+	tests := []struct {
+		name     string
+		released bool // Whether there is a previous release
+		args     []string
+		want     string
+	}{
+		{name: "first release", args: []string{"--annotate", "--include-diffstat"}, want: "2 files changed, 3 insertions(+)\n"},
+		{name: "since the previous release", released: true, args: []string{"--annotate", "--include-diffstat"}, want: "1 file changed, 2 insertions(+)\n"},
+		{name: "after the changelog", released: true, args: []string{"--annotate", "--changelog", "--include-diffstat"}, want: "- Fix the build again\n- Fix the build\n\n1 file changed, 2 insertions(+)\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			if tt.released {
+				tagHead(t, repo, "2024.06.001")
+			}
+			commitFile(t, repo, "file.txt", "Fix the build")
+			commitFile(t, repo, "file.txt", "Fix the build again")
+			mustRelease(t, dir, testTagger, tt.args...)
+			if got := messageOf(t, dir, period()+"001"); got != tt.want {
+				t.Errorf("tag message = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOnEmptyChangelog(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        string // Empty for the default
+		wantCode      int
+		wantOut       string
+		wantAnnotated bool
+		wantMessage   string
+	}{
+		{name: "default", wantAnnotated: true, wantMessage: "No changes since 2024.06.001\n"},
+		{name: "note", policy: "note", wantAnnotated: true, wantMessage: "No changes since 2024.06.001\n"},
+		{name: "warn", policy: "warn", wantOut: "no commits found for the changelog since '2024.06.001', tag will not be annotated"},
+		{name: "block", policy: "block", wantCode: 1, wantOut: "refusing to release (--on-empty-changelog block)"},
+		{name: "invalid", policy: "skip", wantCode: 1, wantOut: "invalid --on-empty-changelog 'skip'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			tagHead(t, repo, "2024.06.001")
+			// HEAD is already released, the changelog since then is empty
+			args := []string{"--changelog", "--allow-duplicate"}
+			if tt.policy != "" {
+				args = append(args, "--on-empty-changelog", tt.policy)
+			}
+			result := runRelease(t, dir, testTagger, args...)
+			output := result.stdout + result.stderr
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, output)
+			}
+			if !strings.Contains(output, tt.wantOut) {
+				t.Errorf("output doesn't contain %q:\n%s", tt.wantOut, output)
+			}
+			tag := period() + "001"
+			if tt.wantCode != 0 {
+				if count := countTags(t, dir); count != 1 {
+					t.Errorf("%d tags, want %s not to be created", count, tag)
+				}
+				return
+			}
+			if got := isAnnotated(t, dir, tag); got != tt.wantAnnotated {
+				t.Fatalf("%s annotated = %t, want %t", tag, got, tt.wantAnnotated)
+			}
+			if tt.wantAnnotated {
+				if got := messageOf(t, dir, tag); got != tt.wantMessage {
+					t.Errorf("tag message = %q, want %q", got, tt.wantMessage)
+				}
+			}
+		})
+	}
+}
+
+func TestIncludeTreeHash(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	segment := "t" + commit.TreeHash.String()[:7]
+	mustRelease(t, dir, nil, "--include-tree-hash", "", "api")
+	for _, tag := range []string{period() + "001-" + segment, period() + "001-" + segment + "-api"} {
+		if _, err := repo.Tag(tag); err != nil {
+			t.Errorf("tag %s wasn't created: %s", tag, err)
+		}
+	}
+}
+
+func TestUnknownComponents(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+		wantTags int
+	}{
+		{name: "known", args: []string{"api", "web"}, wantTags: 2},
+		{name: "typo", args: []string{"api", "apii"}, wantCode: 1, wantOut: "unknown component(s): apii, known components are: api, web (pass --allow-unknown-components"},
+		{name: "allowed", args: []string{"--allow-unknown-components", "api", "apii"}, wantTags: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			addComponentPath(t, repo, "api", "services/api")
+			addComponentPath(t, repo, "web", "services/web")
+			result := runRelease(t, dir, nil, tt.args...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, result.stderr)
+			}
+			if !strings.Contains(result.stderr, tt.wantOut) {
+				t.Errorf("stderr doesn't contain %q:\n%s", tt.wantOut, result.stderr)
+			}
+			// Nothing is released if any component is unknown
+			if count := countTags(t, dir); count != tt.wantTags {
+				t.Errorf("%d tags created, want %d", count, tt.wantTags)
+			}
+		})
+	}
+}
+
+func TestPrintChangelog(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+		wantErr  string
+	}{
+		{name: "historical window", args: []string{"--print-changelog", "--since", "1.0.0", "--until", "1.1.0"}, wantOut: "- Document the feature\n- Add the feature\n"},
+		{name: "since the latest", args: []string{"--print-changelog"}, wantOut: "- Unreleased work\n"},
+		{name: "reversed", args: []string{"--print-changelog", "--since", "1.1.0", "--until", "1.0.0"}, wantCode: 1, wantErr: "1.1.0 is after 1.0.0"},
+		{name: "unknown until", args: []string{"--print-changelog", "--since", "1.0.0", "--until", "9.9.9"}, wantCode: 1, wantErr: "invalid changelog range"},
+		{name: "until without print", args: []string{"--changelog", "--until", "1.1.0"}, wantCode: 1, wantErr: "--until can only be used with --print-changelog"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			tagHead(t, repo, "1.0.0")
+			commitFile(t, repo, "file.txt", "Add the feature")
+			commitFile(t, repo, "file.txt", "Document the feature")
+			tagHead(t, repo, "1.1.0")
+			commitFile(t, repo, "file.txt", "Unreleased work")
+			result := runRelease(t, dir, nil, append([]string{"--semver"}, tt.args...)...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, result.stderr)
+			}
+			if tt.wantOut != "" && result.stdout != tt.wantOut {
+				t.Errorf("stdout = %q, want %q", result.stdout, tt.wantOut)
+			}
+			if !strings.Contains(result.stderr, tt.wantErr) {
+				t.Errorf("stderr doesn't contain %q:\n%s", tt.wantErr, result.stderr)
+			}
+			// Printing the changelog never creates a release
+			if count := countTags(t, dir); count != 2 {
+				t.Errorf("%d tags, want the 2 existing ones", count)
+			}
+		})
+	}
+}
+
+func TestComponentChangelog(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantOut string
+	}{
+		{name: "api", args: []string{"api"}, wantOut: "- Fix the client\n- Add an endpoint\n"},
+		{name: "web", args: []string{"web"}, wantOut: "- Fix the client\n- Restyle the page\n"},
+		{name: "both", args: []string{"api", "web"}, wantOut: "api:\n- Fix the client\n- Add an endpoint\nweb:\n- Fix the client\n- Restyle the page\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			addComponentPath(t, repo, "api", "services/api")
+			addComponentPath(t, repo, "web", "web")
+			tagHead(t, repo, period()+"001-api")
+			tagHead(t, repo, period()+"001-web")
+			commitFile(t, repo, "services/api/main.go", "Add an endpoint")
+			commitFile(t, repo, "web/app.js", "Restyle the page")
+			// Touches both components
+			if err := os.WriteFile(filepath.Join(dir, "web", "client.js"), []byte("client\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			w, err := repo.Worktree()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Add("web/client.js"); err != nil {
+				t.Fatal(err)
+			}
+			commitFile(t, repo, "services/api/client.go", "Fix the client")
+			commitFile(t, repo, "README.md", "Document the setup")
+
+			result := mustRelease(t, dir, nil, append([]string{"--print-changelog"}, tt.args...)...)
+			if result.stdout != tt.wantOut {
+				t.Errorf("stdout = %q, want %q", result.stdout, tt.wantOut)
+			}
+		})
+	}
+
+	t.Run("tag message", func(t *testing.T) {
+		dir, repo := newTestRepo(t)
+		addComponentPath(t, repo, "api", "services/api")
+		addComponentPath(t, repo, "web", "web")
+		tagHead(t, repo, period()+"001-api")
+		commitFile(t, repo, "web/app.js", "Restyle the page")
+		commitFile(t, repo, "services/api/main.go", "Add an endpoint")
+		mustRelease(t, dir, testTagger, "--annotate", "--changelog", "api")
+		if got, want := messageOf(t, dir, period()+"002-api"), "- Add an endpoint\n"; got != want {
+			t.Errorf("message = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestAllowWidthOverflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		latest   string
+		args     []string
+		wantCode int
+		wantTag  string
+		wantErr  string
+	}{
+		{name: "last that fits", latest: "998", wantTag: "999"},
+		{name: "overflow", latest: "999", wantCode: 1, wantErr: "the next release number 1000 doesn't fit in 3 digits, increase --inc-width or pass --allow-width-overflow"},
+		{name: "allowed", latest: "999", args: []string{"--allow-width-overflow"}, wantTag: "1000"},
+		{name: "wider", latest: "999", args: []string{"--inc-width", "4"}, wantTag: "1000"},
+		{name: "wider overflow", latest: "9999", args: []string{"--inc-width", "4"}, wantCode: 1, wantErr: "doesn't fit in 4 digits"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			tagHead(t, repo, period()+tt.latest)
+			commitFile(t, repo, "file.txt", "Fix the build")
+			result := runRelease(t, dir, nil, tt.args...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, result.stderr)
+			}
+			if !strings.Contains(result.stderr, tt.wantErr) {
+				t.Errorf("stderr doesn't contain %q:\n%s", tt.wantErr, result.stderr)
+			}
+			if tt.wantTag == "" {
+				if count := countTags(t, dir); count != 1 {
+					t.Errorf("%d tags after the overflow, want only the existing one", count)
+				}
+				return
+			}
+			if _, err := repo.Tag(period() + tt.wantTag); err != nil {
+				t.Errorf("%s wasn't created: %s", period()+tt.wantTag, err)
+			}
+		})
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	future := fmt.Sprintf("%d.01.001", time.Now().Year()+1)
+	tests := []struct {
+		name     string
+		existing string
+		args     []string
+		wantCode int
+		wantErr  string
+		wantTag  bool
+	}{
+		{name: "no skew", existing: period() + "004", wantTag: true},
+		{name: "future release", existing: future, wantErr: "release " + future + " is dated after today, is the clock wrong? the new release will sort before it", wantTag: true},
+		{name: "fail on skew", existing: future, args: []string{"--fail-on-clock-skew"}, wantCode: 1, wantErr: "refusing to create an out of order release"},
+		{name: "fail without skew", existing: period() + "004", args: []string{"--fail-on-clock-skew"}, wantTag: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			tagHead(t, repo, tt.existing)
+			commitFile(t, repo, "file.txt", "Fix the build")
+			result := runRelease(t, dir, nil, tt.args...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, result.stderr)
+			}
+			if tt.wantErr != "" && !strings.Contains(result.stderr, tt.wantErr) {
+				t.Errorf("stderr doesn't contain %q:\n%s", tt.wantErr, result.stderr)
+			}
+			if tt.wantErr == "" && strings.Contains(result.stderr, "dated after today") {
+				t.Errorf("warned about clock skew without a future release:\n%s", result.stderr)
+			}
+			want := 1
+			if tt.wantTag {
+				want = 2
+			}
+			if count := countTags(t, dir); count != want {
+				t.Errorf("%d tags, want %d", count, want)
+			}
+		})
+	}
+}
+
+func TestNightly(t *testing.T) {
+	today := "nightly-" + time.Now().UTC().Format("2006.01.02")
+	tests := []struct {
+		name         string
+		existing     bool // Today's nightly was already released from the first commit
+		args         []string
+		wantCode     int
+		wantOutput   string
+		wantFloating bool
+	}{
+		{name: "first of the day", wantOutput: "created release: " + today},
+		{name: "retried", existing: true, wantOutput: "nightly release " + today + " already exists, skipping"},
+		{name: "component", args: []string{"api"}, wantOutput: "created release: " + today + "-api"},
+		{name: "floating", args: []string{"--nightly-floating"}, wantOutput: "moved floating tag nightly to " + today, wantFloating: true},
+		{name: "floating retried", existing: true, args: []string{"--nightly-floating"}, wantOutput: "moved floating tag nightly to " + today, wantFloating: true},
+		{name: "floating without nightly", args: []string{"--nightly=false", "--nightly-floating"}, wantCode: 1, wantOutput: "--nightly-floating can only be used with --nightly"},
+		{name: "tree hash", args: []string{"--include-tree-hash"}, wantCode: 1, wantOutput: "--nightly cannot be combined with --semver, --include-tree-hash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			first, err := repo.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.existing {
+				mustRelease(t, dir, testTagger, "--nightly", "--tz", "UTC")
+			}
+			// The floating tag follows the previous nightly until moved
+			tagHead(t, repo, "nightly")
+			head := commitFile(t, repo, "file.txt", "Fix the build")
+
+			result := runRelease(t, dir, testTagger, append([]string{"--nightly", "--tz", "UTC"}, tt.args...)...)
+			output := result.stdout + result.stderr
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, output)
+			}
+			if !strings.Contains(output, tt.wantOutput) {
+				t.Errorf("output doesn't contain %q:\n%s", tt.wantOutput, output)
+			}
+			if tt.wantCode != 0 {
+				return
+			}
+			nightly := today
+			if len(tt.args) > 0 && tt.args[0] == "api" {
+				nightly += "-api"
+			}
+			// A retry leaves today's nightly where it was
+			want := head
+			if tt.existing {
+				want = first.Hash()
+			}
+			if got := commitOf(t, dir, nightly); got != want {
+				t.Errorf("%s points at %s, want %s", nightly, got, want)
+			}
+			wantFloating := first.Hash()
+			if tt.wantFloating {
+				wantFloating = want
+			}
+			if got := commitOf(t, dir, "nightly"); got != wantFloating {
+				t.Errorf("nightly points at %s, want %s", got, wantFloating)
+			}
+		})
+	}
+}
+
+func TestNightlyFloatingPush(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	remote := addTestRemote(t, repo, "origin")
+	tagHead(t, repo, "nightly")
+	pushTags(t, repo, "origin")
+	head := commitFile(t, repo, "file.txt", "Fix the build")
+
+	result := mustRelease(t, dir, testTagger, "--nightly", "--nightly-floating", "--push")
+	if !strings.Contains(result.stdout, "moved floating tag nightly in remote origin") {
+		t.Errorf("floating tag wasn't pushed:\n%s", result.stdout)
+	}
+	ref, err := remote.Tag("nightly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref.Hash() != head {
+		t.Errorf("remote nightly points at %s, want %s", ref.Hash(), head)
+	}
+}
+
+func TestMinCommits(t *testing.T) {
+	tests := []struct {
+		name     string
+		commits  int
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{name: "below", commits: 1, args: []string{"--min-commits", "2"}, wantCode: 1, wantOut: "only 1 commit(s) since " + period() + "001, at least 2 are needed (--min-commits), pass --force to release anyway"},
+		{name: "at the threshold", commits: 2, args: []string{"--min-commits", "2"}},
+		{name: "above", commits: 3, args: []string{"--min-commits", "2"}},
+		{name: "nothing to release", args: []string{"--min-commits", "1"}, wantCode: 1, wantOut: "nothing to release, there are no commits since " + period() + "001, pass --force"},
+		{name: "forced", commits: 1, args: []string{"--min-commits", "2", "--force"}, wantOut: "releasing anyway because --force was given"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			tagHead(t, repo, period()+"001")
+			for i := 0; i < tt.commits; i++ {
+				commitFile(t, repo, "file.txt", fmt.Sprintf("Change %d", i))
+			}
+			result := runRelease(t, dir, nil, tt.args...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, result.stderr)
+			}
+			if output := result.stdout + result.stderr; !strings.Contains(output, tt.wantOut) {
+				t.Errorf("output doesn't contain %q:\n%s", tt.wantOut, output)
+			}
+			wantTags := 2
+			if tt.wantCode != 0 {
+				wantTags = 1
+			}
+			if count := countTags(t, dir); count != wantTags {
+				t.Errorf("%d tags, want %d", count, wantTags)
+			}
+		})
+	}
+}
+
+func TestMinCommitsPerComponent(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+		wantTag  string
+	}{
+		// web was released after api, counting from it would block api
+		{name: "since its own release", args: []string{"--min-commits", "3", "api"}, wantTag: period() + "003-api"},
+		{name: "component released later", args: []string{"--min-commits", "2", "web"}, wantCode: 1, wantOut: "only 1 commit(s) since " + period() + "002-web, at least 2 are needed"},
+		{name: "either component short", args: []string{"--min-commits", "2", "api", "web"}, wantCode: 1, wantOut: "since " + period() + "002-web"},
+		{name: "never released", args: []string{"--min-commits", "5", "docs"}, wantTag: period() + "003-docs"},
+		{name: "never released short", args: []string{"--min-commits", "6", "docs"}, wantCode: 1, wantOut: "only 5 commit(s) since the start of history"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			addComponentPath(t, repo, "api", "api")
+			addComponentPath(t, repo, "web", "web")
+			addComponentPath(t, repo, "docs", "docs")
+			commitFile(t, repo, "api/main.go", "Add the api")
+			tagHead(t, repo, period()+"001-api")
+			commitFile(t, repo, "api/main.go", "Fix the api")
+			commitFile(t, repo, "web/app.js", "Add the page")
+			tagHead(t, repo, period()+"002-web")
+			commitFile(t, repo, "api/main.go", "Speed up the api")
+
+			result := runRelease(t, dir, nil, tt.args...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, result.stderr)
+			}
+			if output := result.stdout + result.stderr; !strings.Contains(output, tt.wantOut) {
+				t.Errorf("output doesn't contain %q:\n%s", tt.wantOut, output)
+			}
+			if tt.wantTag == "" {
+				if count := countTags(t, dir); count != 2 {
+					t.Errorf("%d tags, want none created", count)
+				}
+			} else if _, err := repo.Tag(tt.wantTag); err != nil {
+				t.Errorf("%s wasn't created: %s", tt.wantTag, err)
+			}
+		})
+	}
+}
+
+func TestCounter(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	steps := []struct {
+		args    []string
+		wantTag string
+	}{
+		{args: []string{"api"}, wantTag: "api-0001"},
+		{args: []string{"api"}, wantTag: "api-0002"},
+		{args: []string{"web"}, wantTag: "web-0001"},
+		{args: []string{"api", "web"}, wantTag: "api-0003"},
+		{args: []string{"--counter-width", "2", "--counter-separator", "_", "web"}, wantTag: "web_01"},
+	}
+	for idx, step := range steps {
+		commitFile(t, repo, "file.txt", fmt.Sprintf("Change %d", idx))
+		result := mustRelease(t, dir, nil, append([]string{"--counter"}, step.args...)...)
+		if !strings.Contains(result.stdout, "created release: "+step.wantTag) {
+			t.Errorf("release %s didn't create %s:\n%s", strings.Join(step.args, " "), step.wantTag, result.stdout)
+		}
+	}
+	// Each component of a multi-component release advances its own counter
+	if _, err := repo.Tag("web-0002"); err != nil {
+		t.Errorf("web-0002 wasn't created with api-0003: %s", err)
+	}
+
+	result := runRelease(t, dir, nil, "--counter")
+	if result.code == 0 || !strings.Contains(result.stderr, "the counter scheme needs a component") {
+		t.Errorf("exit code %d, want an error for no component:\n%s", result.code, result.stderr)
+	}
+}
+
+func TestParseVersionString(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", output: "release 2024.06.001\n", want: "2024.06.001"},
+		{name: "build info", output: "release 2024.06.001 (abc1234, 2024-06-01T12:00:00Z)\n", want: "2024.06.001"},
+		{name: "other program", output: "api v1.2.0", want: "v1.2.0"},
+		{name: "more lines", output: "\nrelease 1.2.0\ncopyright someone\n", want: "1.2.0"},
+		{name: "bare version", output: "1.2.0\n", wantErr: true},
+		{name: "empty", output: "", wantErr: true},
+		{name: "free text", output: "release version 1.2.0 built today", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseVersionString(tt.output)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseVersionString(%q) error = %v, want error %t", tt.output, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseVersionString(%q) = %s, want %s", tt.output, got, tt.want)
+			}
+		})
+	}
+
+	// Whatever getVersionString prints must parse back
+	defer func(v, c, d string) { version, commit, buildDate = v, c, d }(version, commit, buildDate)
+	for _, info := range [][2]string{{"", ""}, {"abc1234", ""}, {"abc1234", "2024-06-01T12:00:00Z"}} {
+		version, commit, buildDate = "2024.06.001", info[0], info[1]
+		if got, err := parseVersionString(getVersionString()); err != nil || got != version {
+			t.Errorf("parseVersionString(%q) = %s, %v, want %s", getVersionString(), got, err, version)
+		}
+	}
+}
+
+// writeStubBinary writes a shell script that prints output for --version
+// and exits with code
+func writeStubBinary(t *testing.T, output string, code int) string {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh to run the stub binary with")
+	}
+	path := filepath.Join(t.TempDir(), "stub")
+	script := fmt.Sprintf("#!/bin/sh\n[ \"$1\" = --version ] || exit 2\nprintf '%%s\\n' '%s'\nexit %d\n", output, code)
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckBinary(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		code     int
+		args     []string
+		wantCode int
+		wantOut  string
+	}{
+		{name: "matching", output: "release 2024.06.001 (abc1234)", args: []string{"--expect", "2024.06.001"}, wantOut: "reports version 2024.06.001 as expected"},
+		{name: "mismatching", output: "release 2024.06.002", args: []string{"--expect", "2024.06.001"}, wantCode: 1, wantOut: "reports version 2024.06.002 but 2024.06.001 was expected"},
+		{name: "unparseable", output: "built from a dirty tree", args: []string{"--expect", "2024.06.001"}, wantCode: 1, wantOut: "unexpected --version output 'built from a dirty tree'"},
+		{name: "failing", output: "release 2024.06.001", code: 3, args: []string{"--expect", "2024.06.001"}, wantCode: 1, wantOut: "--version: exit status 3"},
+		{name: "no expect", output: "release 2024.06.001", wantCode: 1, wantOut: "--check-binary needs the expected release tag, pass --expect"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := writeStubBinary(t, tt.output, tt.code)
+			// It doesn't need a repository
+			result := runRelease(t, t.TempDir(), nil, append([]string{"--check-binary", stub}, tt.args...)...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s%s", result.code, tt.wantCode, result.stdout, result.stderr)
+			}
+			if output := result.stdout + result.stderr; !strings.Contains(output, tt.wantOut) {
+				t.Errorf("output doesn't contain %q:\n%s", tt.wantOut, output)
+			}
+		})
+	}
+}
+
+func TestBranchSegment(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantTag string
+	}{
+		{name: "slash kept", wantTag: "feature/x-1.2.0-2"},
+		{name: "dashes", args: []string{"--branch-slash", "-"}, wantTag: "feature-x-1.2.0-2"},
+		{name: "dots", args: []string{"--branch-slash", "."}, wantTag: "feature.x-1.2.0-2"},
+		{name: "separator", args: []string{"--branch-slash", "-", "--branch-separator", "+"}, wantTag: "feature-x+1.2.0-2"},
+		{name: "stable", args: []string{"--stable-branch", "feature/x"}, wantTag: "1.2.0-2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			tagHead(t, repo, "1.2.0-1")
+			w, err := repo.Worktree()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature/x"), Create: true}); err != nil {
+				t.Fatal(err)
+			}
+			commitFile(t, repo, "file.txt", "Work on the feature")
+			result := mustRelease(t, dir, nil, append([]string{"--semver"}, tt.args...)...)
+			if !strings.Contains(result.stdout, "created release: "+tt.wantTag+"\n") {
+				t.Errorf("output doesn't mention %s:\n%s", tt.wantTag, result.stdout)
+			}
+			if _, err := repo.Tag(tt.wantTag); err != nil {
+				t.Errorf("%s wasn't created: %s", tt.wantTag, err)
+			}
+		})
+	}
+}
+
+func TestGetVersionString(t *testing.T) {
+	defer func(v, c, d string) { version, commit, buildDate = v, c, d }(version, commit, buildDate)
+	tests := []struct {
+		version, commit, buildDate string
+		want                       string
+	}{
+		{version: "dev", want: "release dev"},
+		{version: "v1.2.3", commit: "abc1234", want: "release v1.2.3 (abc1234)"},
+		{version: "v1.2.3", buildDate: "2024-05-21", want: "release v1.2.3 (2024-05-21)"},
+		{version: "v1.2.3", commit: "abc1234", buildDate: "2024-05-21", want: "release v1.2.3 (abc1234, 2024-05-21)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			version, commit, buildDate = tt.version, tt.commit, tt.buildDate
+			if got := getVersionString(); got != tt.want {
+				t.Errorf("getVersionString() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVersionFlag(t *testing.T) {
+	result := mustRelease(t, t.TempDir(), nil, "--version")
+	if result.stderr != "release dev\n" {
+		t.Errorf("--version = %q, want release dev", result.stderr)
+	}
+	result = mustRelease(t, t.TempDir(), nil, "--version", "--verbose")
+	if want := fmt.Sprintf("built with %s for %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH); !strings.HasSuffix(result.stderr, want) {
+		t.Errorf("--version --verbose = %q, want it to end with %q", result.stderr, want)
+	}
+}
+
+func TestVersionLdflags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the command")
+	}
+	binary := filepath.Join(t.TempDir(), "release")
+	ldflags := "-X main.version=v1.2.3 -X main.commit=abc1234 -X main.buildDate=2024-05-21"
+	if output, err := exec.Command("go", "build", "-ldflags", ldflags, "-o", binary, ".").CombinedOutput(); err != nil {
+		t.Fatalf("failed to build: %s\n%s", err, output)
+	}
+	output, err := exec.Command(binary, "--version").CombinedOutput()
+	if err != nil {
+		t.Fatalf("--version failed: %s\n%s", err, output)
+	}
+	if got, want := string(output), "release v1.2.3 (abc1234, 2024-05-21)\n"; got != want {
+		t.Errorf("--version = %q, want %q", got, want)
+	}
+	// What --check-binary reads back
+	if found, err := checkBinaryVersion(binary, "v1.2.3"); err != nil {
+		t.Errorf("checkBinaryVersion() = %s, %v", found, err)
+	}
+}
+
+func TestIfChanged(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+		wantTags int
+	}{
+		{name: "changed", args: []string{"--if-changed", "schema/api.yaml"}, wantOut: "created release: " + period() + "002", wantTags: 2},
+		{name: "any of several", args: []string{"--if-changed", "docs", "--if-changed", "schema"}, wantOut: "created release: " + period() + "002", wantTags: 2},
+		{name: "unchanged", args: []string{"--if-changed", "docs"}, wantOut: "nothing to release, none of docs changed since " + period() + "001", wantTags: 1},
+		{name: "unchanged exit code", args: []string{"--if-changed", "docs", "--if-changed", "schema/events.yaml", "--empty-exit-code", "78"}, wantCode: 78, wantOut: "none of docs, schema/events.yaml changed", wantTags: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			commitFile(t, repo, "docs/guide.md", "Write the guide")
+			tagHead(t, repo, period()+"001")
+			commitFile(t, repo, "schema/api.yaml", "Add an endpoint")
+			result := runRelease(t, dir, nil, tt.args...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, result.stderr)
+			}
+			if !strings.Contains(result.stdout, tt.wantOut) {
+				t.Errorf("output doesn't contain %q:\n%s", tt.wantOut, result.stdout)
+			}
+			if count := countTags(t, dir); count != tt.wantTags {
+				t.Errorf("%d tags, want %d", count, tt.wantTags)
+			}
+		})
+	}
+}
+
+func TestIfChangedPerComponent(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantOut  string
+		wantTags []string // Created
+	}{
+		// web was released after the schema changed, api before
+		{name: "changed since its own release", args: []string{"--if-changed", "schema", "api"}, wantTags: []string{period() + "003-api"}},
+		{name: "unchanged since its own release", args: []string{"--if-changed", "schema", "web"}, wantOut: "nothing to release, none of schema changed since " + period() + "002-web"},
+		{name: "only the changed ones", args: []string{"--if-changed", "schema", "api", "web"}, wantOut: "not releasing web, none of schema changed since " + period() + "002-web", wantTags: []string{period() + "003-api"}},
+		{name: "none changed", args: []string{"--if-changed", "docs", "api", "web", "--empty-exit-code", "78"}, wantCode: 78, wantOut: "nothing to release, none of the components changed"},
+		{name: "never released", args: []string{"--if-changed", "docs", "cli"}, wantTags: []string{period() + "003-cli"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			addComponentPath(t, repo, "api", "api")
+			addComponentPath(t, repo, "web", "web")
+			addComponentPath(t, repo, "cli", "cli")
+			commitFile(t, repo, "docs/guide.md", "Write the guide")
+			tagHead(t, repo, period()+"001-api")
+			commitFile(t, repo, "schema/api.yaml", "Add an endpoint")
+			tagHead(t, repo, period()+"002-web")
+
+			result := runRelease(t, dir, nil, tt.args...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, result.stderr)
+			}
+			if !strings.Contains(result.stdout, tt.wantOut) {
+				t.Errorf("output doesn't contain %q:\n%s", tt.wantOut, result.stdout)
+			}
+			if count := countTags(t, dir); count != 2+len(tt.wantTags) {
+				t.Errorf("%d tags, want %d created", count-2, len(tt.wantTags))
+			}
+			for _, tag := range tt.wantTags {
+				if _, err := repo.Tag(tag); err != nil {
+					t.Errorf("%s wasn't created: %s", tag, err)
+				}
+			}
+		})
+	}
+}
+
+func TestListVerify(t *testing.T) {
+	secret, public := writeTestKeys(t)
+	dir, repo := newTestRepo(t)
+	mustRelease(t, dir, testTagger, "--sign", "--signing-key", secret)
+	commitFile(t, repo, "file.txt", "Fix the build")
+	mustRelease(t, dir, testTagger, "--sign", "--signing-key", secret)
+	commitFile(t, repo, "file.txt", "Fix the tests")
+	mustRelease(t, dir, testTagger, "--annotate")
+	commitFile(t, repo, "file.txt", "Fix the docs")
+	tagHead(t, repo, period()+"004")
+
+	// Rewrite the message of the second release, keeping its signature
+	ref, err := repo.Tag(period() + "002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := repo.TagObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag.Message = "Not what was signed\n"
+	obj := repo.Storer.NewEncodedObject()
+	if err := tag.Encode(obj); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(ref.Name(), hash)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		period() + "001": "valid",
+		period() + "002": "invalid",
+		period() + "003": "unsigned",
+		period() + "004": "unsigned",
+	}
+	result := mustRelease(t, dir, nil, "--list", "--verify", "--keyring", public)
+	lines := strings.Split(strings.TrimSpace(result.stdout), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "TAG") || !strings.Contains(lines[0], "SIGNATURE") {
+		t.Fatalf("--list --verify output isn't a table of the 4 releases:\n%s", result.stdout)
+	}
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || want[fields[0]] != fields[1] {
+			t.Errorf("row %q, want status %s", line, want[fields[0]])
+		}
+		if fields[1] == "valid" && !strings.Contains(line, "Test Tagger <tagger@example.com>") {
+			t.Errorf("valid row %q doesn't name the signer", line)
+		}
+	}
+
+	result = mustRelease(t, dir, nil, "--list", "--verify", "--json", "--keyring", public)
+	var releases []struct {
+		Tag       string `json:"tag"`
+		Signature struct {
+			Status string `json:"status"`
+			Signer string `json:"signer"`
+		} `json:"signature"`
+	}
+	if err := json.Unmarshal([]byte(result.stdout), &releases); err != nil {
+		t.Fatalf("--json output isn't JSON: %s\n%s", err, result.stdout)
+	}
+	if len(releases) != len(want) {
+		t.Fatalf("--json listed %d releases, want %d", len(releases), len(want))
+	}
+	for _, rel := range releases {
+		if rel.Signature.Status != want[rel.Tag] {
+			t.Errorf("%s has status %s, want %s", rel.Tag, rel.Signature.Status, want[rel.Tag])
+		}
+		// Only signed tags have a signer, tampered or not
+		if signed := rel.Signature.Status != "unsigned"; signed != (rel.Signature.Signer != "") {
+			t.Errorf("%s has signer %q with status %s", rel.Tag, rel.Signature.Signer, rel.Signature.Status)
+		}
+	}
+
+	result = runRelease(t, dir, nil, "--list", "--verify")
+	if result.code == 0 || !strings.Contains(result.stderr, "--verify needs the public keys to verify against, pass --keyring") {
+		t.Errorf("exit code %d, want an error without --keyring:\n%s", result.code, result.stderr)
+	}
+}
+
+func TestParseTagDate(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "1717243200", want: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{value: "2024-06-01T12:00:00Z", want: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{value: "2024-06-01T14:00:00+02:00", want: time.Date(2024, 6, 1, 14, 0, 0, 0, time.FixedZone("", 2*60*60))},
+		{value: "2024-06-01", wantErr: true},
+		{value: "yesterday", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseTagDate(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseTagDate() = %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseTagDate() error = %s", err)
+			}
+			_, gotOffset := got.Zone()
+			_, wantOffset := tt.want.Zone()
+			if !got.Equal(tt.want) || gotOffset != wantOffset {
+				t.Errorf("parseTagDate() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTagDate(t *testing.T) {
+	tests := []struct {
+		name      string
+		first     []string // Extra arguments and environment of each run
+		firstEnv  []string
+		second    []string
+		secondEnv []string
+		wantEqual bool
+	}{
+		{name: "pinned", first: []string{"--tag-date", "1717243200"}, second: []string{"--tag-date", "1717243200"}, wantEqual: true},
+		{name: "source date epoch", firstEnv: []string{"SOURCE_DATE_EPOCH=1717243200"}, second: []string{"--tag-date", "2024-06-01T12:00:00Z"}, wantEqual: true},
+		{name: "flag over source date epoch", first: []string{"--tag-date", "1717243200"}, secondEnv: []string{"SOURCE_DATE_EPOCH=1717243201"}},
+		{name: "different offsets", first: []string{"--tag-date", "2024-06-01T12:00:00Z"}, second: []string{"--tag-date", "2024-06-01T14:00:00+02:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hashes := []plumbing.Hash{}
+			runs := []struct{ args, env []string }{{tt.first, tt.firstEnv}, {tt.second, tt.secondEnv}}
+			for _, run := range runs {
+				// A fresh repository each time, with the same commit
+				dir, repo := newTestRepo(t)
+				args := append([]string{"--annotate", "--msg", "Release"}, run.args...)
+				mustRelease(t, dir, append(testTagger, run.env...), args...)
+				ref, err := repo.Tag(period() + "001")
+				if err != nil {
+					t.Fatal(err)
+				}
+				hashes = append(hashes, ref.Hash())
+			}
+			if equal := hashes[0] == hashes[1]; equal != tt.wantEqual {
+				t.Errorf("tag objects %s and %s, want equal = %t", hashes[0], hashes[1], tt.wantEqual)
+			}
+		})
+	}
+
+	t.Run("bad date", func(t *testing.T) {
+		dir, _ := newTestRepo(t)
+		result := runRelease(t, dir, testTagger, "--annotate", "--tag-date", "June 1st")
+		if result.code != 1 || !strings.Contains(result.stderr, "bad --tag-date") {
+			t.Errorf("exit code %d, want 1 with bad --tag-date:\n%s", result.code, result.stderr)
+		}
+	})
+}
+
+func TestReadOnlyRefStorage(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, gitDir string)
+	}{
+		{
+			name: "read-only",
+			setup: func(t *testing.T, gitDir string) {
+				if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+					t.Skip("permissions aren't enforced on windows or for root")
+				}
+				if err := os.Chmod(gitDir, 0555); err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { os.Chmod(gitDir, 0755) })
+			},
+		},
+		{
+			name: "refs/tags isn't a directory",
+			setup: func(t *testing.T, gitDir string) {
+				tags := filepath.Join(gitDir, "refs", "tags")
+				if err := os.RemoveAll(tags); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(tags, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		for _, args := range [][]string{nil, {"--dry-run"}, {"--list"}} {
+			t.Run(strings.Join(append([]string{tt.name}, args...), " "), func(t *testing.T) {
+				dir, _ := newTestRepo(t)
+				tt.setup(t, filepath.Join(dir, ".git"))
+				result := runRelease(t, dir, nil, args...)
+				if result.code != 1 || !strings.Contains(result.stderr, "tags can't be written") || !strings.Contains(result.stderr, "the repository's ref storage isn't writable") {
+					t.Errorf("exit code %d, want 1 with the ref storage error:\n%s", result.code, result.stderr)
+				}
+			})
+		}
+	}
+}
+
+func TestSBOM(t *testing.T) {
+	sbom := filepath.Join(t.TempDir(), "sbom.json")
+	if err := os.WriteFile(sbom, []byte("test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	trailer := "Release-SBOM: sbom.json sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		wantMsg  string // Message of the created tag
+		wantOut  string
+		wantErr  string
+	}{
+		{name: "annotates", args: []string{"--sbom", sbom}, wantMsg: "Release " + period() + "001\n\n" + trailer + "\n"},
+		{name: "with the message", args: []string{"--sbom", sbom, "--msg", "Ship it"}, wantMsg: "Ship it\n\n" + trailer + "\n"},
+		{name: "with scheme trailers", args: []string{"--sbom", sbom, "--scheme-trailers"}, wantMsg: trailer + "\nRelease-Scheme: date"},
+		{name: "dry run", args: []string{"--sbom", sbom, "--dry-run"}, wantOut: "would record SBOM sbom.json sha256:9f86d081"},
+		{name: "missing file", args: []string{"--sbom", sbom + ".missing"}, wantCode: 1, wantErr: "failed to read --sbom"},
+		{name: "lightweight", args: []string{"--sbom", sbom, "--lightweight"}, wantCode: 1, wantErr: "can't be combined with --lightweight"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, _ := newTestRepo(t)
+			result := runRelease(t, dir, testTagger, tt.args...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, result.stderr)
+			}
+			if !strings.Contains(result.stdout, tt.wantOut) {
+				t.Errorf("stdout doesn't contain %q:\n%s", tt.wantOut, result.stdout)
+			}
+			if !strings.Contains(result.stderr, tt.wantErr) {
+				t.Errorf("stderr doesn't contain %q:\n%s", tt.wantErr, result.stderr)
+			}
+			if tt.wantMsg == "" {
+				if count := countTags(t, dir); count != 0 {
+					t.Errorf("%d tags, want none", count)
+				}
+				return
+			}
+			if got := messageOf(t, dir, period()+"001"); !strings.Contains(got, tt.wantMsg) {
+				t.Errorf("message = %q, want it to contain %q", got, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestSign(t *testing.T) {
+	secret, public := writeTestKeys(t)
+	keyring, err := os.ReadFile(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		signingKey string // git config user.signingkey
+		args       []string
+		wantCode   int
+		wantOut    string
+		wantErr    string
+		wantSigned bool
+	}{
+		{name: "signing key", args: []string{"--sign", "--signing-key", secret}, wantSigned: true},
+		{name: "shorthand", args: []string{"-s", "--signing-key", secret}, wantSigned: true},
+		{name: "git config", signingKey: secret, args: []string{"--sign"}, wantSigned: true},
+		{name: "flag over git config", signingKey: secret + ".missing", args: []string{"--sign", "--signing-key", secret}, wantSigned: true},
+		{name: "dry run", args: []string{"--sign", "--signing-key", secret, "--dry-run"}, wantOut: "would sign with key " + secret + ", a test signature with it worked"},
+		{name: "no key", args: []string{"--sign"}, wantCode: 1, wantErr: "refusing to create unsigned tags"},
+		{name: "missing key file", args: []string{"--sign", "--signing-key", secret + ".missing"}, wantCode: 1, wantErr: "refusing to create unsigned tags"},
+		{name: "lightweight", args: []string{"--sign", "--signing-key", secret, "--lightweight"}, wantCode: 1, wantErr: "only annotated tags can be signed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			if tt.signingKey != "" {
+				setGitConfig(t, repo, "user", "signingkey", tt.signingKey)
+			}
+			result := runRelease(t, dir, testTagger, tt.args...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, result.stderr)
+			}
+			if !strings.Contains(result.stdout, tt.wantOut) {
+				t.Errorf("stdout doesn't contain %q:\n%s", tt.wantOut, result.stdout)
+			}
+			if !strings.Contains(result.stderr, tt.wantErr) {
+				t.Errorf("stderr doesn't contain %q:\n%s", tt.wantErr, result.stderr)
+			}
+			if !tt.wantSigned {
+				// Never an unsigned tag instead
+				if count := countTags(t, dir); count != 0 {
+					t.Errorf("%d tags, want none", count)
+				}
+				return
+			}
+			ref, err := repo.Tag(period() + "001")
+			if err != nil {
+				t.Fatalf("the release wasn't created: %s", err)
+			}
+			tag, err := repo.TagObject(ref.Hash())
+			if err != nil {
+				t.Fatalf("the release isn't annotated: %s", err)
+			}
+			if _, err := tag.Verify(string(keyring)); err != nil {
+				t.Errorf("the release's signature doesn't verify: %s", err)
+			}
+		})
+	}
+}
+
+func TestParseAsOf(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("no time zone database: %s", err)
+	}
+	tests := []struct {
+		value   string
+		loc     *time.Location
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "2024-05-17", loc: time.UTC, want: time.Date(2024, 5, 17, 23, 59, 59, 0, time.UTC)},
+		{value: "2024-05-17", loc: newYork, want: time.Date(2024, 5, 17, 23, 59, 59, 0, newYork)},
+		{value: "2024-05-17T18:00:00Z", loc: newYork, want: time.Date(2024, 5, 17, 18, 0, 0, 0, time.UTC)},
+		{value: "2024-05-17T18:00:00+02:00", loc: time.UTC, want: time.Date(2024, 5, 17, 16, 0, 0, 0, time.UTC)},
+		{value: "last friday", loc: time.UTC, wantErr: true},
+		{value: "2024-13-01", loc: time.UTC, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseAsOf(tt.value, tt.loc)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseAsOf() = %s, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseAsOf() error = %s", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseAsOf() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAsOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		want     string // Commit the release is at
+		wantCode int
+		wantErr  string
+	}{
+		{name: "end of the day", args: []string{"--as-of", "2024-06-07"}, want: "friday"},
+		{name: "weekend", args: []string{"--as-of", "2024-06-09"}, want: "friday"},
+		{name: "before the commit that day", args: []string{"--as-of", "2024-06-07T15:00:00Z"}, want: "initial"},
+		{name: "time of day", args: []string{"--as-of", "2024-06-10T09:00:00Z"}, want: "monday"},
+		{name: "branch", args: []string{"--as-of", "2024-06-30", "--as-of-ref", "stable"}, want: "friday"},
+		{name: "nothing that early", args: []string{"--as-of", "2024-05-01"}, wantCode: 1, wantErr: "no commit on HEAD at or before 2024-05-01T23:59:59Z"},
+		{name: "bad date", args: []string{"--as-of", "last friday"}, wantCode: 1, wantErr: "bad --as-of"},
+		{name: "with --ref", args: []string{"--as-of", "2024-06-07", "--ref", "stable"}, wantCode: 1, wantErr: "--ref and --as-of can't be combined"},
+		{name: "ref without as-of", args: []string{"--as-of-ref", "stable"}, wantCode: 1, wantErr: "--as-of-ref can only be used with --as-of"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			head, err := repo.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+			commits := map[string]plumbing.Hash{"initial": head.Hash()}
+			commits["friday"] = commitOn(t, repo, "Add the feature", time.Date(2024, 6, 7, 16, 0, 0, 0, time.UTC))
+			if err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("stable"), commits["friday"])); err != nil {
+				t.Fatal(err)
+			}
+			commits["monday"] = commitOn(t, repo, "Fix the build", time.Date(2024, 6, 10, 9, 0, 0, 0, time.UTC))
+			commitOn(t, repo, "Bump the docs", time.Date(2024, 6, 12, 9, 0, 0, 0, time.UTC))
+
+			result := runRelease(t, dir, nil, append([]string{"--tz", "UTC"}, tt.args...)...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, result.stderr)
+			}
+			if tt.wantCode != 0 {
+				if !strings.Contains(result.stderr, tt.wantErr) {
+					t.Errorf("stderr doesn't contain %q:\n%s", tt.wantErr, result.stderr)
+				}
+				return
+			}
+			tag := time.Now().UTC().Format("2006.01.") + "001"
+			if got := commitOf(t, dir, tag); got != commits[tt.want] {
+				t.Errorf("%s is at %s, want the %s commit %s", tag, got, tt.want, commits[tt.want])
+			}
+		})
+	}
+}
+
+func TestRepoConfig(t *testing.T) {
+	year := time.Now().Format("2006.")
+	tests := []struct {
+		name       string
+		config     string
+		args       []string
+		wantCode   int
+		wantTag    string
+		wantErr    string
+		wantRemote string // Remote the release was pushed to
+	}{
+		{name: "none", wantTag: period() + "001"},
+		{name: "format", config: "format: \"%Y.\"\n", wantTag: year + "001"},
+		{name: "flag over the file", config: "format: \"%Y.\"\n", args: []string{"--fmt", "%Y.%m."}, wantTag: period() + "001"},
+		{name: "prefix", config: "prefix: v\n", wantTag: "v" + period() + "001"},
+		{name: "remote", config: "remote: upstream\n", args: []string{"--push"}, wantTag: period() + "001", wantRemote: "upstream"},
+		{name: "remote flag over the file", config: "remote: upstream\n", args: []string{"--push", "--remote", "origin"}, wantTag: period() + "001", wantRemote: "origin"},
+		{name: "malformed", config: "format: [\n", wantCode: 1, wantErr: "failed to load .release.yaml"},
+		{name: "misspelled", config: "fromat: \"%Y.\"\n", wantCode: 1, wantErr: "field fromat not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			remotes := map[string]*git.Repository{"origin": addTestRemote(t, repo, "origin"), "upstream": addTestRemote(t, repo, "upstream")}
+			if tt.config != "" {
+				if err := os.WriteFile(filepath.Join(dir, ".release.yaml"), []byte(tt.config), 0644); err != nil {
+					t.Fatal(err)
+				}
+				w, err := repo.Worktree()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, err := w.Add(".release.yaml"); err != nil {
+					t.Fatal(err)
+				}
+				commitOn(t, repo, "Add the release config", testSignature.When.Add(time.Minute))
+			}
+			result := runRelease(t, dir, nil, tt.args...)
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, result.stderr)
+			}
+			if !strings.Contains(result.stderr, tt.wantErr) {
+				t.Errorf("stderr doesn't contain %q:\n%s", tt.wantErr, result.stderr)
+			}
+			if tt.wantTag == "" {
+				if count := countTags(t, dir); count != 0 {
+					t.Errorf("%d tags, want none", count)
+				}
+				return
+			}
+			if _, err := repo.Tag(tt.wantTag); err != nil {
+				t.Errorf("%s wasn't created: %s", tt.wantTag, err)
+			}
+			for name, remote := range remotes {
+				_, err := remote.Tag(tt.wantTag)
+				if pushed := err == nil; pushed != (name == tt.wantRemote) {
+					t.Errorf("pushed to %s = %t, want %t", name, pushed, name == tt.wantRemote)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"release"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writeGitHubOutput appends the created releases to the GitHub Actions step
+// output file so later steps can use them as steps.<id>.outputs.tag etc. A
+// single release is written as plain values, multiple releases are written as
+// JSON arrays (use fromJSON() in the workflow).
+func writeGitHubOutput(path string, tags, components, previous []string, commit string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	tag, component, previousTag := "", "", ""
+	if len(tags) == 1 {
+		tag, component, previousTag = tags[0], components[0], previous[0]
+	} else {
+		encodedTags, err := json.Marshal(tags)
+		if err != nil {
+			return err
+		}
+		encodedComponents, err := json.Marshal(components)
+		if err != nil {
+			return err
+		}
+		encodedPrevious, err := json.Marshal(previous)
+		if err != nil {
+			return err
+		}
+		tag, component, previousTag = string(encodedTags), string(encodedComponents), string(encodedPrevious)
+	}
+	_, err = fmt.Fprintf(f, "tag=%s\ncomponent=%s\nprevious=%s\ncommit=%s\n", tag, component, previousTag, commit)
+	return err
+}
+
+// shellQuote quotes a value so it can be safely used in a POSIX shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// writeShellExports writes the release as shell exports for use with
+// eval "$(release --export-shell)"
+func writeShellExports(w io.Writer, tag, component, commit, previous string) {
+	fmt.Fprintf(w, "export RELEASE_TAG=%s\n", shellQuote(tag))
+	fmt.Fprintf(w, "export RELEASE_COMPONENT=%s\n", shellQuote(component))
+	fmt.Fprintf(w, "export RELEASE_PREVIOUS=%s\n", shellQuote(previous))
+	fmt.Fprintf(w, "export RELEASE_COMMIT=%s\n", shellQuote(commit))
+}
+
+// pushPreview is what a --dry-run --push would push to one remote
+type pushPreview struct {
+	remote, url, auth string
+	tags              []string
+	conflicts         []string // The tags the remote already has
+	err               error    // Listing the remote's tags failed
+}
+
+// print writes the preview of the push to stdout
+func (p pushPreview) print(forcePush bool) {
+	fmt.Printf("would push to remote %s (%s) using %s:\n", p.remote, p.url, p.auth)
+	if len(p.tags) == 0 {
+		fmt.Println(" nothing, the tags exist already")
+		return
+	}
+	if p.err != nil {
+		fmt.Printf(" %s (unknown, couldn't list the remote's tags)\n", strings.Join(p.tags, ", "))
+		return
+	}
+	for _, tag := range p.tags {
+		status := "new"
+		for _, conflict := range p.conflicts {
+			if conflict == tag {
+				status = "already in the remote, the push would be rejected"
+				if forcePush {
+					status = "already in the remote, would be overwritten"
+				}
+			}
+		}
+		fmt.Printf(" %s (%s)\n", tag, status)
+	}
+}
+
+// printPushSummary prints which remotes got each tag when pushing to more
+// than one, failedOn maps a tag to the remotes it failed to push to
+func printPushSummary(tags, remotes []string, failedOn map[string][]string) {
+	fmt.Println("push summary:")
+	for _, tag := range tags {
+		failed := map[string]bool{}
+		for _, remote := range failedOn[tag] {
+			failed[remote] = true
+		}
+		ok := []string{}
+		for _, remote := range remotes {
+			if !failed[remote] {
+				ok = append(ok, remote)
+			}
+		}
+		line := fmt.Sprintf(" %s: in %s", tag, strings.Join(ok, ", "))
+		if len(ok) == 0 {
+			line = fmt.Sprintf(" %s: in no remote", tag)
+		}
+		if len(failedOn[tag]) > 0 {
+			line += fmt.Sprintf(", FAILED for %s", strings.Join(failedOn[tag], ", "))
+		}
+		fmt.Println(line)
+	}
+}
+
+// componentName returns a component for display, (root) for releases of the
+// whole repository
+func componentName(component string) string {
+	if component == "" {
+		return "(root)"
+	}
+	return component
+}
+
+// componentLabel returns " of <component>" to follow a noun in messages, or
+// nothing for releases of the whole repository
+func componentLabel(component string) string {
+	if component == "" {
+		return ""
+	}
+	return " of " + component
+}
+
+// summaryLine returns a one line headline of a release for posting in chat,
+// e.g. "Released api 1.4.0 (abc1234) from main — 7 commits since 1.3.2".
+// The branch is left out if it's empty (or HEAD is detached), previous is
+// empty for the first release.
+func summaryLine(tag, component, commit, branch, previous string, commits int) string {
+	line := "Released "
+	if component != "" {
+		line += component + " "
+	}
+	line += fmt.Sprintf("%s (%s)", tag, commit[:7])
+	if branch != "" && branch != "HEAD" {
+		line += " from " + branch
+	}
+	plural := "s"
+	if commits == 1 {
+		plural = ""
+	}
+	if previous == "" {
+		return fmt.Sprintf("%s — first release, %d commit%s", line, commits, plural)
+	}
+	return fmt.Sprintf("%s — %d commit%s since %s", line, commits, plural, previous)
+}
+
+// dotQuote quotes a value as a Graphviz DOT string
+func dotQuote(value string) string {
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(value, `\`, `\\`), `"`, `\"`) + `"`
+}
+
+// writePlanDot writes the planned releases as a Graphviz DOT graph of each
+// component, the tag it would get, the commit the tag would point at and the
+// remotes it would be pushed to (none if nothing would be pushed)
+func writePlanDot(w io.Writer, tags, components []string, commit string, remotes []string) error {
+	lines := []string{
+		"digraph release {",
+		"  rankdir=LR;",
+		fmt.Sprintf("  %s [label=%s, shape=diamond];", dotQuote("commit:"+commit), dotQuote(commit[:7])),
+	}
+	for _, remote := range remotes {
+		lines = append(lines, fmt.Sprintf("  %s [label=%s, shape=cylinder];", dotQuote("remote:"+remote), dotQuote(remote)))
+	}
+	for idx, tag := range tags {
+		component := components[idx]
+		label := componentName(component)
+		lines = append(lines,
+			fmt.Sprintf("  %s [label=%s, shape=box];", dotQuote("component:"+component), dotQuote(label)),
+			fmt.Sprintf("  %s [label=%s, shape=ellipse];", dotQuote("tag:"+tag), dotQuote(tag)),
+			fmt.Sprintf("  %s -> %s;", dotQuote("component:"+component), dotQuote("tag:"+tag)),
+			fmt.Sprintf("  %s -> %s;", dotQuote("tag:"+tag), dotQuote("commit:"+commit)),
+		)
+		for _, remote := range remotes {
+			lines = append(lines, fmt.Sprintf("  %s -> %s [style=dashed, label=\"push\"];", dotQuote("tag:"+tag), dotQuote("remote:"+remote)))
+		}
+	}
+	lines = append(lines, "}")
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// writePlanYAML writes the planned releases as YAML, values are written as
+// double quoted strings (Go and YAML agree on the escapes) so tags and
+// components never need any further quoting. remote is the first of the
+// remotes, kept for readers that predate pushing to several.
+func writePlanYAML(w io.Writer, tags, components []string, commit string, remotes []string, push bool) error {
+	quoted := []string{}
+	for _, remote := range remotes {
+		quoted = append(quoted, strconv.Quote(remote))
+	}
+	lines := []string{
+		fmt.Sprintf("commit: %s", strconv.Quote(commit)),
+		fmt.Sprintf("remote: %s", strconv.Quote(remotes[0])),
+		fmt.Sprintf("remotes: [%s]", strings.Join(quoted, ", ")),
+		fmt.Sprintf("push: %t", push),
+	}
+	if len(tags) == 0 {
+		lines = append(lines, "releases: []")
+	} else {
+		lines = append(lines, "releases:")
+	}
+	for idx, tag := range tags {
+		lines = append(lines,
+			fmt.Sprintf("  - component: %s", strconv.Quote(components[idx])),
+			fmt.Sprintf("    tag: %s", strconv.Quote(tag)),
+		)
+	}
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// writeReleasesCSV writes the releases as CSV with a header row
+func writeReleasesCSV(w io.Writer, rm *release.Manager, releases []release.Release) error {
+	out := csv.NewWriter(w)
+	if err := out.Write([]string{"tag", "component", "commit", "date", "annotated", "signer", "message"}); err != nil {
+		return err
+	}
+	for _, rel := range releases {
+		err := out.Write([]string{
+			rel.Tag,
+			rm.ComponentOf(rel.Tag),
+			rel.Hash,
+			rel.Date().Format(time.RFC3339),
+			strconv.FormatBool(rel.Annotated()),
+			rel.SignerKeyID(),
+			strings.SplitN(rel.Message(), "\n", 2)[0],
+		})
+		if err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// releaseJSON is how a release is written by writeReleasesJSON
+type releaseJSON struct {
+	Tag       string                   `json:"tag"`
+	Commit    string                   `json:"commit"`
+	Date      string                   `json:"date"`     // Of the commit
+	Released  string                   `json:"released"` // The tagger date, the commit's for lightweight tags
+	Message   string                   `json:"message"`
+	Signature *release.TagVerification `json:"signature,omitempty"`
+}
+
+// writeReleasesJSON writes the releases as a JSON array, including their
+// signature status if they were verified
+func writeReleasesJSON(w io.Writer, releases []release.Release, verifications map[string]release.TagVerification) error {
+	entries := []releaseJSON{}
+	for _, rel := range releases {
+		entry := releaseJSON{
+			Tag:      rel.Tag,
+			Commit:   rel.Hash,
+			Date:     rel.Date().Format(time.RFC3339),
+			Released: rel.ReleaseDate().Format(time.RFC3339),
+			Message:  strings.SplitN(rel.Message(), "\n", 2)[0],
+		}
+		if result, ok := verifications[rel.Tag]; ok {
+			entry.Signature = &result
+		}
+		entries = append(entries, entry)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// runJSON is the --output json document describing what a run released
+type runJSON struct {
+	DryRun         bool         `json:"dry_run"`
+	Commit         string       `json:"commit"`
+	RolledBack     bool         `json:"rolled_back,omitempty"`     // An --atomic release failed and everything was undone
+	RollbackErrors []string     `json:"rollback_errors,omitempty"` // The steps of a rollback that failed, their tags are still released
+	Releases       []resultJSON `json:"releases"`
+	Warnings       []string     `json:"warnings"` // Everything tolerated along the way, see Manager.Warnf
+}
+
+// resultJSON is a single release of a run, proposed ones with --dry-run
+type resultJSON struct {
+	Tag       string     `json:"tag"`
+	Component string     `json:"component"`
+	Previous  string     `json:"previous"` // The release this one follows, empty for a first release
+	Created   bool       `json:"created"`
+	Skipped   bool       `json:"skipped,omitempty"` // The nightly already existed
+	Error     string     `json:"error,omitempty"`
+	Pushes    []pushJSON `json:"pushes,omitempty"`
+}
+
+// pushJSON is the outcome of pushing a release to one remote, pushed is true
+// if the remote has the tag afterwards (including if it already did)
+type pushJSON struct {
+	Remote string `json:"remote"`
+	Pushed bool   `json:"pushed"`
+	Error  string `json:"error,omitempty"`
+
+	// GitHubRelease is the URL of the release created with --github-release
+	GitHubRelease string `json:"github_release,omitempty"`
+}
+
+// writeRunJSON writes the --output json document
+func writeRunJSON(w io.Writer, run runJSON) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(run)
+}
@@ -0,0 +1,523 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"gopkg.in/yaml.v3"
+)
+
+func TestWarningsJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, dir string, repo *git.Repository)
+		args  []string
+		want  []string // Substrings of the warnings, in order
+	}{
+		{name: "none", args: []string{"--output", "json"}, want: []string{}},
+		{name: "over the component cap", args: []string{"--output", "json", "--max-components", "1", "--yes", "api", "web"}, want: []string{"releasing 2 components, over the limit of 1"}},
+		{
+			name: "too few commits forced",
+			setup: func(t *testing.T, dir string, repo *git.Repository) {
+				mustRelease(t, dir, testTagger)
+				commitFile(t, repo, "file.txt", "Fix the build")
+			},
+			args: []string{"--output", "json", "--min-commits", "2", "--force"},
+			want: []string{"only 1 commit(s) since " + period() + "001, at least 2 are needed (--min-commits), releasing anyway because --force was given"},
+		},
+		{
+			name: "dirty dry run",
+			setup: func(t *testing.T, dir string, repo *git.Repository) {
+				if err := os.WriteFile(filepath.Join(dir, "README"), []byte("changed\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			args: []string{"--output", "json", "--dry-run"},
+			want: []string{"the working tree has uncommitted changes"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			if tt.setup != nil {
+				tt.setup(t, dir, repo)
+			}
+			result := mustRelease(t, dir, testTagger, tt.args...)
+			var run runJSON
+			if err := json.Unmarshal([]byte(result.stdout), &run); err != nil {
+				t.Fatalf("stdout isn't the JSON document: %s\n%s", err, result.stdout)
+			}
+			if run.Warnings == nil {
+				t.Fatalf("warnings is null, want an array:\n%s", result.stdout)
+			}
+			if len(run.Warnings) != len(tt.want) {
+				t.Fatalf("warnings = %q, want %d", run.Warnings, len(tt.want))
+			}
+			for idx, want := range tt.want {
+				if !strings.Contains(run.Warnings[idx], want) {
+					t.Errorf("warning %d = %q, want one containing %q", idx, run.Warnings[idx], want)
+				}
+			}
+			// Still summarized at the end for people reading the log
+			if len(tt.want) > 0 && !strings.Contains(result.stderr, "completed with") {
+				t.Errorf("warnings not summarized on stderr:\n%s", result.stderr)
+			}
+		})
+	}
+}
+
+func TestWriteGitHubOutput(t *testing.T) {
+	tests := []struct {
+		name       string
+		existing   string // Written by earlier steps
+		tags       []string
+		components []string
+		previous   []string
+		want       string
+	}{
+		{
+			name: "single release",
+			tags: []string{"2024.06.002"}, components: []string{""}, previous: []string{"2024.06.001"},
+			want: "tag=2024.06.002\ncomponent=\nprevious=2024.06.001\ncommit=abc123\n",
+		},
+		{
+			name:     "appended",
+			existing: "other=value\n",
+			tags:     []string{"2024.06.001-api"}, components: []string{"api"}, previous: []string{""},
+			want: "other=value\ntag=2024.06.001-api\ncomponent=api\nprevious=\ncommit=abc123\n",
+		},
+		{
+			name: "multiple releases",
+			tags: []string{"2024.06.001-api", "2024.06.003-web"}, components: []string{"api", "web"}, previous: []string{"", "2024.06.002-web"},
+			want: `tag=["2024.06.001-api","2024.06.003-web"]` + "\n" + `component=["api","web"]` + "\n" + `previous=["","2024.06.002-web"]` + "\ncommit=abc123\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "output")
+			if tt.existing != "" {
+				if err := os.WriteFile(path, []byte(tt.existing), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := writeGitHubOutput(path, tt.tags, tt.components, tt.previous, "abc123"); err != nil {
+				t.Fatalf("writeGitHubOutput() error = %s", err)
+			}
+			got, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("output file:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGitHubOutputFlag(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "output")
+	mustRelease(t, dir, append([]string{"GITHUB_OUTPUT=" + path}, testTagger...), "--github-output", "api")
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("$GITHUB_OUTPUT wasn't written: %s", err)
+	}
+	want := "tag=" + period() + "001-api\ncomponent=api\nprevious=\ncommit=" + head.Hash().String() + "\n"
+	if string(got) != want {
+		t.Errorf("output file:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Outside of GitHub Actions it's only a warning
+	result := mustRelease(t, dir, testTagger, "--github-output", "web")
+	if !strings.Contains(result.stderr, "$GITHUB_OUTPUT isn't set") {
+		t.Errorf("no warning without $GITHUB_OUTPUT:\n%s", result.stderr)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "2024.06.001", want: "'2024.06.001'"},
+		{value: "", want: "''"},
+		{value: "it's", want: `'it'\''s'`},
+		{value: "$(rm -rf /) `x` \"y\"", want: "'$(rm -rf /) `x` \"y\"'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := shellQuote(tt.value); got != tt.want {
+				t.Errorf("shellQuote(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteShellExports(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh to evaluate the exports with")
+	}
+	tests := []struct {
+		name                             string
+		tag, component, commit, previous string
+	}{
+		{name: "plain", tag: "2024.06.002-api", component: "api", commit: "abc123", previous: "2024.06.001-api"},
+		{name: "first release", tag: "2024.06.001", commit: "abc123"},
+		{name: "special characters", tag: "v1.0.0-1-it's", component: "it's $HOME `id` \"quoted\" \\ ;|&", commit: "abc123", previous: "a\nb"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var exports strings.Builder
+			writeShellExports(&exports, tt.tag, tt.component, tt.commit, tt.previous)
+			// What eval "$(release --export-shell)" ends up with
+			script := exports.String() + `printf '%s\0%s\0%s\0%s' "$RELEASE_TAG" "$RELEASE_COMPONENT" "$RELEASE_COMMIT" "$RELEASE_PREVIOUS"`
+			out, err := exec.Command("sh", "-c", script).Output()
+			if err != nil {
+				t.Fatalf("sh failed to evaluate the exports: %s\n%s", err, exports.String())
+			}
+			want := strings.Join([]string{tt.tag, tt.component, tt.commit, tt.previous}, "\x00")
+			if string(out) != want {
+				t.Errorf("evaluated to %q, want %q", out, want)
+			}
+		})
+	}
+}
+
+func TestExportShellFlag(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := mustRelease(t, dir, nil, "--export-shell", "api")
+	want := "export RELEASE_TAG='" + period() + "001-api'\nexport RELEASE_COMPONENT='api'\nexport RELEASE_PREVIOUS=''\nexport RELEASE_COMMIT='" + head.Hash().String() + "'\n"
+	if result.stdout != want {
+		t.Errorf("exports:\n%s\nwant:\n%s", result.stdout, want)
+	}
+	if count := countTags(t, dir); count != 0 {
+		t.Errorf("--export-shell created %d tags", count)
+	}
+
+	result = runRelease(t, dir, nil, "--export-shell", "api", "web")
+	if result.code == 0 || !strings.Contains(result.stderr, "--export-shell needs exactly one component, got 2") {
+		t.Errorf("exit code %d, want an error for two components:\n%s", result.code, result.stderr)
+	}
+}
+
+func TestWritePlanDot(t *testing.T) {
+	commit := "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		name       string
+		tags       []string
+		components []string
+		remotes    []string
+		want       []string
+		dontWant   []string
+	}{
+		{
+			name:       "components",
+			tags:       []string{"2024.06.001-api", "2024.06.001-web"},
+			components: []string{"api", "web"},
+			want: []string{
+				`"commit:` + commit + `" [label="0123456", shape=diamond];`,
+				`"component:api" [label="api", shape=box];`,
+				`"tag:2024.06.001-api" [label="2024.06.001-api", shape=ellipse];`,
+				`"component:api" -> "tag:2024.06.001-api";`,
+				`"tag:2024.06.001-api" -> "commit:` + commit + `";`,
+				`"component:web" [label="web", shape=box];`,
+				`"component:web" -> "tag:2024.06.001-web";`,
+			},
+			dontWant: []string{"remote:", "push"},
+		},
+		{
+			name:       "pushed",
+			tags:       []string{"2024.06.001-api"},
+			components: []string{"api"},
+			remotes:    []string{"origin", "mirror"},
+			want: []string{
+				`"remote:origin" [label="origin", shape=cylinder];`,
+				`"remote:mirror" [label="mirror", shape=cylinder];`,
+				`"tag:2024.06.001-api" -> "remote:origin" [style=dashed, label="push"];`,
+				`"tag:2024.06.001-api" -> "remote:mirror" [style=dashed, label="push"];`,
+			},
+		},
+		{
+			name:       "root",
+			tags:       []string{"2024.06.001"},
+			components: []string{""},
+			want:       []string{`"component:" [label="(root)", shape=box];`, `"component:" -> "tag:2024.06.001";`},
+		},
+		{
+			name:       "quoting",
+			tags:       []string{`2024.06.001-a"b`},
+			components: []string{`a"b\c`},
+			want:       []string{`"component:a\"b\\c" [label="a\"b\\c", shape=box];`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := writePlanDot(&out, tt.tags, tt.components, commit, tt.remotes); err != nil {
+				t.Fatalf("writePlanDot() error = %s", err)
+			}
+			dot := out.String()
+			if !strings.HasPrefix(dot, "digraph release {\n") || !strings.HasSuffix(dot, "\n}\n") {
+				t.Errorf("not a digraph:\n%s", dot)
+			}
+			for _, line := range tt.want {
+				if !strings.Contains(dot, "\n  "+line+"\n") {
+					t.Errorf("missing %s in:\n%s", line, dot)
+				}
+			}
+			for _, text := range tt.dontWant {
+				if strings.Contains(dot, text) {
+					t.Errorf("unexpected %s in:\n%s", text, dot)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanDotFlag(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "plan.dot")
+	mustRelease(t, dir, nil, "--plan-dot", path, "api", "web")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("--plan-dot didn't write the file: %s", err)
+	}
+	dot := string(data)
+	for _, tag := range []string{period() + "001-api", period() + "001-web"} {
+		if !strings.Contains(dot, `"tag:`+tag+`" -> "commit:`+head.Hash().String()+`";`) {
+			t.Errorf("no edge from %s to HEAD in:\n%s", tag, dot)
+		}
+	}
+	if count := countTags(t, dir); count != 0 {
+		t.Errorf("--plan-dot created %d tags", count)
+	}
+
+	addTestRemote(t, repo, "origin")
+	result := mustRelease(t, dir, nil, "--plan-dot", "-", "--push", "api")
+	if !strings.Contains(result.stdout, `"tag:`+period()+`001-api" -> "remote:origin" [style=dashed, label="push"];`) {
+		t.Errorf("no push edge to origin on stdout:\n%s", result.stdout)
+	}
+}
+
+// planYAML is the document --plan-yaml writes
+type planYAML struct {
+	Commit   string   `yaml:"commit"`
+	Remote   string   `yaml:"remote"`
+	Remotes  []string `yaml:"remotes"`
+	Push     bool     `yaml:"push"`
+	Releases []struct {
+		Component string `yaml:"component"`
+		Tag       string `yaml:"tag"`
+	} `yaml:"releases"`
+}
+
+func TestWritePlanYAML(t *testing.T) {
+	commit := "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		name       string
+		tags       []string
+		components []string
+		remotes    []string
+		push       bool
+	}{
+		{name: "components", tags: []string{"2024.06.001-api", "2024.06.001-web"}, components: []string{"api", "web"}, remotes: []string{"origin"}},
+		{name: "pushed", tags: []string{"2024.06.001"}, components: []string{""}, remotes: []string{"origin", "mirror"}, push: true},
+		{name: "quoting", tags: []string{"v1.0.0: \"rc\" #1"}, components: []string{"api: 'x'"}, remotes: []string{"my remote"}},
+		{name: "nothing", remotes: []string{"origin"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &strings.Builder{}
+			if err := writePlanYAML(out, tt.tags, tt.components, commit, tt.remotes, tt.push); err != nil {
+				t.Fatalf("writePlanYAML() error = %s", err)
+			}
+			var got planYAML
+			if err := yaml.Unmarshal([]byte(out.String()), &got); err != nil {
+				t.Fatalf("invalid YAML: %s\n%s", err, out)
+			}
+			if got.Commit != commit || got.Remote != tt.remotes[0] || !reflect.DeepEqual(got.Remotes, tt.remotes) || got.Push != tt.push {
+				t.Errorf("plan = %+v, want commit %s, remotes %q and push %t", got, commit, tt.remotes, tt.push)
+			}
+			if len(got.Releases) != len(tt.tags) {
+				t.Fatalf("got %d releases, want %d:\n%s", len(got.Releases), len(tt.tags), out)
+			}
+			for idx, rel := range got.Releases {
+				if rel.Tag != tt.tags[idx] || rel.Component != tt.components[idx] {
+					t.Errorf("release %d = %+v, want %s of %q", idx, rel, tt.tags[idx], tt.components[idx])
+				}
+			}
+		})
+	}
+}
+
+func TestPlanYAMLFlag(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addTestRemote(t, repo, "origin")
+
+	tests := []struct {
+		name string
+		args []string
+		push bool
+	}{
+		{name: "dry run", args: []string{"api", "web"}},
+		{name: "push", args: []string{"--push", "api", "web"}, push: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := mustRelease(t, dir, nil, append([]string{"--plan-yaml", "-"}, tt.args...)...)
+			var got planYAML
+			if err := yaml.Unmarshal([]byte(result.stdout), &got); err != nil {
+				t.Fatalf("invalid YAML on stdout: %s\n%s", err, result.stdout)
+			}
+			if got.Commit != head.Hash().String() || got.Push != tt.push || !reflect.DeepEqual(got.Remotes, []string{"origin"}) {
+				t.Errorf("plan = %+v, want HEAD on origin with push %t", got, tt.push)
+			}
+			if len(got.Releases) != 2 || got.Releases[0].Tag != period()+"001-api" || got.Releases[1].Tag != period()+"001-web" {
+				t.Errorf("releases = %+v, want the first api and web releases", got.Releases)
+			}
+			if count := countTags(t, dir); count != 0 {
+				t.Errorf("--plan-yaml created %d tags", count)
+			}
+		})
+	}
+}
+
+func TestSummaryLine(t *testing.T) {
+	commit := "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		name      string
+		tag       string
+		component string
+		branch    string
+		previous  string
+		commits   int
+		want      string
+	}{
+		{name: "component", tag: "1.4.0-api", component: "api", branch: "main", previous: "1.3.2-api", commits: 7, want: "Released api 1.4.0-api (0123456) from main — 7 commits since 1.3.2-api"},
+		{name: "one commit", tag: "2024.06.002", branch: "main", previous: "2024.06.001", commits: 1, want: "Released 2024.06.002 (0123456) from main — 1 commit since 2024.06.001"},
+		{name: "no branch", tag: "2024.06.002", previous: "2024.06.001", commits: 3, want: "Released 2024.06.002 (0123456) — 3 commits since 2024.06.001"},
+		{name: "first release", tag: "2024.06.001", branch: "main", commits: 12, want: "Released 2024.06.001 (0123456) from main — first release, 12 commits"},
+		{name: "first release of one commit", tag: "2024.06.001-web", component: "web", commits: 1, want: "Released web 2024.06.001-web (0123456) — first release, 1 commit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summaryLine(tt.tag, tt.component, commit, tt.branch, tt.previous, tt.commits); got != tt.want {
+				t.Errorf("summaryLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummaryLineFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		released bool // 2024.06.001-api was released from the first commit
+		detached bool
+		args     []string
+		want     string // With the tag and commit filled in
+	}{
+		{name: "first release", args: []string{"--summary-line", "api"}, want: "Released api %s (%s) from master — first release, 2 commits"},
+		{name: "since the previous", released: true, args: []string{"--summary-line", "api"}, want: "Released api %s (%s) from master — 1 commit since 2024.06.001-api"},
+		{name: "branch flag", released: true, args: []string{"--summary-line", "--branch", "main", "api"}, want: "Released api %s (%s) from main — 1 commit since 2024.06.001-api"},
+		{name: "detached", released: true, detached: true, args: []string{"--summary-line", "api"}, want: "Released api %s (%s) — 1 commit since 2024.06.001-api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			addComponentPath(t, repo, "api", "services/api")
+			commitFile(t, repo, "services/api/main.go", "Add the api")
+			if tt.released {
+				tagHead(t, repo, "2024.06.001-api")
+			}
+			commitFile(t, repo, "web/index.html", "Restyle the web")
+			head := commitFile(t, repo, "services/api/main.go", "Fix the api")
+			if tt.detached {
+				w, err := repo.Worktree()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Checkout(&git.CheckoutOptions{Hash: head}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			result := mustRelease(t, dir, nil, tt.args...)
+			want := fmt.Sprintf(tt.want, period()+"001-api", head.String()[:7])
+			if !strings.Contains(result.stdout, want+"\n") {
+				t.Errorf("stdout doesn't contain %q:\n%s", want, result.stdout)
+			}
+		})
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	first, err := repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagHead(t, repo, "2024.06.001")
+	second := commitFile(t, repo, "file.txt", "Fix the build")
+	_, err = repo.CreateTag("2024.06.002-api", second, &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "Test Tagger", Email: "tagger@example.com", When: time.Date(2024, 6, 2, 9, 0, 0, 0, time.UTC)},
+		Message: "Release api, with a comma and \"quotes\"\n\nMore details",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := []string{"tag", "component", "commit", "date", "annotated", "signer", "message"}
+	apiRow := []string{"2024.06.002-api", "api", second.String(), "2024-06-01T12:01:00Z", "true", "", `Release api, with a comma and "quotes"`}
+	rootRow := []string{"2024.06.001", "", first.Hash().String(), "2024-06-01T12:00:00Z", "false", "", "initial commit"}
+
+	tests := []struct {
+		name string
+		args []string
+		want [][]string
+	}{
+		{name: "all", args: []string{"--export", "csv"}, want: [][]string{header, apiRow, rootRow}},
+		{name: "component", args: []string{"--export", "csv", "api"}, want: [][]string{header, apiRow}},
+		{name: "no releases", args: []string{"--export", "csv", "web"}, want: [][]string{header}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := mustRelease(t, dir, nil, tt.args...)
+			got, err := csv.NewReader(strings.NewReader(result.stdout)).ReadAll()
+			if err != nil {
+				t.Fatalf("output isn't valid CSV: %s\n%s", err, result.stdout)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rows = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	result := runRelease(t, dir, nil, "--export", "tsv")
+	if result.code == 0 || !strings.Contains(result.stderr, "unknown --export format 'tsv', only csv is supported") {
+		t.Errorf("exit code %d, want an error for tsv:\n%s", result.code, result.stderr)
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+
+	"release"
+)
+
+func TestListRemoteAuth(t *testing.T) {
+	const missingKey = "/nonexistent/id_ed25519"
+	tests := []struct {
+		name       string
+		sshRemote  bool // An unreachable ssh remote instead of a local one
+		args       []string
+		wantCode   int
+		wantOutput string
+		wantNot    string
+	}{
+		{name: "read without the key", args: []string{"--list", "--remote", "origin", "--ssh-key", missingKey, "-v"}, wantOutput: "2024.06.001", wantNot: "ssh key"},
+		{name: "read falls back to the key", sshRemote: true, args: []string{"--list", "--remote", "origin", "--ssh-key", missingKey, "-v"}, wantCode: 1, wantOutput: "anonymous listing of remote origin failed"},
+		{name: "write needs the key", sshRemote: true, args: []string{"--push", "--remote", "origin", "--ssh-key", missingKey, "-v"}, wantCode: 1, wantOutput: "ssh key " + missingKey + " does not exist", wantNot: "anonymous listing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			if tt.sshRemote {
+				_, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"ssh://git@127.0.0.1:1/release.git"}})
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				addTestRemote(t, repo, "origin")
+			}
+			tagHead(t, repo, "2024.06.001")
+			if !tt.sshRemote {
+				pushTags(t, repo, "origin")
+			}
+			commitFile(t, repo, "file.txt", "Fix the build")
+
+			result := runRelease(t, dir, testTagger, tt.args...)
+			output := result.stdout + result.stderr
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, output)
+			}
+			if !strings.Contains(output, tt.wantOutput) {
+				t.Errorf("output doesn't contain %q:\n%s", tt.wantOutput, output)
+			}
+			if tt.wantNot != "" && strings.Contains(output, tt.wantNot) {
+				t.Errorf("output contains %q:\n%s", tt.wantNot, output)
+			}
+		})
+	}
+}
+
+func TestSync(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantOutput []string
+		wantLocal  bool // 2024.06.003 was fetched
+		wantRemote bool // 2024.06.002 was pushed
+	}{
+		{
+			name:       "report",
+			args:       []string{"--sync"},
+			wantOutput: []string{"only in the local repository, missing from origin (1):\n 2024.06.002\n", "only in remote origin, missing locally (1):\n 2024.06.003\n"},
+		},
+		{name: "pull missing", args: []string{"--sync", "--pull-missing"}, wantOutput: []string{"fetched 1 tag(s) from remote origin"}, wantLocal: true},
+		{name: "push extra", args: []string{"--sync", "--push-extra"}, wantRemote: true},
+		{name: "both", args: []string{"--sync", "--pull-missing", "--push-extra"}, wantLocal: true, wantRemote: true},
+		{name: "dry run", args: []string{"--sync", "--pull-missing", "--push-extra", "--dry-run"}},
+		{name: "without sync", args: []string{"--pull-missing"}, wantCode: 1, wantOutput: []string{"--pull-missing and --push-extra can only be used with --sync"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			remote := addTestRemote(t, repo, "origin")
+			tagHead(t, repo, "2024.06.001")
+			pushTags(t, repo, "origin")
+			head, err := repo.Head()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Released from another machine, and a release that wasn't pushed
+			err = remote.Storer.SetReference(plumbing.NewHashReference(plumbing.NewTagReferenceName("2024.06.003"), head.Hash()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			commitFile(t, repo, "file.txt", "Fix the build")
+			tagHead(t, repo, "2024.06.002")
+
+			result := runRelease(t, dir, nil, tt.args...)
+			output := result.stdout + result.stderr
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, output)
+			}
+			for _, want := range tt.wantOutput {
+				if !strings.Contains(output, want) {
+					t.Errorf("output doesn't contain %q:\n%s", want, output)
+				}
+			}
+			if _, err := repo.Tag("2024.06.003"); (err == nil) != tt.wantLocal {
+				t.Errorf("2024.06.003 fetched = %t, want %t", err == nil, tt.wantLocal)
+			}
+			if _, err := remote.Tag("2024.06.002"); (err == nil) != tt.wantRemote {
+				t.Errorf("2024.06.002 pushed = %t, want %t", err == nil, tt.wantRemote)
+			}
+			if tt.wantLocal && tt.wantRemote {
+				if result := mustRelease(t, dir, nil, "--sync"); result.stdout != "release tags are in sync with remote origin\n" {
+					t.Errorf("not in sync after reconciling:\n%s", result.stdout)
+				}
+			}
+		})
+	}
+}
+
+func TestUseUpstreamRemote(t *testing.T) {
+	tests := []struct {
+		name     string
+		upstream string // The remote master tracks, none if empty
+		args     []string
+		wantIn   string
+	}{
+		{name: "upstream", upstream: "upstream", args: []string{"--use-upstream-remote"}, wantIn: "upstream"},
+		{name: "no upstream", args: []string{"--use-upstream-remote"}, wantIn: "origin"},
+		{name: "no upstream falls back to --remote", args: []string{"--use-upstream-remote", "--remote", "mirror"}, wantIn: "mirror"},
+		{name: "upstream wins over --remote", upstream: "upstream", args: []string{"--use-upstream-remote", "--remote", "mirror"}, wantIn: "upstream"},
+		{name: "not asked for", upstream: "upstream", wantIn: "origin"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			remotes := map[string]*git.Repository{}
+			for _, name := range []string{"origin", "upstream", "mirror"} {
+				remotes[name] = addTestRemote(t, repo, name)
+			}
+			if tt.upstream != "" {
+				cfg, err := repo.Config()
+				if err != nil {
+					t.Fatal(err)
+				}
+				cfg.Branches["master"] = &config.Branch{Name: "master", Remote: tt.upstream, Merge: plumbing.Master}
+				if err := repo.SetConfig(cfg); err != nil {
+					t.Fatal(err)
+				}
+			}
+			mustRelease(t, dir, nil, append([]string{"--push"}, tt.args...)...)
+			for name, remote := range remotes {
+				_, err := remote.Tag(period() + "001")
+				if pushed := err == nil; pushed != (name == tt.wantIn) {
+					t.Errorf("pushed to %s = %t, want it pushed to %s only", name, pushed, tt.wantIn)
+				}
+			}
+		})
+	}
+}
+
+func TestRequireSigned(t *testing.T) {
+	secret, public := writeTestKeys(t)
+	tests := []struct {
+		name       string
+		existing   []string // Arguments of a release made before, not pushed
+		args       []string
+		wantCode   int
+		wantOutput string
+		wantPushed bool
+	}{
+		{name: "signed", args: []string{"--push", "--require-signed", "--sign", "--signing-key", secret}, wantPushed: true},
+		{name: "signed, with a keyring", args: []string{"--push", "--require-signed", "--sign", "--signing-key", secret, "--keyring", public}, wantPushed: true},
+		{name: "unsigned", args: []string{"--push", "--require-signed", "--keyring", public}, wantCode: 1, wantOutput: "--require-signed would refuse to push the new unsigned tags, add --sign"},
+		{name: "no keys", args: []string{"--push", "--require-signed"}, wantCode: 1, wantOutput: "--require-signed needs the public keys to verify tags against"},
+		{name: "existing signed", existing: []string{"--sign", "--signing-key", secret}, args: []string{"--sync", "--push-extra", "--require-signed", "--keyring", public}, wantPushed: true},
+		{name: "existing unsigned", existing: []string{"--annotate"}, args: []string{"--sync", "--push-extra", "--require-signed", "--keyring", public}, wantCode: 1, wantOutput: "refusing to push tag " + period() + "001, signed tags are required and it is unsigned"},
+		{name: "existing unsigned, not required", existing: []string{"--annotate"}, args: []string{"--sync", "--push-extra"}, wantPushed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			remote := addTestRemote(t, repo, "origin")
+			if tt.existing != nil {
+				mustRelease(t, dir, testTagger, tt.existing...)
+			}
+			result := runRelease(t, dir, testTagger, tt.args...)
+			output := result.stdout + result.stderr
+			if result.code != tt.wantCode {
+				t.Fatalf("exit code %d, want %d:\n%s", result.code, tt.wantCode, output)
+			}
+			if !strings.Contains(output, tt.wantOutput) {
+				t.Errorf("output doesn't contain %q:\n%s", tt.wantOutput, output)
+			}
+			_, err := remote.Tag(period() + "001")
+			if pushed := err == nil; pushed != tt.wantPushed {
+				t.Errorf("pushed = %t, want %t", pushed, tt.wantPushed)
+			}
+		})
+	}
+}
+
+func TestRollbackRelease(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	remote := addTestRemote(t, repo, "origin")
+	tagHead(t, repo, "2024.06.001")
+	pushTags(t, repo, "origin")
+	rm, err := release.NewManager(dir, release.DefaultDateFormat, "%03d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	noAuth := func(string) transport.AuthMethod { return nil }
+
+	failed := rollbackRelease(rm, []string{"2024.06.001", "2024.06.900"}, map[string][]string{"origin": {"2024.06.001"}, "missing": {"2024.06.001"}}, noAuth)
+	if len(failed) != 2 || !strings.Contains(failed[0], "missing") || !strings.Contains(failed[1], "failed to delete local tag 2024.06.900") {
+		t.Errorf("failed steps = %q, want the missing remote and the missing local tag", failed)
+	}
+	// The steps that could be undone still were
+	if _, err := remote.Tag("2024.06.001"); err == nil {
+		t.Error("2024.06.001 is still in origin")
+	}
+	if _, err := repo.Tag("2024.06.001"); err == nil {
+		t.Error("2024.06.001 is still a local tag")
+	}
+
+	if failed := rollbackRelease(rm, nil, nil, noAuth); len(failed) != 0 {
+		t.Errorf("failed steps = %q for an empty rollback, want none", failed)
+	}
+}
+
+func TestAtomicRollbackJSON(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	remote := addTestRemote(t, repo, "origin")
+	// broken can be listed but rejects every push, local remotes are served
+	// by git itself so its hooks run
+	broken := addTestRemote(t, repo, "broken")
+	hook := filepath.Join(remoteRoot(t, broken), "hooks", "pre-receive")
+	if err := os.MkdirAll(filepath.Dir(hook), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	result := runRelease(t, dir, testTagger, "--atomic", "--push", "--remote", "origin", "--remote", "broken", "--output", "json")
+	if result.code != 1 {
+		t.Fatalf("exit code %d, want 1:\n%s", result.code, result.stderr)
+	}
+	if !strings.Contains(result.stderr, "was rolled back (--atomic), nothing was released") {
+		t.Errorf("stderr doesn't report the rollback:\n%s", result.stderr)
+	}
+	var run runJSON
+	if err := json.Unmarshal([]byte(result.stdout), &run); err != nil {
+		t.Fatalf("invalid JSON output %q: %s", result.stdout, err)
+	}
+	if !run.RolledBack || len(run.RollbackErrors) != 0 {
+		t.Errorf("rolled_back = %t, rollback_errors = %q, want a complete rollback", run.RolledBack, run.RollbackErrors)
+	}
+	if count := countTags(t, dir); count != 0 {
+		t.Errorf("%d local tags after the rollback, want none", count)
+	}
+	tags, err := remote.Tags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ref, _ := tags.Next(); ref != nil {
+		t.Errorf("origin still has %s after the rollback", ref.Name())
+	}
+}
+
+// remoteRoot returns the directory of a repository made by addTestRemote
+func remoteRoot(t *testing.T, remote *git.Repository) string {
+	t.Helper()
+	storage, ok := remote.Storer.(*filesystem.Storage)
+	if !ok {
+		t.Fatal("the remote isn't stored on disk")
+	}
+	return storage.Filesystem().Root()
+}
+
+// writeTestSSHKey writes an unencrypted ed25519 ssh key and returns its path
+func writeTestSSHKey(t *testing.T) string {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRemoteAuth(t *testing.T) {
+	sshKey := sshKeyConfig{path: writeTestSSHKey(t), given: true}
+	tests := []struct {
+		name  string
+		url   string
+		token tokenConfig
+		want  string // describeAuth of the result
+	}{
+		{name: "https with a token", url: "https://github.com/owner/repo.git", token: tokenConfig{value: "secret", explicit: true}, want: "token"},
+		{name: "https without a token", url: "https://github.com/owner/repo.git", want: "no credentials"},
+		{name: "local path", url: t.TempDir(), token: tokenConfig{value: "secret", explicit: true}, want: "no credentials"},
+		{name: "ssh with a token from the environment", url: "git@github.com:owner/repo.git", token: tokenConfig{value: "secret"}, want: "ssh key " + sshKey.path},
+		{name: "ssh with a token for the API", url: "git@github.com:owner/repo.git", token: tokenConfig{value: "secret", explicit: true, api: true}, want: "ssh key " + sshKey.path},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{tt.url}}); err != nil {
+				t.Fatal(err)
+			}
+			rm, err := release.NewManager(dir, release.DefaultDateFormat, "%03d")
+			if err != nil {
+				t.Fatal(err)
+			}
+			auth := remoteAuth(rm, "origin", sshKey, tt.token)
+			if got := describeAuth(auth, sshKey.path); got != tt.want {
+				t.Errorf("remoteAuth() picked %s, want %s", got, tt.want)
+			}
+			if basic, ok := auth.(*githttp.BasicAuth); ok && (basic.Username != tokenUser || basic.Password != tt.token.value) {
+				t.Errorf("basic auth %s:%s, want %s and the token", basic.Username, basic.Password, tokenUser)
+			}
+		})
+	}
+}
+
+func TestTokenWithSSHRemote(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     []string
+		wantErr string
+		wantNot string
+	}{
+		{name: "--token", args: []string{"--push", "--token", "secret"}, wantErr: "--token only works with https remotes"},
+		// The token is for the API, pushing uses the key
+		{name: "--token for --github-release", args: []string{"--push", "--token", "secret", "--github-release"}, wantNot: "--token only works"},
+		{name: "$RELEASE_TOKEN", args: []string{"--push"}, env: []string{"RELEASE_TOKEN=secret"}, wantNot: "--token only works"},
+	}
+	key := writeTestSSHKey(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"ssh://git@127.0.0.1:1/owner/repo.git"}}); err != nil {
+				t.Fatal(err)
+			}
+			// Pushing fails either way, nothing listens on the port
+			result := runRelease(t, dir, testTagger, append([]string{"--ssh-key", key}, tt.args...)...)
+			if result.code == 0 {
+				t.Fatalf("release succeeded, want the push to fail:\n%s", result.stdout)
+			}
+			if !strings.Contains(result.stderr, tt.wantErr) {
+				t.Errorf("stderr doesn't contain %q:\n%s", tt.wantErr, result.stderr)
+			}
+			if tt.wantNot != "" && strings.Contains(result.stderr, tt.wantNot) {
+				t.Errorf("stderr contains %q:\n%s", tt.wantNot, result.stderr)
+			}
+		})
+	}
+}
+
+func TestDraftFlag(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr []string
+	}{
+		{name: "--draft", args: []string{"--draft"}, wantErr: []string{"--draft can only be used with --github-release, drafts are only supported for GitHub releases"}},
+		{name: "--github-draft alias", args: []string{"--github-draft"}, wantErr: []string{"--github-draft has been deprecated, use --draft instead", "--draft can only be used with --github-release"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, _ := newTestRepo(t)
+			result := runRelease(t, dir, testTagger, tt.args...)
+			if result.code != 1 {
+				t.Fatalf("exit code %d, want 1:\n%s", result.code, result.stderr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(result.stderr, want) {
+					t.Errorf("stderr doesn't contain %q:\n%s", want, result.stderr)
+				}
+			}
+			if count := countTags(t, dir); count != 0 {
+				t.Errorf("%d tags, want none created", count)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+// isTerminal returns true if the given file is an interactive terminal
+func isTerminal(f *os.File) bool {
+	// Not just any character device, /dev/null is one too
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// parseSelection parses a selection like "1,3,5-7" into the (zero based,
+// sorted, de-duplicated) indexes of the chosen items, count is the number of
+// items that were offered
+func parseSelection(input string, count int) ([]int, error) {
+	chosen := map[int]bool{}
+	for _, field := range strings.FieldsFunc(input, func(r rune) bool { return r == ',' || unicode.IsSpace(r) }) {
+		start, end := field, field
+		if parts := strings.SplitN(field, "-", 2); len(parts) == 2 {
+			start, end = parts[0], parts[1]
+		}
+		first, err := strconv.Atoi(start)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection '%s'", field)
+		}
+		last, err := strconv.Atoi(end)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection '%s'", field)
+		}
+		if first < 1 || last > count || first > last {
+			return nil, fmt.Errorf("selection '%s' is out of range, pick between 1 and %d", field, count)
+		}
+		for idx := first; idx <= last; idx++ {
+			chosen[idx-1] = true
+		}
+	}
+	indexes := []int{}
+	for idx := range chosen {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+	return indexes, nil
+}
+
+// selectComponents presents the given components as a numbered list on out
+// and reads the user's choice from in, returning the chosen components
+func selectComponents(components []string, in io.Reader, out io.Writer) ([]string, error) {
+	if len(components) == 0 {
+		return nil, fmt.Errorf("no known components to select from")
+	}
+	for idx, component := range components {
+		fmt.Fprintf(out, "%3d) %s\n", idx+1, component)
+	}
+	fmt.Fprintf(out, "select components to release (e.g. 1,3 or 2-4): ")
+
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	indexes, err := parseSelection(line, len(components))
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) == 0 {
+		return nil, fmt.Errorf("no components selected")
+	}
+	selected := []string{}
+	for _, idx := range indexes {
+		selected = append(selected, components[idx])
+	}
+	return selected, nil
+}
+
+// confirm asks a yes/no question on out and reads the answer from in, anything
+// but yes is a no
+func confirm(question string, in io.Reader, out io.Writer) bool {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(line))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"reflect"
+	"release"
+	"strings"
+	"testing"
+)
+
+func TestParseSelection(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []int
+		wantErr bool
+	}{
+		{input: "1", want: []int{0}},
+		{input: "3,1", want: []int{0, 2}},
+		{input: "2-4", want: []int{1, 2, 3}},
+		{input: "1 2-3, 3", want: []int{0, 1, 2}},
+		{input: "", want: []int{}},
+		{input: "0", wantErr: true},
+		{input: "5", wantErr: true},
+		{input: "3-2", wantErr: true},
+		{input: "a", wantErr: true},
+		{input: "1-b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseSelection(tt.input, 4)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSelection(%q) error = %v, want error %t", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSelection(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectComponents(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	for _, tag := range []string{"2024.06.001-web", "2024.06.002-api", "2024.06.003-db"} {
+		tagHead(t, repo, tag)
+	}
+	rm, err := release.NewManager(dir, release.DefaultDateFormat, "%03d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr string
+	}{
+		{input: "1,3\n", want: []string{"api", "web"}},
+		{input: "2", want: []string{"db"}},
+		{input: "1-3\n", want: []string{"api", "db", "web"}},
+		{input: "\n", wantErr: "no components selected"},
+		{input: "4\n", wantErr: "out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(strings.TrimSpace(tt.input), func(t *testing.T) {
+			var out strings.Builder
+			got, err := selectComponents(rm.Components(), strings.NewReader(tt.input), &out)
+			if !strings.Contains(out.String(), "  1) api\n  2) db\n  3) web\n") {
+				t.Errorf("offered:\n%s", out.String())
+			}
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("selectComponents() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectComponents() error = %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectComponents() = %v, want %v", got, tt.want)
+			}
+			// The selection is released like components given as arguments
+			result := mustRelease(t, dir, nil, append([]string{"--dry-run"}, got...)...)
+			for _, component := range got {
+				if !strings.Contains(result.stdout, "-"+component) {
+					t.Errorf("%s isn't released:\n%s", component, result.stdout)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectComponentsNone(t *testing.T) {
+	if _, err := selectComponents(nil, strings.NewReader("1\n"), &strings.Builder{}); err == nil {
+		t.Fatal("selecting from no components succeeded")
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	for input, want := range map[string]bool{"y\n": true, "YES\n": true, " yes ": true, "n\n": false, "\n": false, "": false, "yep\n": false} {
+		if got := confirm("delete?", strings.NewReader(input), &strings.Builder{}); got != want {
+			t.Errorf("confirm(%q) = %t, want %t", input, got, want)
+		}
+	}
+}
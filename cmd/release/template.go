@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// messageData is what a --msg-template is rendered with
+type messageData struct {
+	Tag       string    // The new release
+	Component string    // Empty for the whole repository
+	Date      time.Time // When the release is made
+	Branch    string    // The released branch, empty if it's unknown
+	Commit    string    // The released commit
+	PrevTag   string    // The release this one follows, empty for a first release
+	Changelog string    // The message --changelog and --include-diffstat would have used, if given
+}
+
+// parseMessageTemplate parses a --msg-template and renders it once with
+// placeholder data, so a misspelled field fails before anything is released
+// rather than half way through
+func parseMessageTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("msg-template").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := renderMessage(tmpl, messageData{Date: time.Now()}); err != nil {
+		return nil, fmt.Errorf("%w, the fields are .Tag, .Component, .Date, .Branch, .Commit, .PrevTag and .Changelog", err)
+	}
+	return tmpl, nil
+}
+
+// renderMessage renders the tag message of a release
+func renderMessage(tmpl *template.Template, data messageData) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,31 @@
+package release
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// GetProposedCounter returns the next release of the counter only scheme,
+// where each component has its own counter and no date at all (api-0001,
+// api-0002). The counter is scanned from the component's existing tags, width
+// is the minimum number of digits.
+func (r *Manager) GetProposedCounter(component, separator string, width int) (string, error) {
+	if component == "" {
+		return "", fmt.Errorf("the counter scheme needs a component, the counter is kept per component")
+	}
+	scan := regexp.MustCompile(`^` + regexp.QuoteMeta(component+separator) + `(\d+)$`)
+	var latest uint64
+	for _, release := range r.releases {
+		if results := scan.FindStringSubmatch(release.Tag); results != nil {
+			counter, err := strconv.ParseUint(results[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			if counter > latest {
+				latest = counter
+			}
+		}
+	}
+	return fmt.Sprintf("%s%s%0*d", component, separator, width, latest+1), nil
+}
@@ -0,0 +1,41 @@
+package release
+
+import "testing"
+
+func TestGetProposedCounter(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  []string
+		component string
+		separator string
+		width     int
+		want      string
+		wantErr   bool
+	}{
+		{name: "first", component: "api", separator: "-", width: 4, want: "api-0001"},
+		{name: "subsequent", existing: []string{"api-0001", "api-0002"}, component: "api", separator: "-", width: 4, want: "api-0003"},
+		{name: "per component", existing: []string{"api-0007", "web-0002"}, component: "web", separator: "-", width: 4, want: "web-0003"},
+		{name: "other schemes ignored", existing: []string{"2024.06.009-api", "api-v2", "api-0001-rc"}, component: "api", separator: "-", width: 4, want: "api-0001"},
+		{name: "prefix of another component", existing: []string{"api-gw-0005"}, component: "api", separator: "-", width: 4, want: "api-0001"},
+		{name: "unpadded", existing: []string{"api-9"}, component: "api", separator: "-", width: 4, want: "api-0010"},
+		{name: "past the width", existing: []string{"api-99"}, component: "api", separator: "-", width: 2, want: "api-100"},
+		{name: "separator", existing: []string{"api_0004", "api-0009"}, component: "api", separator: "_", width: 4, want: "api_0005"},
+		{name: "no component", separator: "-", width: 4, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			for _, tag := range tt.existing {
+				tagHead(t, repo, tag, "")
+			}
+			rm := newTestManager(t, dir)
+			got, err := rm.GetProposedCounter(tt.component, tt.separator, tt.width)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetProposedCounter() error = %v, want error %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetProposedCounter() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package release
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cactus/gostrftime"
+)
+
+// DefaultDateFormat is the strftime format of the date part of date releases,
+// 2024.06.001
+const DefaultDateFormat = "%Y.%m."
+
+// dateDirectives are the strftime directives allowed in a date format and the
+// pattern matching what each produces. %Y and %y are both the year, the two
+// digit year still orders correctly within a century.
+var dateDirectives = map[byte]string{
+	'Y': `(?P<year>\d{4})`,
+	'y': `(?P<year>\d{2})`,
+	'm': `(?P<month>\d{2})`,
+	'd': `(?P<day>\d{2})`,
+	'j': `(?P<yday>\d{3})`,
+}
+
+// dateKeyOrder is the order of the parts of a date release when comparing
+// them, regardless of where the format puts them (%d.%m.%Y.)
+var dateKeyOrder = []string{"year", "month", "day", "yday", "release"}
+
+// dateSeparators can end a date format, a format ending in anything else gets
+// a "." so the release number doesn't run into the date (2024.06001)
+const dateSeparators = ".-_"
+
+// validateDateFormat returns an error if format would produce tags that
+// can't be told apart or read back: no year, unsupported or repeated
+// directives and characters that git doesn't allow in tag names
+func validateDateFormat(format string) error {
+	if format == "" {
+		return fmt.Errorf("the date format is empty")
+	}
+	seen := map[string]bool{}
+	for idx := 0; idx < len(format); idx++ {
+		c := format[idx]
+		if c != '%' {
+			if !isDateLiteral(c) {
+				return fmt.Errorf("date format %q contains %q, only letters, digits and %s can be used between directives", format, c, dateSeparators)
+			}
+			continue
+		}
+		if idx == len(format)-1 {
+			return fmt.Errorf("date format %q ends with an incomplete directive", format)
+		}
+		idx++
+		pattern, ok := dateDirectives[format[idx]]
+		if !ok {
+			return fmt.Errorf("date format %q uses %%%c, only %%Y, %%y, %%m, %%d and %%j are supported", format, format[idx])
+		}
+		name := directiveName(pattern)
+		if seen[name] {
+			return fmt.Errorf("date format %q has more than one %s", format, name)
+		}
+		seen[name] = true
+	}
+	if !seen["year"] {
+		return fmt.Errorf("date format %q needs a year (%%Y or %%y), releases would collide from one year to the next", format)
+	}
+	return nil
+}
+
+func isDateLiteral(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte(dateSeparators, c) >= 0
+}
+
+// directiveName returns the group name of a dateDirectives pattern
+func directiveName(pattern string) string {
+	return pattern[strings.Index(pattern, "<")+1 : strings.Index(pattern, ">")]
+}
+
+// dateFormat returns the manager's date format, ending in a separator
+func (r *Manager) dateFormat() string {
+	format := r.timeFmt
+	if strings.IndexByte(dateSeparators, format[len(format)-1]) < 0 {
+		format += "."
+	}
+	return format
+}
+
+// datePeriod returns the date part of the releases made at now, releases
+// with the same period share a counter
+func (r *Manager) datePeriod(now time.Time) string {
+	return gostrftime.Format(r.dateFormat(), now)
+}
+
+// dateFormatPattern returns the (unanchored) pattern matching the date part
+// produced by the manager's date format
+func (r *Manager) dateFormatPattern() string {
+	format := r.dateFormat()
+	var b strings.Builder
+	for idx := 0; idx < len(format); idx++ {
+		if format[idx] == '%' && idx+1 < len(format) {
+			idx++
+			b.WriteString(dateDirectives[format[idx]])
+			continue
+		}
+		b.WriteString(regexp.QuoteMeta(format[idx : idx+1]))
+	}
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package release
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// emptyTree is the hash of the empty tree, the first release is diffed
+// against it
+const emptyTree = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+
+// DiffStat summarizes the size of the changes between two revisions
+type DiffStat struct {
+	Files      int
+	Insertions int
+	Deletions  int
+}
+
+// String formats the summary the way git diff --shortstat does, for example
+// "12 files changed, 340 insertions(+), 56 deletions(-)"
+func (d DiffStat) String() string {
+	plural := func(count int, word string) string {
+		if count == 1 {
+			return fmt.Sprintf("%d %s", count, word)
+		}
+		return fmt.Sprintf("%d %ss", count, word)
+	}
+	parts := []string{plural(d.Files, "file") + " changed"}
+	if d.Insertions > 0 || d.Deletions == 0 {
+		parts = append(parts, plural(d.Insertions, "insertion")+"(+)")
+	}
+	if d.Deletions > 0 {
+		parts = append(parts, plural(d.Deletions, "deletion")+"(-)")
+	}
+	return strings.Join(parts, ", ")
+}
+
+var patShortStat = regexp.MustCompile(`(\d+) (file|insertion|deletion)`)
+
+// DiffStat computes the diffstat between fromTag and toRef. If fromTag is
+// empty (the first release) everything in toRef counts as inserted. go-git's
+// line diffs can be a lot coarser than git's, so this uses git diff
+// --shortstat to get the numbers people see from git.
+func (r *Manager) DiffStat(fromTag, toRef string) (DiffStat, error) {
+	stat := DiffStat{}
+	from := emptyTree
+	if fromTag != "" {
+		commit, err := r.ResolveCommit(fromTag)
+		if err != nil {
+			return stat, err
+		}
+		from = commit
+	}
+	to, err := r.ResolveCommit(toRef)
+	if err != nil {
+		return stat, err
+	}
+	output, err := r.runGit("diff", "--shortstat", from, to)
+	if err != nil {
+		return stat, err
+	}
+	for _, results := range patShortStat.FindAllStringSubmatch(output, -1) {
+		count, _ := strconv.Atoi(results[1])
+		switch results[2] {
+		case "file":
+			stat.Files = count
+		case "insertion":
+			stat.Insertions = count
+		case "deletion":
+			stat.Deletions = count
+		}
+	}
+	return stat, nil
+}
@@ -0,0 +1,93 @@
+package release
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+func TestDiffStatString(t *testing.T) {
+	tests := []struct {
+		stat DiffStat
+		want string
+	}{
+		{stat: DiffStat{Files: 12, Insertions: 340, Deletions: 56}, want: "12 files changed, 340 insertions(+), 56 deletions(-)"},
+		{stat: DiffStat{Files: 1, Insertions: 1}, want: "1 file changed, 1 insertion(+)"},
+		{stat: DiffStat{Files: 3, Deletions: 2}, want: "3 files changed, 2 deletions(-)"},
+		{stat: DiffStat{Files: 2, Insertions: 1, Deletions: 1}, want: "2 files changed, 1 insertion(+), 1 deletion(-)"},
+		{stat: DiffStat{}, want: "0 files changed, 0 insertions(+)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.stat.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffStat(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("the diffstat is computed with the git command")
+	}
+	dir, repo := newTestRepo(t)
+	tagHead(t, repo, "2024.06.001", "")
+	writeFiles(t, repo, "Rework the docs", map[string]string{
+		"README":    "changed\n",
+		"a.txt":     "1\n2\n3\n",
+		"sub/b.txt": "b\n",
+	})
+	tagHead(t, repo, "2024.06.002", "")
+	writeFiles(t, repo, "Trim a", map[string]string{"a.txt": "1\n"})
+
+	tests := []struct {
+		name     string
+		from, to string
+		want     DiffStat
+	}{
+		{name: "since the previous release", from: "2024.06.001", to: "2024.06.002", want: DiffStat{Files: 3, Insertions: 5, Deletions: 1}},
+		{name: "deletions only", from: "2024.06.002", to: "HEAD", want: DiffStat{Files: 1, Deletions: 2}},
+		{name: "first release", to: "2024.06.001", want: DiffStat{Files: 1, Insertions: 1}},
+		{name: "nothing changed", from: "2024.06.002", to: "2024.06.002", want: DiffStat{}},
+	}
+	rm := newTestManager(t, dir)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rm.DiffStat(tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("DiffStat() error = %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("DiffStat() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// writeFiles replaces the content of the files in the repository's worktree
+// and commits them together
+func writeFiles(t *testing.T, repo *git.Repository, message string, files map[string]string) {
+	t.Helper()
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(w.Filesystem.Root(), name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Add(name); err != nil {
+			t.Fatalf("failed to add %s: %s", name, err)
+		}
+	}
+	if _, err := w.Commit(message, &git.CommitOptions{Author: testSignature, Committer: testSignature}); err != nil {
+		t.Fatalf("failed to commit: %s", err)
+	}
+}
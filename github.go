@@ -0,0 +1,322 @@
+package release
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// DefaultGitHubAPI is the GitHub REST API used unless $GITHUB_API_URL is set
+const DefaultGitHubAPI = "https://api.github.com"
+
+// ErrGitHubReleaseExists is returned by CreateGitHubRelease if the tag
+// already has a release
+var ErrGitHubReleaseExists = errors.New("the GitHub release already exists")
+
+// GitHubRepo returns the owner and name of the GitHub repository a remote URL
+// points at (git@github.com:owner/repo.git, https://github.com/owner/repo),
+// ok is false if it isn't a github.com remote
+func GitHubRepo(url string) (owner, repo string, ok bool) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil || !strings.EqualFold(endpoint.Host, "github.com") {
+		return "", "", false
+	}
+	parts := strings.Split(strings.Trim(strings.TrimSuffix(endpoint.Path, ".git"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// GitHubRelease is a release to create for a pushed tag
+type GitHubRelease struct {
+	Tag        string `json:"tag_name"`
+	Name       string `json:"name"`
+	Body       string `json:"body"`
+	Prerelease bool   `json:"prerelease"`
+	Draft      bool   `json:"draft"` // Not published until PublishGitHubDraft
+	// Assets are the paths of files uploaded to the release once it's created
+	Assets []string `json:"-"`
+}
+
+// ErrGitHubAssetUpload is returned by CreateGitHubRelease if the release was
+// created but one of its assets couldn't be uploaded
+var ErrGitHubAssetUpload = errors.New("uploading a GitHub release asset failed")
+
+// CreateGitHubRelease creates the release in owner/repo with the token and
+// returns its URL. The tag has to have been pushed already, GitHub would
+// otherwise create it at the default branch. If one of the assets can't be
+// uploaded the URL is returned with an ErrGitHubAssetUpload error, the release
+// exists without the assets from that one on.
+func CreateGitHubRelease(client *http.Client, owner, repo, token string, release GitHubRelease) (string, error) {
+	payload, err := json.Marshal(release)
+	if err != nil {
+		return "", err
+	}
+	resp, err := gitHubRequest(client, http.MethodPost, fmt.Sprintf("/repos/%s/%s/releases", owner, repo), token, payload)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		HTMLURL   string `json:"html_url"`
+		UploadURL string `json:"upload_url"`
+		Message   string `json:"message"`
+		Errors    []struct {
+			Code string `json:"code"`
+		} `json:"errors"`
+	}
+	// Error responses that aren't JSON still get their status reported
+	_ = json.NewDecoder(resp.Body).Decode(&result)
+	switch {
+	case resp.StatusCode == http.StatusCreated:
+		for _, asset := range release.Assets {
+			if err := uploadGitHubAsset(client, result.UploadURL, token, asset); err != nil {
+				return result.HTMLURL, fmt.Errorf("%w to the release of %s in %s/%s: %v", ErrGitHubAssetUpload, release.Tag, owner, repo, err)
+			}
+		}
+		return result.HTMLURL, nil
+	case resp.StatusCode == http.StatusUnprocessableEntity && len(result.Errors) > 0 && result.Errors[0].Code == "already_exists":
+		return "", fmt.Errorf("%w for tag %s in %s/%s", ErrGitHubReleaseExists, release.Tag, owner, repo)
+	case resp.StatusCode == http.StatusUnauthorized:
+		return "", fmt.Errorf("GitHub rejected the token as invalid or expired: %s", result.Message)
+	default:
+		return "", fmt.Errorf("creating the release of %s in %s/%s failed with %s: %s", release.Tag, owner, repo, resp.Status, result.Message)
+	}
+}
+
+// ErrGitHubDraftNotFound is returned by PublishGitHubDraft if the tag has no
+// draft release
+var ErrGitHubDraftNotFound = errors.New("no GitHub draft release")
+
+// PublishGitHubDraft publishes the draft release of tag in owner/repo (see
+// GitHubRelease.Draft) and returns its URL. Drafts can't be looked up by tag,
+// they're searched for in the latest 100 releases.
+func PublishGitHubDraft(client *http.Client, owner, repo, token, tag string) (string, error) {
+	resp, err := gitHubRequest(client, http.MethodGet, fmt.Sprintf("/repos/%s/%s/releases?per_page=100", owner, repo), token, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("listing the releases of %s/%s failed with %s", owner, repo, resp.Status)
+	}
+	var releases []struct {
+		ID    int64  `json:"id"`
+		Tag   string `json:"tag_name"`
+		Draft bool   `json:"draft"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return "", fmt.Errorf("failed to read the releases of %s/%s: %w", owner, repo, err)
+	}
+	id := int64(-1)
+	for _, release := range releases {
+		if release.Draft && release.Tag == tag {
+			id = release.ID
+			break
+		}
+	}
+	if id < 0 {
+		return "", fmt.Errorf("%w for tag %s in %s/%s", ErrGitHubDraftNotFound, tag, owner, repo)
+	}
+
+	resp, err = gitHubRequest(client, http.MethodPatch, fmt.Sprintf("/repos/%s/%s/releases/%d", owner, repo, id), token, []byte(`{"draft":false}`))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		HTMLURL string `json:"html_url"`
+		Message string `json:"message"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("publishing the draft release of %s in %s/%s failed with %s: %s", tag, owner, repo, resp.Status, result.Message)
+	}
+	return result.HTMLURL, nil
+}
+
+// GitHubPullRequest is a merged pull request, see PullRequestNotes
+type GitHubPullRequest struct {
+	Number int
+	Title  string
+	Labels []string
+}
+
+// GitHubPullRequests returns the merged pull requests of owner/repo the commit
+// sha belongs to. Commits pushed straight to a branch have none.
+func GitHubPullRequests(client *http.Client, owner, repo, token, sha string) ([]GitHubPullRequest, error) {
+	resp, err := gitHubRequest(client, http.MethodGet, fmt.Sprintf("/repos/%s/%s/commits/%s/pulls", owner, repo, sha), token, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var result struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		if resp.StatusCode == http.StatusUnauthorized {
+			return nil, fmt.Errorf("GitHub rejected the token as invalid or expired: %s", result.Message)
+		}
+		return nil, fmt.Errorf("looking up the pull requests of %s in %s/%s failed with %s: %s", sha, owner, repo, resp.Status, result.Message)
+	}
+	var pulls []struct {
+		Number   int     `json:"number"`
+		Title    string  `json:"title"`
+		MergedAt *string `json:"merged_at"`
+		Labels   []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&pulls); err != nil {
+		return nil, fmt.Errorf("failed to read the pull requests of %s in %s/%s: %w", sha, owner, repo, err)
+	}
+	merged := []GitHubPullRequest{}
+	for _, pull := range pulls {
+		// Open pull requests containing the commit aren't what released it
+		if pull.MergedAt == nil {
+			continue
+		}
+		pr := GitHubPullRequest{Number: pull.Number, Title: pull.Title, Labels: []string{}}
+		for _, label := range pull.Labels {
+			pr.Labels = append(pr.Labels, label.Name)
+		}
+		merged = append(merged, pr)
+	}
+	return merged, nil
+}
+
+// PullRequestNotes looks up the merged pull requests of owner/repo that the
+// commits between fromTag and toRef belong to (see changelogCommits) and
+// returns each of them once, newest first. The subjects of commits that don't
+// belong to one are returned in other.
+func (r *Manager) PullRequestNotes(client *http.Client, owner, repo, token, fromTag, toRef string) (prs []GitHubPullRequest, other []string, err error) {
+	commits, err := r.changelogCommits(fromTag, toRef)
+	if err != nil {
+		return nil, nil, err
+	}
+	prs, other = []GitHubPullRequest{}, []string{}
+	seen := map[int]bool{}
+	for _, c := range commits {
+		commitPRs, err := GitHubPullRequests(client, owner, repo, token, c.Hash.String())
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(commitPRs) == 0 {
+			other = append(other, commitSubject(c.Message))
+		}
+		for _, pr := range commitPRs {
+			// Every commit of a pull request (and its merge commit) finds it
+			if !seen[pr.Number] {
+				seen[pr.Number] = true
+				prs = append(prs, pr)
+			}
+		}
+	}
+	return prs, other, nil
+}
+
+// FormatPullRequestNotes formats pull requests into a message suitable for an
+// annotated tag. Each one is listed under a heading of its first label, the
+// headings sorted, with unlabeled ones first. Other entries (if any) are
+// listed under their own heading last, like FormatReleaseNotes.
+func FormatPullRequestNotes(prs []GitHubPullRequest, other []string) string {
+	unlabeled := []string{}
+	groups := map[string][]string{}
+	for _, pr := range prs {
+		entry := fmt.Sprintf("%s (#%d)", pr.Title, pr.Number)
+		if len(pr.Labels) == 0 {
+			unlabeled = append(unlabeled, entry)
+			continue
+		}
+		groups[pr.Labels[0]] = append(groups[pr.Labels[0]], entry)
+	}
+	labels := []string{}
+	for label := range groups {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	sections := []string{}
+	if len(unlabeled) > 0 {
+		sections = append(sections, FormatChangelog(unlabeled))
+	}
+	for _, label := range labels {
+		sections = append(sections, fmt.Sprintf("%s:\n%s", label, FormatChangelog(groups[label])))
+	}
+	if len(other) > 0 {
+		sections = append(sections, fmt.Sprintf("Other:\n%s", FormatChangelog(other)))
+	}
+	return strings.Join(sections, "\n\n")
+}
+
+// uploadGitHubAsset uploads the file at path to a release, uploadURL is the
+// URI template GitHub returns for the release
+// (https://uploads.github.com/repos/owner/repo/releases/1/assets{?name,label})
+// and is given the file name
+func uploadGitHubAsset(client *http.Client, uploadURL, token, path string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(path)
+	if idx := strings.Index(uploadURL, "{"); idx >= 0 {
+		uploadURL = uploadURL[:idx]
+	}
+	req, err := http.NewRequest(http.MethodPost, uploadURL+"?name="+url.QueryEscape(name), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		var result struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&result)
+		return fmt.Errorf("uploading %s failed with %s: %s", name, resp.Status, result.Message)
+	}
+	return nil
+}
+
+// gitHubRequest sends a request to path of the GitHub API ($GITHUB_API_URL or
+// DefaultGitHubAPI) authenticated with token, payload is the JSON body if set
+func gitHubRequest(client *http.Client, method, path, token string, payload []byte) (*http.Response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	api := os.Getenv("GITHUB_API_URL")
+	if api == "" {
+		api = DefaultGitHubAPI
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(api, "/")+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return client.Do(req)
+}
@@ -0,0 +1,355 @@
+package release
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeGitHub serves the release endpoints of the GitHub API for owner/repo,
+// with the releases it was created with and the ones created through it
+type fakeGitHub struct {
+	t            *testing.T
+	releases     []map[string]interface{}
+	patched      map[string]interface{} // Body of the last PATCH
+	assets       []fakeAsset
+	rejectAssets bool
+	pulls        map[string][]map[string]interface{} // Pull requests by commit hash
+	pullLookups  int
+}
+
+// fakeAsset is an asset uploaded to a fakeGitHub
+type fakeAsset struct {
+	path        string // Request path, with the release ID
+	name        string
+	contentType string
+	data        string
+}
+
+func (f *fakeGitHub) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"message":"Bad credentials"}`)
+		return
+	}
+	switch {
+	case req.Method == http.MethodPost && req.URL.Path == "/repos/owner/repo/releases":
+		var release map[string]interface{}
+		if err := json.NewDecoder(req.Body).Decode(&release); err != nil {
+			f.t.Errorf("bad request body: %s", err)
+		}
+		for _, existing := range f.releases {
+			if existing["tag_name"] == release["tag_name"] {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				fmt.Fprint(w, `{"message":"Validation Failed","errors":[{"code":"already_exists"}]}`)
+				return
+			}
+		}
+		release["id"] = len(f.releases) + 1
+		f.releases = append(f.releases, release)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"html_url":"https://github.com/owner/repo/releases/tag/%s","upload_url":"http://%s/repos/owner/repo/releases/%d/assets{?name,label}"}`, release["tag_name"], req.Host, release["id"])
+	case req.Method == http.MethodPost && strings.HasPrefix(req.URL.Path, "/repos/owner/repo/releases/") && strings.HasSuffix(req.URL.Path, "/assets"):
+		if f.rejectAssets {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"message":"Validation Failed"}`)
+			return
+		}
+		data, _ := io.ReadAll(req.Body)
+		f.assets = append(f.assets, fakeAsset{path: req.URL.Path, name: req.URL.Query().Get("name"), contentType: req.Header.Get("Content-Type"), data: string(data)})
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{}`)
+	case req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/repos/owner/repo/commits/") && strings.HasSuffix(req.URL.Path, "/pulls"):
+		f.pullLookups++
+		pulls := f.pulls[strings.TrimSuffix(strings.TrimPrefix(req.URL.Path, "/repos/owner/repo/commits/"), "/pulls")]
+		if pulls == nil {
+			pulls = []map[string]interface{}{}
+		}
+		json.NewEncoder(w).Encode(pulls)
+	case req.Method == http.MethodGet && req.URL.Path == "/repos/owner/repo/releases":
+		json.NewEncoder(w).Encode(f.releases)
+	case req.Method == http.MethodPatch && strings.HasPrefix(req.URL.Path, "/repos/owner/repo/releases/"):
+		f.patched = map[string]interface{}{}
+		json.NewDecoder(req.Body).Decode(&f.patched)
+		fmt.Fprintf(w, `{"html_url":"https://github.com/owner/repo/releases/%s"}`, strings.TrimPrefix(req.URL.Path, "/repos/owner/repo/releases/"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"Not Found"}`)
+	}
+}
+
+// newFakeGitHub starts a fakeGitHub with the releases and points
+// $GITHUB_API_URL at it
+func newFakeGitHub(t *testing.T, releases ...map[string]interface{}) *fakeGitHub {
+	t.Helper()
+	fake := &fakeGitHub{t: t, releases: releases}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	t.Setenv("GITHUB_API_URL", server.URL)
+	return fake
+}
+
+func TestCreateGitHubRelease(t *testing.T) {
+	tests := []struct {
+		name    string
+		release GitHubRelease
+		token   string
+		wantErr error
+		wantMsg string
+	}{
+		{name: "release", release: GitHubRelease{Tag: "2024.06.001", Name: "2024.06.001", Body: "notes"}, token: "token"},
+		{name: "draft", release: GitHubRelease{Tag: "2024.06.001", Draft: true}, token: "token"},
+		{name: "prerelease", release: GitHubRelease{Tag: "1.2.0-rc1", Prerelease: true}, token: "token"},
+		{name: "already exists", release: GitHubRelease{Tag: "2024.05.001"}, token: "token", wantErr: ErrGitHubReleaseExists},
+		{name: "bad token", release: GitHubRelease{Tag: "2024.06.001"}, token: "bad", wantMsg: "invalid or expired"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, map[string]interface{}{"tag_name": "2024.05.001"})
+			url, err := CreateGitHubRelease(nil, "owner", "repo", tt.token, tt.release)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreateGitHubRelease() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantMsg != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantMsg) {
+					t.Fatalf("CreateGitHubRelease() error = %v, want one containing %q", err, tt.wantMsg)
+				}
+				return
+			case err != nil:
+				t.Fatalf("CreateGitHubRelease() error = %s", err)
+			}
+			if want := "https://github.com/owner/repo/releases/tag/" + tt.release.Tag; url != want {
+				t.Errorf("URL = %s, want %s", url, want)
+			}
+			created := fake.releases[len(fake.releases)-1]
+			if created["draft"] != tt.release.Draft || created["prerelease"] != tt.release.Prerelease || created["body"] != tt.release.Body {
+				t.Errorf("request body = %v, want %+v", created, tt.release)
+			}
+		})
+	}
+}
+
+func TestCreateGitHubReleaseAssets(t *testing.T) {
+	dir := t.TempDir()
+	sbom := filepath.Join(dir, "sbom.json")
+	if err := os.WriteFile(sbom, []byte(`{"bomFormat":"CycloneDX"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	notes := filepath.Join(dir, "notes with spaces.txt")
+	if err := os.WriteFile(notes, []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		assets  []string
+		reject  bool
+		want    []fakeAsset
+		wantErr bool // The release is created either way
+	}{
+		{name: "none"},
+		{name: "sbom", assets: []string{sbom}, want: []fakeAsset{{path: "/repos/owner/repo/releases/2/assets", name: "sbom.json", contentType: "application/octet-stream", data: `{"bomFormat":"CycloneDX"}`}}},
+		{name: "several", assets: []string{sbom, notes}, want: []fakeAsset{
+			{path: "/repos/owner/repo/releases/2/assets", name: "sbom.json", contentType: "application/octet-stream", data: `{"bomFormat":"CycloneDX"}`},
+			{path: "/repos/owner/repo/releases/2/assets", name: "notes with spaces.txt", contentType: "application/octet-stream", data: "notes"},
+		}},
+		{name: "missing file", assets: []string{filepath.Join(dir, "missing.json")}, wantErr: true},
+		{name: "rejected", assets: []string{sbom}, reject: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, map[string]interface{}{"tag_name": "2024.05.001"})
+			fake.rejectAssets = tt.reject
+			url, err := CreateGitHubRelease(nil, "owner", "repo", "token", GitHubRelease{Tag: "2024.06.001", Assets: tt.assets})
+			if tt.wantErr != errors.Is(err, ErrGitHubAssetUpload) {
+				t.Fatalf("CreateGitHubRelease() error = %v, want ErrGitHubAssetUpload = %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("CreateGitHubRelease() error = %s", err)
+			}
+			if want := "https://github.com/owner/repo/releases/tag/2024.06.001"; url != want {
+				t.Errorf("URL = %s, want %s", url, want)
+			}
+			if len(fake.releases) != 2 {
+				t.Errorf("%d releases, want the created one too", len(fake.releases))
+			}
+			if !reflect.DeepEqual(fake.assets, tt.want) {
+				t.Errorf("uploaded %+v, want %+v", fake.assets, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishGitHubDraft(t *testing.T) {
+	releases := []map[string]interface{}{
+		{"id": 1, "tag_name": "2024.06.001", "draft": false},
+		{"id": 2, "tag_name": "2024.06.002", "draft": true},
+	}
+	tests := []struct {
+		name    string
+		tag     string
+		token   string
+		want    string
+		wantErr error
+		wantMsg string
+	}{
+		{name: "draft", tag: "2024.06.002", token: "token", want: "https://github.com/owner/repo/releases/2"},
+		{name: "already published", tag: "2024.06.001", token: "token", wantErr: ErrGitHubDraftNotFound},
+		{name: "no release", tag: "2024.06.003", token: "token", wantErr: ErrGitHubDraftNotFound},
+		{name: "bad token", tag: "2024.06.002", token: "bad", wantMsg: "401"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeGitHub(t, releases...)
+			url, err := PublishGitHubDraft(nil, "owner", "repo", tt.token, tt.tag)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("PublishGitHubDraft() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantMsg != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantMsg) {
+					t.Fatalf("PublishGitHubDraft() error = %v, want one containing %q", err, tt.wantMsg)
+				}
+				return
+			case err != nil:
+				t.Fatalf("PublishGitHubDraft() error = %s", err)
+			}
+			if url != tt.want {
+				t.Errorf("URL = %s, want %s", url, tt.want)
+			}
+			if draft, ok := fake.patched["draft"]; !ok || draft != false {
+				t.Errorf("PATCH body = %v, want draft false", fake.patched)
+			}
+		})
+	}
+}
+
+// fakePull is a pull request as the GitHub API returns it, merged unless
+// mergedAt is empty
+func fakePull(number int, title, mergedAt string, labels ...string) map[string]interface{} {
+	pull := map[string]interface{}{"number": number, "title": title, "merged_at": nil, "labels": []map[string]string{}}
+	if mergedAt != "" {
+		pull["merged_at"] = mergedAt
+	}
+	for _, label := range labels {
+		pull["labels"] = append(pull["labels"].([]map[string]string), map[string]string{"name": label})
+	}
+	return pull
+}
+
+func TestGitHubPullRequests(t *testing.T) {
+	tests := []struct {
+		name    string
+		pulls   []map[string]interface{}
+		token   string
+		want    []GitHubPullRequest
+		wantMsg string
+	}{
+		{name: "none", token: "token", want: []GitHubPullRequest{}},
+		{name: "merged", pulls: []map[string]interface{}{fakePull(12, "Fix the login", "2024-06-02T12:00:00Z", "bug", "auth")}, token: "token", want: []GitHubPullRequest{{Number: 12, Title: "Fix the login", Labels: []string{"bug", "auth"}}}},
+		{name: "open left out", pulls: []map[string]interface{}{fakePull(13, "Draft work", ""), fakePull(12, "Fix the login", "2024-06-02T12:00:00Z")}, token: "token", want: []GitHubPullRequest{{Number: 12, Title: "Fix the login", Labels: []string{}}}},
+		{name: "bad token", token: "bad", wantMsg: "invalid or expired"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newFakeGitHub(t)
+			fake.pulls = map[string][]map[string]interface{}{"abc123": tt.pulls}
+			got, err := GitHubPullRequests(nil, "owner", "repo", tt.token, "abc123")
+			if tt.wantMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantMsg) {
+					t.Fatalf("GitHubPullRequests() error = %v, want one containing %q", err, tt.wantMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GitHubPullRequests() error = %s", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GitHubPullRequests() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPullRequestNotes(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	tagHead(t, repo, "2024.06.001", "")
+	login := commitFile(t, repo, "file.txt", "Fix the login")
+	loginTests := commitFile(t, repo, "file.txt", "Test the login")
+	direct := commitFile(t, repo, "file.txt", "Bump the version")
+	export := commitFile(t, repo, "file.txt", "Add exports")
+
+	fake := newFakeGitHub(t)
+	loginPR := fakePull(12, "Fix logging in with SSO", "2024-06-02T12:00:00Z", "bug")
+	fake.pulls = map[string][]map[string]interface{}{
+		login.String():      {loginPR},
+		loginTests.String(): {loginPR},
+		export.String():     {fakePull(15, "Export to CSV", "2024-06-03T12:00:00Z", "feature")},
+	}
+	rm := newTestManager(t, dir)
+
+	prs, other, err := rm.PullRequestNotes(nil, "owner", "repo", "token", "2024.06.001", "HEAD")
+	if err != nil {
+		t.Fatalf("PullRequestNotes() error = %s", err)
+	}
+	want := []GitHubPullRequest{
+		{Number: 15, Title: "Export to CSV", Labels: []string{"feature"}},
+		{Number: 12, Title: "Fix logging in with SSO", Labels: []string{"bug"}},
+	}
+	if !reflect.DeepEqual(prs, want) {
+		t.Errorf("pull requests = %+v, want %+v", prs, want)
+	}
+	if !reflect.DeepEqual(other, []string{"Bump the version"}) {
+		t.Errorf("other = %v, want the commit without a pull request (%s)", other, direct)
+	}
+	if fake.pullLookups != 4 {
+		t.Errorf("looked up %d commits, want the 4 since the release", fake.pullLookups)
+	}
+
+	if _, _, err := rm.PullRequestNotes(nil, "owner", "repo", "bad", "2024.06.001", "HEAD"); err == nil {
+		t.Error("PullRequestNotes() with a rejected token succeeded, want an error")
+	}
+}
+
+func TestFormatPullRequestNotes(t *testing.T) {
+	tests := []struct {
+		name  string
+		prs   []GitHubPullRequest
+		other []string
+		want  string
+	}{
+		{name: "empty", want: ""},
+		{name: "unlabeled", prs: []GitHubPullRequest{{Number: 3, Title: "Tidy up"}}, want: "- Tidy up (#3)"},
+		{
+			name: "grouped by first label",
+			prs: []GitHubPullRequest{
+				{Number: 15, Title: "Export to CSV", Labels: []string{"feature"}},
+				{Number: 14, Title: "Tidy up"},
+				{Number: 12, Title: "Fix logging in", Labels: []string{"bug", "feature"}},
+				{Number: 11, Title: "Import from CSV", Labels: []string{"feature"}},
+			},
+			want: "- Tidy up (#14)\n\nbug:\n- Fix logging in (#12)\n\nfeature:\n- Export to CSV (#15)\n- Import from CSV (#11)",
+		},
+		{name: "with other", prs: []GitHubPullRequest{{Number: 12, Title: "Fix logging in", Labels: []string{"bug"}}}, other: []string{"Bump the version"}, want: "bug:\n- Fix logging in (#12)\n\nOther:\n- Bump the version"},
+		{name: "only other", other: []string{"Bump the version"}, want: "Other:\n- Bump the version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatPullRequestNotes(tt.prs, tt.other); got != tt.want {
+				t.Errorf("FormatPullRequestNotes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,16 +3,18 @@ module release
 go 1.19
 
 require (
+	github.com/ProtonMail/go-crypto v0.0.0-20221026131551-cf6655e29de4
 	github.com/cactus/gostrftime v0.0.0-20190922123236-884915fd58c8
 	github.com/go-git/go-git/v5 v5.5.2
 	github.com/rs/zerolog v1.19.0
 	github.com/spf13/pflag v1.0.5
 	golang.org/x/crypto v0.5.0
+	golang.org/x/term v0.4.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/Microsoft/go-winio v0.6.0 // indirect
-	github.com/ProtonMail/go-crypto v0.0.0-20221026131551-cf6655e29de4 // indirect
 	github.com/acomagu/bufpipe v1.0.3 // indirect
 	github.com/cloudflare/circl v1.3.1 // indirect
 	github.com/emirpasic/gods v1.18.1 // indirect
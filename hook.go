@@ -0,0 +1,31 @@
+package release
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// RunHook runs command with sh in the root of the repository, with env
+// (NAME=value) added to the environment. What it writes to stdout is passed
+// on as it runs, its stderr is held back and included in the error if it
+// exits non-zero.
+func (r *Manager) RunHook(command string, env []string, stdout, stderr io.Writer) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Dir = r.repoDir
+	cmd.Env = append(os.Environ(), env...)
+	var captured bytes.Buffer
+	cmd.Stdout = stdout
+	cmd.Stderr = &captured
+	if err := cmd.Run(); err != nil {
+		if output := strings.TrimSpace(captured.String()); output != "" {
+			return fmt.Errorf("%w, stderr:\n%s", err, output)
+		}
+		return err
+	}
+	_, err := stderr.Write(captured.Bytes())
+	return err
+}
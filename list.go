@@ -0,0 +1,250 @@
+package release
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// treeSegment matches the optional tree hash marker (see TreeHashSegment)
+// that follows the release number
+const treeSegment = `(?:-t(?P<tree>[0-9a-f]{7}))?`
+
+// TreeHashSegment returns the marker for the tree of the given revision that
+// goes after the release number, e.g. "t1a2b3c" for 2024.05.003-t1a2b3c.
+// Releases of identical content get the same marker.
+func (r *Manager) TreeHashSegment(rev string) (string, error) {
+	commit, err := r.resolveCommit(rev)
+	if err != nil {
+		return "", err
+	}
+	return "t" + commit.TreeHash.String()[:7], nil
+}
+
+// patDateVersion matches a date based release with an optional component
+var patDateVersion = regexp.MustCompile(`^(?P<year>\d{4})\.(?P<month>\d{2})\.(?P<release>\d+)` + treeSegment + `(?:-(?P<component>.+))?$`)
+
+// dateVersionPattern returns patDateVersion, adjusted for the manager's date
+// format and number prefix and suffix if any of them are set
+func (r *Manager) dateVersionPattern() *regexp.Regexp {
+	if r.dateFormat() == DefaultDateFormat && r.NumberPrefix == "" && r.NumberSuffix == "" {
+		return patDateVersion
+	}
+	return regexp.MustCompile(`^` + r.dateFormatPattern() + regexp.QuoteMeta(r.NumberPrefix) + `(?P<release>\d+)` + regexp.QuoteMeta(r.NumberSuffix) + treeSegment + `(?:-(?P<component>.+))?$`)
+}
+
+// patSemVersion matches a semver based release with an optional branch
+// prefix, release number and component. Leading zeros aren't valid semver,
+// which also keeps date releases (2024.06.001) from matching.
+var patSemVersion = regexp.MustCompile(`^(?:(?P<branch>.+)-)?` + semVersionBody)
+
+// semVersionBody is everything after the branch segment of a semver release
+const semVersionBody = `(?P<major>0|[1-9]\d*)\.(?P<minor>0|[1-9]\d*)\.(?P<patch>0|[1-9]\d*)(?:-(?:rc(?P<rc>\d+)|(?P<release>\d+)))?` + treeSegment + `(?:-(?P<component>.+))?$`
+
+// semVersionPattern returns patSemVersion, adjusted for the manager's branch
+// separator if it isn't the default
+func (r *Manager) semVersionPattern() *regexp.Regexp {
+	if r.BranchSeparator == "" || r.BranchSeparator == "-" {
+		return patSemVersion
+	}
+	return regexp.MustCompile(`^(?:(?P<branch>.+)` + regexp.QuoteMeta(r.BranchSeparator) + `)?` + semVersionBody)
+}
+
+// parsedVersion is a release tag broken into the numeric parts used to order
+// it (year, month, release for date releases or major, minor, patch, stage,
+// release for semver releases, the stage puts release candidates first) and
+// the component it belongs to
+type parsedVersion struct {
+	key        []uint64
+	component  string
+	hasRelease bool // Bounds like 1.2.0 don't have a release number
+	candidate  bool // A semver release candidate, 1.2.0-rc2
+}
+
+// parseVersion parses a release tag of the manager's scheme, ok is false if
+// the tag isn't a release of that scheme
+func (r *Manager) parseVersion(tag string) (version parsedVersion, ok bool) {
+	tag, ok = r.trimTagPrefix(tag)
+	if !ok {
+		return version, false
+	}
+	pattern := r.dateVersionPattern()
+	if r.SemVer {
+		pattern = r.semVersionPattern()
+	}
+	results := pattern.FindStringSubmatch(tag)
+	if results == nil {
+		return version, false
+	}
+	version.component = results[pattern.SubexpIndex("component")]
+	names := pattern.SubexpNames()
+	if !r.SemVer {
+		// The date format can put the parts in any order, the key always
+		// goes from the year down
+		names = dateKeyOrder
+	}
+	for _, name := range names {
+		idx := pattern.SubexpIndex(name)
+		switch {
+		case idx < 0, name == "branch", name == "tree", name == "component", name == "rc":
+		case name == "release" && r.SemVer:
+			stage, number := uint64(1), results[idx]
+			if rc := results[pattern.SubexpIndex("rc")]; rc != "" {
+				stage, number = 0, rc
+				version.candidate = true
+			}
+			version.hasRelease = number != ""
+			if !version.hasRelease {
+				version.key = append(version.key, 0, 0)
+				continue
+			}
+			value, err := strconv.ParseUint(number, 10, 64)
+			if err != nil {
+				return version, false
+			}
+			version.key = append(version.key, stage, value)
+		default:
+			// Date releases always have a release number
+			version.hasRelease = version.hasRelease || name == "release"
+			value, err := strconv.ParseUint(results[idx], 10, 64)
+			if err != nil {
+				return version, false
+			}
+			version.key = append(version.key, value)
+		}
+	}
+	return version, true
+}
+
+// ComponentOf returns the component of a release tag of the manager's scheme
+// or of a component scheme, an empty string for releases of the whole
+// repository or other tags
+func (r *Manager) ComponentOf(tag string) string {
+	if version, ok := r.parseVersion(tag); ok {
+		return version.component
+	}
+	for component := range r.ComponentSchemes {
+		if version, ok := r.ForComponent(component).parseVersion(tag); ok && version.component == component {
+			return component
+		}
+	}
+	return ""
+}
+
+// compareKeys compares two version keys, returning -1, 0 or 1
+func compareKeys(a, b []uint64) int {
+	for idx := 0; idx < len(a) && idx < len(b); idx++ {
+		if a[idx] < b[idx] {
+			return -1
+		}
+		if a[idx] > b[idx] {
+			return 1
+		}
+	}
+	return 0
+}
+
+// SortTags returns the tags that are releases of the manager's scheme for the
+// given component (all components if empty), ordered newest first
+func (r *Manager) SortTags(tags []string, component string) []string {
+	releases := []string{}
+	keys := map[string][]uint64{}
+	for _, tag := range tags {
+		version, ok := r.parseVersion(tag)
+		if !ok || (component != "" && version.component != component) {
+			continue
+		}
+		keys[tag] = version.key
+		releases = append(releases, tag)
+	}
+	sort.SliceStable(releases, func(i, j int) bool {
+		if cmp := compareKeys(keys[releases[i]], keys[releases[j]]); cmp != 0 {
+			return cmp > 0
+		}
+		return releases[i] < releases[j]
+	})
+	return releases
+}
+
+// ListReleases returns the releases of the manager's scheme for the given
+// component (all components if empty) ordered newest first. Date releases are
+// ordered by date and increment and semver releases by version precedence.
+func (r *Manager) ListReleases(component string) []Release {
+	tags := []string{}
+	byTag := map[string]Release{}
+	for _, release := range r.releases {
+		tags = append(tags, release.Tag)
+		byTag[release.Tag] = release
+	}
+	releases := []Release{}
+	for _, tag := range r.SortTags(tags, component) {
+		releases = append(releases, byTag[tag])
+	}
+	return releases
+}
+
+// ErrNoRelease is returned by GetLatestRelease if there is no release yet
+var ErrNoRelease = errors.New("no release yet")
+
+// GetLatestRelease returns the tag of the newest release of the component
+// (of any component if empty) with the component's scheme, ordered like
+// ListReleases. ErrNoRelease is returned if it hasn't been released yet.
+func (r *Manager) GetLatestRelease(component string) (string, error) {
+	releases := r.ForComponent(component).ListReleases(component)
+	if len(releases) == 0 {
+		if component != "" {
+			return "", fmt.Errorf("%w of component %s", ErrNoRelease, component)
+		}
+		return "", ErrNoRelease
+	}
+	return releases[0].Tag, nil
+}
+
+// ReleasesInRange filters releases to those whose version falls between from
+// and to (inclusive), either bound may be empty to leave that side open. For
+// semver a bound without a release number (1.2.0) covers every release of
+// that version.
+func (r *Manager) ReleasesInRange(releases []Release, from, to string) ([]Release, error) {
+	var fromKey, toKey []uint64
+	if from != "" {
+		version, ok := r.parseVersion(from)
+		if !ok {
+			return nil, fmt.Errorf("invalid lower bound '%s' for this release scheme", from)
+		}
+		fromKey = version.key
+	}
+	if to != "" {
+		version, ok := r.parseVersion(to)
+		if !ok {
+			return nil, fmt.Errorf("invalid upper bound '%s' for this release scheme", to)
+		}
+		if !version.hasRelease {
+			// Past the stage and release number of every release
+			version.key[len(version.key)-2] = math.MaxUint64
+			version.key[len(version.key)-1] = math.MaxUint64
+		}
+		toKey = version.key
+	}
+	if fromKey != nil && toKey != nil && compareKeys(fromKey, toKey) > 0 {
+		return nil, fmt.Errorf("invalid range, '%s' is after '%s'", from, to)
+	}
+
+	filtered := []Release{}
+	for _, release := range releases {
+		version, ok := r.parseVersion(release.Tag)
+		if !ok {
+			continue
+		}
+		if fromKey != nil && compareKeys(version.key, fromKey) < 0 {
+			continue
+		}
+		if toKey != nil && compareKeys(version.key, toKey) > 0 {
+			continue
+		}
+		filtered = append(filtered, release)
+	}
+	return filtered, nil
+}
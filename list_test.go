@@ -0,0 +1,118 @@
+package release
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
+
+func TestReleasesInRange(t *testing.T) {
+	dateTags := []string{"2023.12.004", "2024.01.001", "2024.03.002", "2024.06.010", "2024.07.001"}
+	semTags := []string{"0.9.0-1", "1.0.0-rc1", "1.0.0-1", "1.4.2-3", "2.0.0-1", "2.0.1-1"}
+	tests := []struct {
+		name     string
+		semver   bool
+		from, to string
+		want     []string
+		wantErr  string
+	}{
+		{name: "date range", from: "2024.01.001", to: "2024.06.999", want: []string{"2024.06.010", "2024.03.002", "2024.01.001"}},
+		{name: "date bounds inclusive", from: "2024.03.002", to: "2024.06.010", want: []string{"2024.06.010", "2024.03.002"}},
+		{name: "date from only", from: "2024.06.001", want: []string{"2024.07.001", "2024.06.010"}},
+		{name: "date to only", to: "2023.12.999", want: []string{"2023.12.004"}},
+		{name: "date empty range", from: "2024.04.001", to: "2024.05.999", want: []string{}},
+		{name: "date reversed", from: "2024.06.001", to: "2024.01.001", wantErr: "invalid range"},
+		{name: "date bad bound", from: "1.0.0", wantErr: "invalid lower bound"},
+		{name: "semver range", semver: true, from: "1.0.0", to: "2.0.0", want: []string{"2.0.0-1", "1.4.2-3", "1.0.0-1", "1.0.0-rc1"}},
+		{name: "semver release bounds", semver: true, from: "1.0.0-1", to: "2.0.0-1", want: []string{"2.0.0-1", "1.4.2-3", "1.0.0-1"}},
+		{name: "semver to only", semver: true, to: "0.9.0", want: []string{"0.9.0-1"}},
+		{name: "semver reversed", semver: true, from: "2.0.0", to: "1.0.0", wantErr: "invalid range"},
+		{name: "semver bad bound", semver: true, to: "2024.06.001", wantErr: "invalid upper bound"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			tags := dateTags
+			if tt.semver {
+				tags = semTags
+			}
+			for _, tag := range tags {
+				tagHead(t, repo, tag, "")
+			}
+			rm := newTestManager(t, dir)
+			rm.SemVer = tt.semver
+			releases, err := rm.ReleasesInRange(rm.ListReleases(""), tt.from, tt.to)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ReleasesInRange() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReleasesInRange() error = %s", err)
+			}
+			got := []string{}
+			for _, release := range releases {
+				got = append(got, release.Tag)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReleasesInRange(%q, %q) = %q, want %q", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTreeHashSegment(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	first := commitFile(t, repo, "file.txt", "Fix the build")
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Same content as the first commit, a different commit
+	second, err := w.Commit("Rebuild", &git.CommitOptions{Author: testSignature, Committer: testSignature, AllowEmptyCommits: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rm := newTestManager(t, dir)
+	for _, rev := range []string{"HEAD", first.String(), second.String()} {
+		got, err := rm.TreeHashSegment(rev)
+		if err != nil {
+			t.Fatalf("TreeHashSegment(%s) error = %s", rev, err)
+		}
+		commit, err := repo.CommitObject(first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "t" + commit.TreeHash.String()[:7]; got != want {
+			t.Errorf("TreeHashSegment(%s) = %s, want %s", rev, got, want)
+		}
+	}
+}
+
+func TestTreeHashIgnoredForNext(t *testing.T) {
+	june := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		existing []string
+		want     string
+	}{
+		{name: "with tree hash", existing: []string{"2024.06.001-t1a2b3c4"}, want: "2024.06.002"},
+		{name: "mixed", existing: []string{"2024.06.001", "2024.06.002-t1a2b3c4", "2024.06.003-t5d6e7f8-api"}, want: "2024.06.004"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			for _, tag := range tt.existing {
+				tagHead(t, repo, tag, "")
+			}
+			rm := newTestManager(t, dir)
+			if got := rm.getNextDateString("", june); got != tt.want {
+				t.Errorf("next release = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
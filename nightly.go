@@ -0,0 +1,74 @@
+package release
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cactus/gostrftime"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// NightlyPrefix starts every nightly release tag and is the name of the
+// floating tag that follows the latest nightly
+const NightlyPrefix = "nightly"
+
+// NightlyTag returns the name of the nightly release of the component for the
+// given day, e.g. nightly-2024.05.21 or nightly-2024.05.21-api
+func NightlyTag(component string, now time.Time) string {
+	name := fmt.Sprintf("%s-%s", NightlyPrefix, gostrftime.Format("%Y.%m.%d", now))
+	if component != "" {
+		name = fmt.Sprintf("%s-%s", name, component)
+	}
+	return name
+}
+
+// NightlyFloatingTag returns the name of the floating tag that follows the
+// latest nightly release of the component, nightly or nightly-<component>
+func NightlyFloatingTag(component string) string {
+	if component == "" {
+		return NightlyPrefix
+	}
+	return fmt.Sprintf("%s-%s", NightlyPrefix, component)
+}
+
+// TagExists is true if the tag exists in the local repository
+func (r *Manager) TagExists(name string) bool {
+	_, err := r.repo.Tag(name)
+	return err == nil
+}
+
+// MoveTag points a lightweight tag at the commit rev resolves to, creating the
+// tag if it doesn't exist yet
+func (r *Manager) MoveTag(name, rev string) error {
+	commit, err := r.resolveCommit(rev)
+	if err != nil {
+		return err
+	}
+	ref := plumbing.NewHashReference(plumbing.NewTagReferenceName(name), commit.Hash)
+	if err := r.repo.Storer.SetReference(ref); err != nil {
+		return fmt.Errorf("failed to move tag %s: %w", name, err)
+	}
+	return r.loadGitTags()
+}
+
+// PushFloatingTag force pushes a tag that is expected to move (see MoveTag),
+// unlike PushTagToRemote it overwrites the tag in the remote
+func (r *Manager) PushFloatingTag(tag, remote string, auth transport.AuthMethod) (string, error) {
+	options := &git.PushOptions{
+		RemoteName: remote,
+		RefSpecs: []config.RefSpec{
+			config.RefSpec(fmt.Sprintf("+refs/tags/%s:refs/tags/%s", tag, tag)),
+		},
+		Auth: auth,
+	}
+	err := r.repo.Push(options)
+	if err == git.NoErrAlreadyUpToDate {
+		return fmt.Sprintf("nothing pushed, floating tag %s was already up to date in remote %s", tag, remote), nil
+	} else if err != nil {
+		return fmt.Sprintf("failed to push floating tag %s to remote %s", tag, remote), err
+	}
+	return fmt.Sprintf("moved floating tag %s in remote %s", tag, remote), nil
+}
@@ -0,0 +1,63 @@
+package release
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNightlyTag(t *testing.T) {
+	day := time.Date(2024, 5, 21, 23, 30, 0, 0, time.UTC)
+	tests := []struct {
+		component    string
+		wantTag      string
+		wantFloating string
+	}{
+		{wantTag: "nightly-2024.05.21", wantFloating: "nightly"},
+		{component: "api", wantTag: "nightly-2024.05.21-api", wantFloating: "nightly-api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.wantTag, func(t *testing.T) {
+			if got := NightlyTag(tt.component, day); got != tt.wantTag {
+				t.Errorf("NightlyTag() = %s, want %s", got, tt.wantTag)
+			}
+			if got := NightlyFloatingTag(tt.component); got != tt.wantFloating {
+				t.Errorf("NightlyFloatingTag() = %s, want %s", got, tt.wantFloating)
+			}
+		})
+	}
+}
+
+func TestMoveTag(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	first := commitFile(t, repo, "file.txt", "Add the feature")
+	tagHead(t, repo, "nightly-2024.05.21", "Release nightly-2024.05.21")
+	second := commitFile(t, repo, "file.txt", "Fix the build")
+	tagHead(t, repo, "nightly-2024.05.22", "")
+
+	rm := newTestManager(t, dir)
+	for _, step := range []struct {
+		rev  string
+		want string
+	}{
+		{rev: "nightly-2024.05.21", want: first.String()}, // Created, through an annotated tag
+		{rev: "nightly-2024.05.22", want: second.String()},
+		{rev: "nightly-2024.05.22", want: second.String()}, // Already there
+	} {
+		if err := rm.MoveTag("nightly", step.rev); err != nil {
+			t.Fatalf("MoveTag(%s) error = %s", step.rev, err)
+		}
+		ref, err := repo.Tag("nightly")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ref.Hash().String() != step.want {
+			t.Errorf("nightly points at %s after moving it to %s, want %s", ref.Hash(), step.rev, step.want)
+		}
+		if !rm.TagExists("nightly") {
+			t.Error("TagExists(nightly) = false after moving it")
+		}
+	}
+	if err := rm.MoveTag("nightly", "nightly-2024.05.23"); err == nil {
+		t.Error("MoveTag() to a missing tag succeeded")
+	}
+}
@@ -0,0 +1,196 @@
+package release
+
+import (
+	"fmt"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ComponentPaths returns the paths (relative to the repository root) that
+// belong to each component. These are configured in the repository's git
+// config, a component may have more than one path:
+//
+//	[release "api"]
+//		path = services/api
+//		path = libs/api-client
+func (r *Manager) ComponentPaths() map[string][]string {
+	paths := map[string][]string{}
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return paths
+	}
+	for _, subsection := range cfg.Raw.Section("release").Subsections {
+		for _, path := range subsection.Options.GetAll("path") {
+			paths[subsection.Name] = append(paths[subsection.Name], pathpkg.Clean(filepath.ToSlash(path)))
+		}
+	}
+	return paths
+}
+
+// UnknownComponents returns the requested components that aren't known (see
+// Components). Components are only checked once some are configured in git
+// config, KnownComponents or ComponentSchemes, until then every component is
+// accepted and nil is returned.
+func (r *Manager) UnknownComponents(requested []string) []string {
+	if len(r.ComponentPaths()) == 0 && len(r.KnownComponents) == 0 && len(r.ComponentSchemes) == 0 {
+		return nil
+	}
+	known := map[string]bool{}
+	for _, component := range r.Components() {
+		known[component] = true
+	}
+	unknown := []string{}
+	for _, component := range requested {
+		if component != "" && !known[component] {
+			unknown = append(unknown, component)
+		}
+	}
+	return unknown
+}
+
+// pathContains returns true if path (relative to the repository root, using
+// forward slashes like git does) is the component path itself or inside of it
+func pathContains(componentPath, path string) bool {
+	return componentPath == "." || path == componentPath || strings.HasPrefix(path, componentPath+"/")
+}
+
+// ComponentForDir returns the component whose configured path contains the
+// given directory, the most specific path wins. An empty string is returned if
+// the directory doesn't belong to any component.
+func (r *Manager) ComponentForDir(dir string) (string, error) {
+	rel, err := filepath.Rel(r.repoDir, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("%s is not inside the repository %s", dir, r.repoDir)
+	}
+	rel = filepath.ToSlash(rel)
+	found, longest := "", -1
+	for component, paths := range r.ComponentPaths() {
+		for _, path := range paths {
+			if pathContains(path, rel) && len(path) > longest {
+				found, longest = component, len(path)
+			}
+		}
+	}
+	return found, nil
+}
+
+// changedPaths returns the paths changed by a commit compared to its first
+// parent (or every path for the root commit)
+func changedPaths(c *object.Commit) ([]string, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var parentTree *object.Tree
+	if c.NumParents() > 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	for _, change := range changes {
+		if change.From.Name != "" {
+			paths = append(paths, change.From.Name)
+		}
+		if change.To.Name != "" && change.To.Name != change.From.Name {
+			paths = append(paths, change.To.Name)
+		}
+	}
+	return paths, nil
+}
+
+// commitTouches returns true if the commit changed anything inside one of the
+// given paths
+func commitTouches(c *object.Commit, paths []string) (bool, error) {
+	changed, err := changedPaths(c)
+	if err != nil {
+		return false, err
+	}
+	for _, path := range changed {
+		for _, componentPath := range paths {
+			if pathContains(componentPath, path) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// PathsChanged returns true if any commit between fromTag and toRef changed
+// something inside one of the paths. Relative paths are relative to the
+// manager's working directory.
+func (r *Manager) PathsChanged(fromTag, toRef string, paths []string) (bool, error) {
+	repoPaths := []string{}
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(r.cwd, path)
+		}
+		rel, err := filepath.Rel(r.repoDir, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return false, fmt.Errorf("path %s is outside of the repository", path)
+		}
+		repoPaths = append(repoPaths, filepath.ToSlash(rel))
+	}
+	commits, err := r.commitsBetween(fromTag, toRef, false, false)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range commits {
+		touched, err := commitTouches(c, repoPaths)
+		if err != nil || touched {
+			return touched, err
+		}
+	}
+	return false, nil
+}
+
+// ComponentChange summarizes what changed in a component since a release
+type ComponentChange struct {
+	Component string
+	Paths     []string // The configured paths, nil if the component has none
+	Commits   int      // The number of commits that touched the paths
+}
+
+// Changed returns true if any commits touched the component
+func (c ComponentChange) Changed() bool {
+	return c.Commits > 0
+}
+
+// ComponentChanges counts the commits between fromTag and toRef that touched
+// each component's configured paths. Components without configured paths
+// could be anywhere in the repository so every commit counts for them.
+func (r *Manager) ComponentChanges(fromTag, toRef string, components []string) ([]ComponentChange, error) {
+	commits, err := r.commitsBetween(fromTag, toRef, false, false)
+	if err != nil {
+		return nil, err
+	}
+	componentPaths := r.ComponentPaths()
+	changes := []ComponentChange{}
+	for _, component := range components {
+		change := ComponentChange{Component: component, Paths: componentPaths[component]}
+		for _, c := range commits {
+			touched := true
+			if change.Paths != nil {
+				if touched, err = commitTouches(c, change.Paths); err != nil {
+					return nil, err
+				}
+			}
+			if touched {
+				change.Commits++
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
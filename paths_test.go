@@ -0,0 +1,169 @@
+package release
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// addComponentPath configures path as belonging to component in the
+// repository's git config (release.<component>.path)
+func addComponentPath(t *testing.T, rm *Manager, component, path string) {
+	t.Helper()
+	cfg, err := rm.repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Raw.Section("release").Subsection(component).AddOption("path", path)
+	if err := rm.repo.SetConfig(cfg); err != nil {
+		t.Fatalf("failed to configure the path of %s: %s", component, err)
+	}
+}
+
+func TestComponentForDir(t *testing.T) {
+	dir, _ := newTestRepo(t)
+	rm := newTestManager(t, dir)
+	addComponentPath(t, rm, "api", "services/api")
+	addComponentPath(t, rm, "api", "libs/api-client")
+	addComponentPath(t, rm, "api-v2", "services/api/v2/")
+	addComponentPath(t, rm, "web", "services/web")
+
+	tests := []struct {
+		dir     string // Relative to the repository
+		want    string
+		wantErr string
+	}{
+		{dir: "services/api", want: "api"},
+		{dir: "services/api/handlers", want: "api"},
+		{dir: "libs/api-client/src", want: "api"},
+		{dir: "services/api/v2/handlers", want: "api-v2"},
+		{dir: "services/web", want: "web"},
+		{dir: "services/webhooks", want: ""},
+		{dir: "services", want: ""},
+		{dir: ".", want: ""},
+		{dir: "..", wantErr: "is not inside the repository"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dir, func(t *testing.T) {
+			got, err := rm.ComponentForDir(filepath.Join(dir, tt.dir))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ComponentForDir() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ComponentForDir() error = %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("ComponentForDir(%s) = %q, want %q", tt.dir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComponentChanges(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	rm := newTestManager(t, dir)
+	addComponentPath(t, rm, "api", "services/api")
+	addComponentPath(t, rm, "web", "services/web")
+	addComponentPath(t, rm, "docs", "docs")
+	tagHead(t, repo, "2024.06.001", "")
+	commitFile(t, repo, "services/api/main.go", "Fix the api")
+	commitFile(t, repo, "services/api/main.go", "Speed up the api")
+	commitFile(t, repo, "services/web/index.html", "Restyle the web")
+
+	changes, err := rm.ComponentChanges("2024.06.001", "HEAD", []string{"api", "web", "docs", "tools"})
+	if err != nil {
+		t.Fatalf("ComponentChanges() error = %s", err)
+	}
+	want := []struct {
+		component string
+		commits   int
+		changed   bool
+	}{
+		{"api", 2, true},
+		{"web", 1, true},
+		{"docs", 0, false},
+		{"tools", 3, true}, // Without paths every commit counts
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("ComponentChanges() = %+v, want %d components", changes, len(want))
+	}
+	for idx, w := range want {
+		change := changes[idx]
+		if change.Component != w.component || change.Commits != w.commits || change.Changed() != w.changed {
+			t.Errorf("change %d = %+v (changed %t), want %s with %d commits", idx, change, change.Changed(), w.component, w.commits)
+		}
+	}
+}
+
+func TestUnknownComponents(t *testing.T) {
+	tests := []struct {
+		name      string
+		paths     map[string]string
+		known     []string
+		tags      []string
+		requested []string
+		want      []string
+	}{
+		{name: "nothing configured", requested: []string{"apii"}, want: nil},
+		{name: "typo", paths: map[string]string{"api": "services/api"}, requested: []string{"api", "apii"}, want: []string{"apii"}},
+		{name: "known from the repo config", known: []string{"ui"}, requested: []string{"ui", "web"}, want: []string{"web"}},
+		{name: "known from tags", paths: map[string]string{"api": "services/api"}, tags: []string{"2024.06.001-web"}, requested: []string{"api", "web"}, want: []string{}},
+		{name: "root release", paths: map[string]string{"api": "services/api"}, requested: []string{""}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			for _, tag := range tt.tags {
+				tagHead(t, repo, tag, "")
+			}
+			rm := newTestManager(t, dir)
+			for component, path := range tt.paths {
+				addComponentPath(t, rm, component, path)
+			}
+			rm.KnownComponents = tt.known
+			if got := rm.UnknownComponents(tt.requested); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnknownComponents(%q) = %q, want %q", tt.requested, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPathsChanged(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	commitFile(t, repo, "docs/guide.md", "Write the guide")
+	tagHead(t, repo, "2024.06.001", "")
+	commitFile(t, repo, "schema/api.yaml", "Add an endpoint")
+	commitFile(t, repo, "src/main.go", "Implement the endpoint")
+
+	tests := []struct {
+		name    string
+		paths   []string
+		want    bool
+		wantErr bool
+	}{
+		{name: "changed file", paths: []string{"schema/api.yaml"}, want: true},
+		{name: "changed directory", paths: []string{"schema"}, want: true},
+		{name: "changed before the release", paths: []string{"docs"}},
+		{name: "never changed", paths: []string{"schema/events.yaml"}},
+		{name: "any of several", paths: []string{"docs", "schema/events.yaml", "src/main.go"}, want: true},
+		{name: "not a path prefix", paths: []string{"sch"}},
+		{name: "absolute", paths: []string{filepath.Join(dir, "schema")}, want: true},
+		{name: "outside of the repository", paths: []string{"../schema"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := newTestManager(t, dir)
+			got, err := rm.PathsChanged("2024.06.001", "HEAD", tt.paths)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PathsChanged() error = %v, want error %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("PathsChanged(%q) = %t, want %t", tt.paths, got, tt.want)
+			}
+		})
+	}
+}
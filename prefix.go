@@ -0,0 +1,32 @@
+package release
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidateTagPrefix returns an error if prefix can't start a release tag: it
+// has to be usable in a tag name and can't end with a digit, which would run
+// into the version (v1.2.3, but 21.2.3 reads as 21.2.3)
+func ValidateTagPrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+	if strings.ContainsAny(prefix, " ~^:?*[\\") || strings.HasPrefix(prefix, "-") || strings.Contains(prefix, "..") {
+		return fmt.Errorf("tag prefix %q can't be used in a tag name", prefix)
+	}
+	if last := prefix[len(prefix)-1]; last >= '0' && last <= '9' {
+		return fmt.Errorf("tag prefix %q can't end with a digit", prefix)
+	}
+	return nil
+}
+
+// trimTagPrefix returns the tag without the manager's TagPrefix, ok is false
+// if the tag doesn't have it and so isn't a date or semver release
+// (2024.06.001 isn't a release when the prefix is v)
+func (r *Manager) trimTagPrefix(tag string) (name string, ok bool) {
+	if !strings.HasPrefix(tag, r.TagPrefix) {
+		return tag, false
+	}
+	return tag[len(r.TagPrefix):], true
+}
@@ -0,0 +1,57 @@
+package release
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrRefStorageReadOnly is returned by CheckRefStorage when tags can't be
+// written to the repository
+var ErrRefStorageReadOnly = errors.New("the repository's ref storage isn't writable")
+
+// gitCommonDir returns the directory the refs are stored in, the .git
+// directory or for worktrees the main repository's
+func (r *Manager) gitCommonDir() (string, error) {
+	gitDir := filepath.Join(r.repoDir, ".git")
+	if info, err := os.Stat(gitDir); err == nil && info.IsDir() {
+		return gitDir, nil
+	}
+	output, err := r.runGit("rev-parse", "--git-common-dir")
+	if err != nil {
+		return "", err
+	}
+	dir := strings.TrimSpace(output)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(r.repoDir, dir)
+	}
+	return dir, nil
+}
+
+// CheckRefStorage makes sure tags can be written before any work is done.
+// Tags are written as loose refs in refs/tags and removing or renaming one
+// rewrites packed-refs in the git directory, so both have to be writable.
+// Locked down environments otherwise only find out when go-git fails deep
+// inside CreateTag.
+func (r *Manager) CheckRefStorage() error {
+	gitDir, err := r.gitCommonDir()
+	if err != nil {
+		return fmt.Errorf("failed to find the git directory: %w", err)
+	}
+	for _, dir := range []string{filepath.Join(gitDir, "refs", "tags"), gitDir} {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			// refs/tags is created with the first tag, the git directory
+			// check covers that
+			continue
+		}
+		probe, err := os.CreateTemp(dir, ".release-preflight-")
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrRefStorageReadOnly, err)
+		}
+		probe.Close()
+		os.Remove(probe.Name())
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package release
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// readOnly makes path read-only for the rest of the test, skipping if that
+// wouldn't stop anyone from writing to it
+func readOnly(t *testing.T, path string) {
+	t.Helper()
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("permissions aren't enforced on windows or for root")
+	}
+	if err := os.Chmod(path, 0555); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chmod(path, 0755) })
+}
+
+func TestCheckRefStorage(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, gitDir string)
+		wantErr bool
+	}{
+		{name: "writable"},
+		{
+			name: "no tags yet",
+			setup: func(t *testing.T, gitDir string) {
+				if err := os.RemoveAll(filepath.Join(gitDir, "refs", "tags")); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name:    "read-only refs/tags",
+			setup:   func(t *testing.T, gitDir string) { readOnly(t, filepath.Join(gitDir, "refs", "tags")) },
+			wantErr: true,
+		},
+		{
+			name:    "read-only git directory",
+			setup:   func(t *testing.T, gitDir string) { readOnly(t, gitDir) },
+			wantErr: true,
+		},
+		{
+			name: "refs/tags isn't a directory",
+			setup: func(t *testing.T, gitDir string) {
+				tags := filepath.Join(gitDir, "refs", "tags")
+				if err := os.RemoveAll(tags); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(tags, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, _ := newTestRepo(t)
+			gitDir := filepath.Join(dir, ".git")
+			if err := os.MkdirAll(filepath.Join(gitDir, "refs", "tags"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			rm := newTestManager(t, dir)
+			if tt.setup != nil {
+				tt.setup(t, gitDir)
+			}
+			err := rm.CheckRefStorage()
+			if tt.wantErr != errors.Is(err, ErrRefStorageReadOnly) {
+				t.Fatalf("CheckRefStorage() error = %v, want ErrRefStorageReadOnly = %t", err, tt.wantErr)
+			}
+			// The probe doesn't leave anything behind
+			matches, _ := filepath.Glob(filepath.Join(gitDir, "*", "*", ".release-preflight-*"))
+			more, _ := filepath.Glob(filepath.Join(gitDir, ".release-preflight-*"))
+			if leftover := append(matches, more...); len(leftover) != 0 {
+				t.Errorf("CheckRefStorage() left %q behind", leftover)
+			}
+		})
+	}
+}
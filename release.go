@@ -1,6 +1,7 @@
 package release
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -9,8 +10,12 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
 	"github.com/cactus/gostrftime"
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
@@ -20,16 +25,6 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// CheckIfError checks if the given error is nil, if not it prints a message and
-// exits
-func CheckIfError(err error, msg string) {
-	if err == nil {
-		return
-	}
-
-	log.Fatal().Err(err).Msg(msg)
-}
-
 // Release represents a release
 type Release struct {
 	Tag            string            // The human readable name of the tag
@@ -39,6 +34,7 @@ type Release struct {
 	Author         object.Signature  // The author of the tag
 	Committer      object.Signature  // The committer (person who merged/ran git commit)
 	Tagger         *object.Signature // The person who created a proper tag (will be nil for lightweight tags)
+	PGPSignature   string            // The armored signature of a signed tag, empty if unsigned
 }
 
 // Date returns the date of when the commit the tag points to happened
@@ -46,6 +42,15 @@ func (r *Release) Date() time.Time {
 	return r.Committer.When
 }
 
+// ReleaseDate returns when the release was made, the tagger date of annotated
+// tags and the date of the commit for lightweight tags
+func (r *Release) ReleaseDate() time.Time {
+	if r.Tagger != nil {
+		return r.Tagger.When
+	}
+	return r.Committer.When
+}
+
 // ReleasedBy returns who the tag was released by. It looks at the Tagger first,
 // if nil, it defaults to the Committer
 func (r *Release) ReleasedBy() object.Signature {
@@ -64,13 +69,39 @@ func (r *Release) ReleasedByString(includeDate bool) string {
 	return fmt.Sprintf("%s <%s>", relBy.Name, relBy.Email)
 }
 
+// Annotated is true for annotated tags, lightweight tags have no tagger
+func (r *Release) Annotated() bool {
+	return r.Tagger != nil
+}
+
+// SignerKeyID returns the ID of the key that signed the tag (in hex, like git
+// log --show-signature shows it), an empty string if the tag isn't signed or
+// the signature can't be parsed
+func (r *Release) SignerKeyID() string {
+	if r.PGPSignature == "" {
+		return ""
+	}
+	block, err := armor.Decode(strings.NewReader(r.PGPSignature))
+	if err != nil {
+		return ""
+	}
+	pkt, err := packet.Read(block.Body)
+	if err != nil {
+		return ""
+	}
+	if sig, ok := pkt.(*packet.Signature); ok && sig.IssuerKeyId != nil {
+		return fmt.Sprintf("%016X", *sig.IssuerKeyId)
+	}
+	return ""
+}
+
 // Message returns a friendly messaage for the commit, it uses the tagged
 // message if that's available and defaults to the commit message
 func (r *Release) Message() string {
 	if r.ReleaseMessage != "" {
 		return r.ReleaseMessage
 	}
-	return strings.SplitN(r.CommitMessage, "\n", 1)[0]
+	return strings.SplitN(r.CommitMessage, "\n", 2)[0]
 }
 
 type releaseList []Release
@@ -94,10 +125,85 @@ type Manager struct {
 	repoDir             string
 	cwd                 string
 	repo                *git.Repository
+	writeLock           *sync.Mutex // Serializes writes to repo, shared with ForComponent managers
 	releases            releaseList
 	timeFmt             string
 	incFmt              string
 	AlwaysIncludeNumber bool
+	SemVer              bool     // Use semantic versioning when listing and comparing releases
+	BranchSeparator     string   // Separates the branch from semver releases, defaults to -
+	BranchSlash         string   // Replaces / in branch names of semver releases (feature/x -> feature-x), empty keeps it
+	StableBranches      []string // Branches whose semver releases have no branch segment, defaults to main and master
+	NumberPrefix        string   // Wraps the date release number, e.g. "b" for 2024.05.b003
+	NumberSuffix        string   // Wraps the date release number, e.g. "-build" for 2024.05.003-build
+	TagPrefix           string   // Starts date and semver release names, e.g. "v" for v2024.05.003 or v1.2.3-1
+	KnownComponents     []string // Components that are known without paths in git config or existing tags
+
+	// ComponentSchemes name the releases of some components differently
+	// from the rest, see ForComponent
+	ComponentSchemes map[string]ComponentScheme
+	parent           *Manager // The manager a ForComponent manager was made from
+	scanComponent    string   // The component a ForComponent manager scans the semver releases of
+
+	// Warnings recorded during the run, see Warnf
+	warnings []string
+
+	// Location is the time zone the date of date and nightly releases is
+	// taken in, local time if nil. Everyone releasing from a repository has
+	// to use the same zone, otherwise their releases around midnight fall
+	// into different periods (and counters).
+	Location *time.Location
+
+	// Target is the revision releases are tagged at, HEAD if empty
+	Target string
+
+	// TagDate is the tagger date of annotated tags, the current time if zero.
+	// Pinning it makes the tag object (and its hash) reproducible.
+	TagDate time.Time
+
+	// SignKey signs annotated tags if set, see LoadSigningKey
+	SignKey *openpgp.Entity
+
+	// PushRetries is how often a tag push failing with a transient error
+	// (see IsTransientPushError) is retried, after PushRetryDelay and then
+	// twice as long every time
+	PushRetries    int
+	PushRetryDelay time.Duration
+
+	// OverwriteRemoteTags force pushes tags that already exist in the remote
+	// with a different target instead of failing with ErrRemoteTagConflict
+	OverwriteRemoteTags bool
+
+	// AllowDuplicate lets CreateTag release a commit that already has a
+	// release of the same component, see ErrAlreadyReleased
+	AllowDuplicate bool
+
+	// RequireSigned refuses to push tags that aren't validly signed by a key
+	// in SignedKeyRing (armored public keys), or by SignKey if that's empty
+	RequireSigned bool
+	SignedKeyRing string
+
+	// Changelog Items
+	ChangelogMergesOnly  bool     // Only include merge commits in the changelog
+	ChangelogFirstParent bool     // Only follow the first parent of merges when building the changelog
+	ChangelogPaths       []string // Only include commits touching these paths (relative to the repository root) in the changelog
+}
+
+// Warnf logs a warning and records it so everything that was tolerated during
+// a run can be summarized at the end
+func (r *Manager) Warnf(format string, args ...interface{}) {
+	if r.parent != nil {
+		r.parent.Warnf(format, args...)
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	log.Warn().Msg(msg)
+	r.warnings = append(r.warnings, msg)
+}
+
+// Warnings returns every warning recorded with Warnf
+func (r *Manager) Warnings() []string {
+	return r.warnings
 }
 
 // FindRepoDir finds a git repository directory in the current or any parent
@@ -118,21 +224,148 @@ func FindRepoDir(path string) (string, error) {
 func NewManager(cwd, timeFmt, incFmt string) (*Manager, error) {
 	repoDir, err := FindRepoDir(cwd)
 	log.Debug().Msgf("searching for git directory in: %s", cwd)
-	CheckIfError(err, "failed to find repo dir")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find repo dir: %w", err)
+	}
 	r, err := git.PlainOpen(repoDir)
-	CheckIfError(err, "failed to load git repository")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load git repository: %w", err)
+	}
+	if err := validateDateFormat(timeFmt); err != nil {
+		return nil, err
+	}
+	if err := ValidateIncrementFormat(incFmt); err != nil {
+		return nil, err
+	}
+	// An unborn HEAD fails every later lookup of it with "reference not
+	// found", there is nothing to release yet
+	if _, err := r.Head(); err == plumbing.ErrReferenceNotFound {
+		return nil, fmt.Errorf("%w, commit something before releasing %s", ErrNoCommits, repoDir)
+	}
 
 	mgr := &Manager{
-		repoDir: repoDir,
-		cwd:     cwd,
-		repo:    r,
-		timeFmt: timeFmt,
-		incFmt:  incFmt,
+		repoDir:   repoDir,
+		cwd:       cwd,
+		repo:      r,
+		timeFmt:   timeFmt,
+		incFmt:    incFmt,
+		writeLock: &sync.Mutex{},
+	}
+	if err := mgr.loadGitTags(); err != nil {
+		return nil, err
 	}
-	mgr.loadGitTags()
 	return mgr, nil
 }
 
+// LatestRelease returns the most recent release (by date) or nil if there are
+// no releases yet
+func (r *Manager) LatestRelease() *Release {
+	if len(r.releases) == 0 {
+		return nil
+	}
+	return &r.releases[0]
+}
+
+// OnlyReachableFrom drops every release whose commit isn't reachable from rev
+// (usually HEAD), so the latest/next release is computed only from the history
+// of the current branch and ignores tags on unrelated branches
+func (r *Manager) OnlyReachableFrom(rev string) error {
+	from, err := r.resolveCommit(rev)
+	if err != nil {
+		return err
+	}
+	ancestors := map[string]bool{}
+	err = object.NewCommitPreorderIter(from, nil, nil).ForEach(func(c *object.Commit) error {
+		ancestors[c.Hash.String()] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	reachable := releaseList{}
+	for _, release := range r.releases {
+		if ancestors[release.Hash] {
+			reachable = append(reachable, release)
+		} else {
+			log.Debug().Msgf("ignoring tag %s, it is not reachable from %s", release.Tag, rev)
+		}
+	}
+	r.releases = reachable
+	return nil
+}
+
+// GetRelease returns the release with the given tag name
+func (r *Manager) GetRelease(tag string) (*Release, error) {
+	for idx := range r.releases {
+		if r.releases[idx].Tag == tag {
+			return &r.releases[idx], nil
+		}
+	}
+	return nil, fmt.Errorf("no release found with tag %s", tag)
+}
+
+// GitConfigOption returns the value of a raw git config option (e.g. the
+// "annotate" option in the "release" section), the repository config is
+// checked first followed by the global and system config. An empty string is
+// returned if the option isn't set anywhere.
+func (r *Manager) GitConfigOption(section, option string) string {
+	cfg, err := r.repo.Config()
+	if err == nil && cfg.Raw.Section(section).Option(option) != "" {
+		return cfg.Raw.Section(section).Option(option)
+	}
+	for _, scope := range []config.Scope{config.GlobalScope, config.SystemScope} {
+		cfg, err := config.LoadConfig(scope)
+		if err != nil {
+			log.Debug().Err(err).Msgf("unable to load git config for scope %d", scope)
+			continue
+		}
+		if value := cfg.Raw.Section(section).Option(option); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// patComponent matches the component suffix of both date based and semver
+// based release tags
+var patComponent = regexp.MustCompile(`^(?:\d{4}\.\d{2}\.\d+|(?:[^.]+-)?\d+\.\d+\.\d+-(?:rc)?\d+)` + treeSegment + `(?:-(?P<component>.+))?$`)
+
+// Components returns the sorted names of all known components, those with
+// configured paths (see ComponentPaths), KnownComponents and those discovered
+// from existing release tags
+func (r *Manager) Components() []string {
+	found := map[string]bool{}
+	for component := range r.ComponentPaths() {
+		found[component] = true
+	}
+	for _, component := range r.KnownComponents {
+		found[component] = true
+	}
+	for component := range r.ComponentSchemes {
+		found[component] = true
+	}
+	datePattern := r.dateVersionPattern()
+	for _, release := range r.releases {
+		tag, ok := r.trimTagPrefix(release.Tag)
+		if !ok {
+			continue
+		}
+		if results := datePattern.FindStringSubmatch(tag); results != nil {
+			if component := results[datePattern.SubexpIndex("component")]; component != "" {
+				found[component] = true
+			}
+		} else if results := patComponent.FindStringSubmatch(tag); results != nil && results[2] != "" {
+			found[results[2]] = true
+		}
+	}
+	components := []string{}
+	for component := range found {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+	return components
+}
+
 func tagToRefspec(tag string) config.RefSpec {
 	return config.RefSpec(fmt.Sprintf("refs/tags/%s:refs/tags/%s", tag, tag))
 }
@@ -144,39 +377,208 @@ func (r *Manager) CheckRemote(remote string) error {
 	return err
 }
 
+// UpstreamRemote returns the remote of the current branch's configured
+// upstream (branch.<name>.remote), or "" if the branch doesn't track one or
+// HEAD is detached
+func (r *Manager) UpstreamRemote() (string, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	if !head.Name().IsBranch() {
+		return "", nil
+	}
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the repository config: %w", err)
+	}
+	if branch, ok := cfg.Branches[head.Name().Short()]; ok {
+		return branch.Remote, nil
+	}
+	return "", nil
+}
+
+// RemoteURL returns the URL of the remote (the first one if it has several)
+func (r *Manager) RemoteURL(remote string) (string, error) {
+	found, err := r.repo.Remote(remote)
+	if err != nil {
+		return "", err
+	}
+	urls := found.Config().URLs
+	if len(urls) == 0 {
+		return "", fmt.Errorf("remote %s has no URL", remote)
+	}
+	return urls[0], nil
+}
+
 // PushTagToRemote pushes the given local tag to the remote repository returns a
 // message to be displayed to the user along with an an optional error, If err
 // is nil, the operation was successful
 func (r *Manager) PushTagToRemote(tag, remote string, auth transport.AuthMethod) (string, error) {
+	if r.RequireSigned {
+		if msg, err := r.checkSigned(tag); err != nil {
+			return msg, err
+		}
+	}
+	msg, _, err := r.retryPush(tag, remote, func() (string, bool, error) {
+		return pushTag(r.repo, tag, remote, auth, r.OverwriteRemoteTags)
+	})
+	return msg, err
+}
+
+// pushTag pushes the tag, pushed is false if the remote already had it. A tag
+// in the remote with a different target is only replaced if overwrite is set.
+func pushTag(repo *git.Repository, tag, remote string, auth transport.AuthMethod, overwrite bool) (msg string, pushed bool, err error) {
+	// Check the remote first, go-git will happily move an existing remote tag
+	// if the new target is a descendant of the old one. An existing tag with
+	// the same target is fine (think CI re-runs), anything else is a genuine
+	// conflict.
+	refSpec := tagToRefspec(tag)
+	if remoteHash, found := remoteTagHash(repo, tag, remote, auth); found {
+		localRef, err := repo.Tag(tag)
+		if err != nil {
+			return fmt.Sprintf("failed to load local tag %s", tag), false, err
+		}
+		if localRef.Hash().String() == remoteHash {
+			return fmt.Sprintf("nothing pushed, tag %s already exists in remote %s with the same target", tag, remote), false, nil
+		}
+		if !overwrite {
+			return fmt.Sprintf("tag %s already exists in remote %s pointing at %s", tag, remote, remoteHash), false, ErrRemoteTagConflict
+		}
+		refSpec = "+" + refSpec
+	}
+
 	options := &git.PushOptions{
 		RemoteName: remote,
 		RefSpecs: []config.RefSpec{
-			tagToRefspec(tag),
+			refSpec,
 		},
 		Auth: auth,
 	}
-	err := r.repo.Push(options)
+	err = repo.Push(options)
 	if err == git.NoErrAlreadyUpToDate {
-		return fmt.Sprintf("nothing pushed, tag %s already existed and was up to date in remote %s", tag, remote), nil
+		return fmt.Sprintf("nothing pushed, tag %s already existed and was up to date in remote %s", tag, remote), false, nil
 	} else if err != nil {
-		return fmt.Sprintf("failed to push tag %s to remote %s", tag, remote), err
+		return fmt.Sprintf("failed to push tag %s to remote %s", tag, remote), false, err
+	}
+	if refSpec.IsForceUpdate() {
+		return fmt.Sprintf("overwrote tag %s in remote %s", tag, remote), true, nil
+	}
+	return fmt.Sprintf("pushed tag %s to remote %s", tag, remote), true, nil
+}
+
+// ErrRemoteTagConflict is returned when pushing a tag that already exists in
+// the remote but points at a different object
+var ErrRemoteTagConflict = errors.New("tag already exists in the remote with a different target")
+
+// remoteTagHash looks up the hash a tag points at in the remote, found is
+// false if the remote doesn't have the tag (or couldn't be listed)
+func remoteTagHash(repo *git.Repository, tag, remote string, auth transport.AuthMethod) (string, bool) {
+	rem, err := repo.Remote(remote)
+	if err != nil {
+		return "", false
+	}
+	refs, err := rem.List(&git.ListOptions{Auth: auth})
+	if err != nil {
+		log.Debug().Err(err).Msgf("unable to list remote %s to check for tag %s", remote, tag)
+		return "", false
+	}
+	for _, ref := range refs {
+		if ref.Name() == plumbing.NewTagReferenceName(tag) {
+			return ref.Hash().String(), true
+		}
 	}
-	return fmt.Sprintf("pushed tag %s to remote %s", tag, remote), err
+	return "", false
 }
 
-func (r *Manager) loadGitTags() {
+// PushResult is the outcome of pushing a single tag, Message is suitable for
+// displaying to the user and Err is nil if the push was successful
+type PushResult struct {
+	Tag     string
+	Message string
+	Err     error
+	Pushed  bool // False if the remote already had the tag (or the push failed)
+}
+
+// PushTagsToRemote pushes each of the tags to the remote with at most jobs
+// pushes running at once. The results are in the same order as the tags no
+// matter which push finishes first.
+func (r *Manager) PushTagsToRemote(tags []string, remote string, auth transport.AuthMethod, jobs int) []PushResult {
+	results := make([]PushResult, len(tags))
+	pending := []int{}
+	for idx, tag := range tags {
+		if r.RequireSigned {
+			if msg, err := r.checkSigned(tag); err != nil {
+				results[idx] = PushResult{Tag: tag, Message: msg, Err: err}
+				continue
+			}
+		}
+		pending = append(pending, idx)
+	}
+	if jobs < 2 {
+		for _, idx := range pending {
+			results[idx] = r.pushResult(r.repo, tags[idx], remote, auth)
+		}
+		return results
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < jobs && worker < len(pending); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// go-git's storage isn't safe to share between goroutines, so
+			// every worker gets its own handle on the repository
+			repo, err := git.PlainOpen(r.repoDir)
+			for idx := range indexes {
+				if err != nil {
+					results[idx] = PushResult{Tag: tags[idx], Message: fmt.Sprintf("failed to open repository to push tag %s", tags[idx]), Err: err}
+					continue
+				}
+				results[idx] = r.pushResult(repo, tags[idx], remote, auth)
+			}
+		}()
+	}
+	for _, idx := range pending {
+		indexes <- idx
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+func (r *Manager) pushResult(repo *git.Repository, tag, remote string, auth transport.AuthMethod) PushResult {
+	msg, pushed, err := r.retryPush(tag, remote, func() (string, bool, error) {
+		return pushTag(repo, tag, remote, auth, r.OverwriteRemoteTags)
+	})
+	return PushResult{Tag: tag, Message: msg, Err: err, Pushed: pushed}
+}
+
+func (r *Manager) loadGitTags() error {
 	tagrefs, err := r.repo.Tags()
-	CheckIfError(err, "failed to load lightweight tags")
+	if err != nil {
+		return fmt.Errorf("failed to load lightweight tags: %w", err)
+	}
 	// Reset the relesae list
 	r.releases = releaseList{}
 	tagrefs.ForEach(func(t *plumbing.Reference) error {
 		newRelease := Release{}
 		obj, err := r.repo.CommitObject(t.Hash())
 		if err != nil {
-			tag, _ := r.repo.TagObject(t.Hash())
-			newRelease.Tag = tag.Name
+			tag, err := r.repo.TagObject(t.Hash())
+			if err != nil {
+				// Neither a commit nor a tag object, what the tag pointed at
+				// is gone (see CheckTag)
+				log.Error().Err(err).Msgf("failed to load the object tag %s points at, skipping", t.Name().Short())
+				return nil
+			}
+			// The ref is what git (and everyone else) knows the tag as, the
+			// name inside the tag object can differ if the ref was copied
+			newRelease.Tag = t.Name().Short()
 			newRelease.ReleaseMessage = tag.Message
 			newRelease.Tagger = &tag.Tagger
+			newRelease.PGPSignature = tag.PGPSignature
 			obj, err = tag.Commit()
 			if err != nil {
 				log.Error().Err(err).Msgf("failed to load commit for tag %s, this looks bad, skipping", tag.Name)
@@ -194,60 +596,186 @@ func (r *Manager) loadGitTags() {
 		return nil
 	})
 	sort.Sort(r.releases)
+	return nil
 }
 
-// CreateTag creates a tag in the repo, if comment is specified it creates an
-// annotated tag
+// CreateTag creates a tag at Target in the repo, if comment is specified it
+// creates an annotated tag
 func (r *Manager) CreateTag(name, comment, user, email string) (*plumbing.Reference, error) {
-	hash, err := r.repo.Head()
+	target := r.Target
+	if target == "" {
+		target = "HEAD"
+	}
+	// go-git's storage isn't safe for concurrent use, see CreateTags
+	r.writeLock.Lock()
+	commit, err := r.resolveCommit(target)
+	r.writeLock.Unlock()
 	if err != nil {
 		return nil, err
 	}
+	if existing := r.releasedAt(name, commit.Hash.String()); existing != "" && !r.AllowDuplicate {
+		return nil, fmt.Errorf("%w: %s is %s, pass --allow-duplicate to release it again", ErrAlreadyReleased, commit.Hash.String()[:7], existing)
+	}
 	var opts *git.CreateTagOptions
 	if comment != "" {
 		if user == "" || email == "" {
-			msg := "both user and email are required when specifying a message, something might be wrong with your ~/.gitconfig or you didn't specify --name and --email"
-			log.Fatal().Str(
-				"name", user,
-			).Str(
-				"email", email,
-			).Msg(msg)
+			return nil, fmt.Errorf("both user and email are required when specifying a message, something might be wrong with your ~/.gitconfig or you didn't specify --user and --email (or $RELEASE_USER and $RELEASE_EMAIL), got name %q and email %q", user, email)
+		}
+		when := time.Now()
+		if !r.TagDate.IsZero() {
+			when = r.TagDate
 		}
 		sig := &object.Signature{
 			Name:  user,
 			Email: email,
-			When:  time.Now(),
+			When:  when,
+		}
+		opts = &git.CreateTagOptions{Message: comment, Tagger: sig, SignKey: r.SignKey}
+	}
+	r.writeLock.Lock()
+	defer r.writeLock.Unlock()
+	return r.repo.CreateTag(name, commit.Hash, opts)
+}
+
+// TagSpec is a tag for CreateTags to create, an empty message creates a
+// lightweight tag
+type TagSpec struct {
+	Name    string
+	Message string
+}
+
+// CreateResult is the outcome of creating one of the tags of CreateTags
+type CreateResult struct {
+	Tag string
+	Err error
+}
+
+// CreateTags creates the tags with CreateTag, at most jobs at once. Access to
+// the repository itself is serialized since go-git's storage isn't safe for
+// concurrent use, what runs in parallel is the rest of CreateTag, like the
+// duplicate check against every existing release. The results are in the same
+// order as the tags no matter which finishes first.
+func (r *Manager) CreateTags(tags []TagSpec, user, email string, jobs int) []CreateResult {
+	results := make([]CreateResult, len(tags))
+	create := func(idx int) {
+		_, err := r.CreateTag(tags[idx].Name, tags[idx].Message, user, email)
+		results[idx] = CreateResult{Tag: tags[idx].Name, Err: err}
+	}
+	if jobs < 2 {
+		for idx := range tags {
+			create(idx)
+		}
+		return results
+	}
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < jobs && worker < len(tags); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				create(idx)
+			}
+		}()
+	}
+	for idx := range tags {
+		indexes <- idx
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// ErrAlreadyReleased is returned by CreateTag when the commit already has a
+// release of the same component, releasing it again would only bump the
+// number
+var ErrAlreadyReleased = errors.New("commit already released")
+
+// releasedAt returns an existing release of the same scheme and component as
+// the release name that points at commit, empty if there is none. Tags that
+// aren't date or semver releases (nightlies, counters) never are duplicates,
+// and neither is the final release of a release candidate.
+func (r *Manager) releasedAt(name, commit string) string {
+	component := r.ComponentOf(name)
+	mgr := r.ForComponent(component)
+	version, ok := mgr.parseVersion(name)
+	if !ok {
+		return ""
+	}
+	for _, release := range r.releases {
+		if release.Hash != commit || release.Tag == name {
+			continue
+		}
+		existing, ok := mgr.parseVersion(release.Tag)
+		if ok && existing.component == component && (existing.candidate == version.candidate || version.candidate) {
+			return release.Tag
 		}
-		opts = &git.CreateTagOptions{Message: comment, Tagger: sig}
 	}
-	return r.repo.CreateTag(name, hash.Hash(), opts)
+	return ""
 }
 
+// ErrDetachedHead is returned by GetBranch when HEAD isn't on a branch, as
+// in the checkouts of most CI systems
+var ErrDetachedHead = errors.New("HEAD is detached, it isn't on a branch")
+
+// ErrNoCommits is returned for a repository without any commits yet
+var ErrNoCommits = errors.New("the repository has no commits")
+
+// GetBranch returns the name of the checked out branch, ErrDetachedHead if
+// HEAD is detached
 func (r *Manager) GetBranch() (string, error) {
-	hash, err := r.repo.Head()
-	if err != nil {
+	head, err := r.repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return "", ErrNoCommits
+	} else if err != nil {
 		return "", err
 	}
-	branch := hash.Name().Short()
-	return branch, nil
+	if !head.Name().IsBranch() {
+		return "", ErrDetachedHead
+	}
+	return head.Name().Short(), nil
 }
 
+// RefBranch returns the branch rev names, a local branch or a remote tracking
+// branch without the remote (origin/main is main). Anything else, a tag or a
+// hash, falls back to the current branch.
+func (r *Manager) RefBranch(rev string) (string, error) {
+	if _, err := r.repo.Reference(plumbing.NewBranchReferenceName(rev), false); err == nil {
+		return rev, nil
+	}
+	if _, err := r.repo.Reference(plumbing.ReferenceName("refs/remotes/"+rev), false); err == nil {
+		if idx := strings.Index(rev, "/"); idx >= 0 {
+			return rev[idx+1:], nil
+		}
+	}
+	return r.GetBranch()
+}
+
+// ReleaseCommitPrefix starts the message of commits made by the tool itself,
+// these are skipped when building changelogs
+const ReleaseCommitPrefix = "Updated version number to "
+
 func (r *Manager) CommitVersionFile(fname, user, email, version string) error {
 	w, err := r.repo.Worktree()
-	CheckIfError(err, "failed to get repo work tree")
+	if err != nil {
+		return fmt.Errorf("failed to get repo work tree: %w", err)
+	}
 	// w.Add(fname)
-	_, err = exec.Command("git", "add", fname).Output()
-	CheckIfError(err, "failed to add version file")
-	commit, err := w.Commit("Updated version number to "+version, &git.CommitOptions{
+	if _, err := exec.Command("git", "add", fname).Output(); err != nil {
+		return fmt.Errorf("failed to add version file: %w", err)
+	}
+	commit, err := w.Commit(ReleaseCommitPrefix+version, &git.CommitOptions{
 		Author: &object.Signature{
 			Name:  user,
 			Email: email,
 			When:  time.Now(),
 		},
 	})
-	CheckIfError(err, "failed to commit version file")
+	if err != nil {
+		return fmt.Errorf("failed to commit version file: %w", err)
+	}
 	log.Info().Msgf("created commit hash for version file: %s", commit)
-	return err
+	return nil
 }
 
 // PushCommitToRemote pushes local commits to remote
@@ -265,40 +793,47 @@ func (r *Manager) PushCommitToRemote(remote string, auth transport.AuthMethod) (
 	return fmt.Sprintf("pushed commits to remote %s", remote), err
 }
 
-var pat = regexp.MustCompile(`^(?P<year>\d{4})\.(?P<month>\d{2})\.(?P<release>\d{3,})-.*$`)
+// periodPattern returns the pattern used to scan for the date releases of the
+// given period (see datePeriod), taking the tag prefix and the number prefix
+// and suffix into account. The release number is matched at any width, so
+// releases made with a different --inc-fmt still count. Releases of the whole
+// repository (2024.06.001) count as well as those of components
+// (2024.06.002-api), every component shares the counter so the version always
+// increases.
+func (r *Manager) periodPattern(period string) *regexp.Regexp {
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(r.TagPrefix+period+r.NumberPrefix) + `(?P<release>\d+)` + regexp.QuoteMeta(r.NumberSuffix) + `(?:-.+)?$`)
+}
 
 type calVerStandard struct {
-	Year    uint64
-	Month   uint64
-	Release uint64
+	Prefix       string // The tag prefix, see Manager.TagPrefix
+	Period       string // The date part, 2024.06. with the default format
+	Release      uint64
+	NumberPrefix string
+	NumberSuffix string
+	IncFormat    string // Format of the release number, defaults to %03d
 }
 
-func newCalVerStandard(year, month, rel uint64) *calVerStandard {
+func newCalVerStandard(period string, rel uint64) *calVerStandard {
 	return &calVerStandard{
-		Year:    year,
-		Month:   month,
+		Period:  period,
 		Release: rel,
 	}
 }
 
 func (c *calVerStandard) String() string {
-	return fmt.Sprintf("Release: %d.%02d.%03d", c.Year, c.Month, c.Release)
+	return fmt.Sprintf("Release: %s%03d", c.Period, c.Release)
 }
 
 func (c *calVerStandard) FormatRelease(release string) string {
+	incFormat := c.IncFormat
+	if incFormat == "" {
+		incFormat = "%03d"
+	}
+	number := fmt.Sprintf(incFormat, c.Release)
 	if release == "" {
-		return fmt.Sprintf("%d.%02d.%03d", c.Year, c.Month, c.Release)
+		return fmt.Sprintf("%s%s%s%s%s", c.Prefix, c.Period, c.NumberPrefix, number, c.NumberSuffix)
 	}
-	return fmt.Sprintf("%d.%02d.%03d-%s", c.Year, c.Month, c.Release, release)
-}
-
-func (c *calVerStandard) IsAfter(other *calVerStandard) bool {
-	// Check to see of the other is greater than us, return the opposite of that
-	return !(other.Year > c.Year || other.Month > c.Month || other.Release > c.Release)
-}
-
-func (c *calVerStandard) IsSameMonth(other *calVerStandard) bool {
-	return other.Year == c.Year && other.Month == c.Month
+	return fmt.Sprintf("%s%s%s%s%s-%s", c.Prefix, c.Period, c.NumberPrefix, number, c.NumberSuffix, release)
 }
 
 func (c *calVerStandard) Increase() *calVerStandard {
@@ -307,57 +842,93 @@ func (c *calVerStandard) Increase() *calVerStandard {
 }
 
 func (r *Manager) getNextDateString(name string, now time.Time) string {
+	return r.getNextDateVersion(now).FormatRelease(name)
+}
+
+func (r *Manager) getNextDateVersion(now time.Time) *calVerStandard {
 	// Create a new calVerStandard object to use as a baseline comparison. We do
 	// this with a 0 release time so this function can blindly call .Increase()
 	// at the end and not have to deal with a case where we created our own
 	// versus a case where we found another tag. If we find one (say .023) we'll
 	// have to increase it, but I want to reduce the branches so I just set this
 	// to 0, so the default entry will be 001
-	latest := newCalVerStandard(uint64(now.Year()), uint64(now.Month()), 0)
+	latest := newCalVerStandard(r.datePeriod(now), 0)
+	// Only tags of the current period are scanned, we're not interested in
+	// past or future releases.
+	scan := r.periodPattern(latest.Period)
 	for _, release := range r.releases {
-		if pat.MatchString(release.Tag) {
-			results := pat.FindStringSubmatch(release.Tag)
-			year, _ := strconv.ParseUint(results[1], 10, 64)
-			month, _ := strconv.ParseUint(results[2], 10, 64)
-			relNum, _ := strconv.ParseUint(results[3], 10, 64)
-			rev := newCalVerStandard(year, month, relNum)
-			// Make sure the tag we're comparing is of our YYYY.MM, if it's not,
-			// we don't even bother comparing, we're not interested in past or
-			// future releases.
-			if !rev.IsSameMonth(latest) {
-				// Future time
-				continue
-			}
-			if rev.IsAfter(latest) {
-				latest = rev
+		if results := scan.FindStringSubmatch(release.Tag); results != nil {
+			relNum, _ := strconv.ParseUint(results[1], 10, 64)
+			if relNum > latest.Release {
+				latest.Release = relNum
 			}
 		}
 	}
 
 	// Always increase the release before returning, this way we always get a
 	// unique one.
-	return latest.Increase().FormatRelease(name)
+	latest.Prefix, latest.NumberPrefix, latest.NumberSuffix = r.TagPrefix, r.NumberPrefix, r.NumberSuffix
+	latest.IncFormat = r.incFmt
+	return latest.Increase()
+}
+
+var patIncWidth = regexp.MustCompile(`^%0?(\d+)d$`)
+
+// patIncFormat is a single, optionally zero padded, integer directive. Space
+// padding (%3d) can't be used in a tag name and text around the number goes in
+// NumberPrefix and NumberSuffix.
+var patIncFormat = regexp.MustCompile(`^%(?:0[1-9]\d*)?d$`)
+
+// ValidateIncrementFormat returns an error unless format is a single integer
+// directive, %d or %0<width>d
+func ValidateIncrementFormat(format string) error {
+	if !patIncFormat.MatchString(format) {
+		return fmt.Errorf("increment format %q has to be a single integer directive, %%d or %%0<width>d like %%03d", format)
+	}
+	return nil
+}
+
+// CheckNumberWidth returns an error if the next date release number has
+// outgrown the width of the increment format (1000 with %03d), tags that are
+// suddenly wider break anything parsing them by width
+func (r *Manager) CheckNumberWidth() error {
+	results := patIncWidth.FindStringSubmatch(r.incFmt)
+	if results == nil {
+		return nil
+	}
+	width, _ := strconv.Atoi(results[1])
+	next := r.getNextDateVersion(r.Now())
+	if digits := len(strconv.FormatUint(next.Release, 10)); digits > width {
+		return fmt.Errorf("the next release number %d doesn't fit in %d digits, increase --inc-width or pass --allow-width-overflow", next.Release, width)
+	}
+	return nil
 }
 
 // GetProposedName returns a proposed name for the next release tag
 func (r *Manager) GetProposedName(name string) string {
-	now := time.Now()
-	return r.getNextDateString(name, now)
+	return r.getNextDateString(name, r.Now())
 }
 
 // GetProposedDate returns a proposed name for the next release tag
 func (r *Manager) GetProposedDate() string {
-	now := time.Now()
-	return r.getNextDateString("", now)
+	return r.getNextDateString("", r.Now())
 }
 
-var patSem = regexp.MustCompile(`^(?P<major>\d+)\.(?P<minor>\d+)\.(?P<patch>\d+)-(?P<release>\d+)$`)
+// patSem matches the semver releases counted for the next release, like
+// patSemVersion it rejects leading zeros (01.2.3-1 isn't semver)
+var patSem = regexp.MustCompile(`^(?P<major>0|[1-9]\d*)\.(?P<minor>0|[1-9]\d*)\.(?P<patch>0|[1-9]\d*)-(?:rc(?P<rc>\d+)|(?P<release>\d+))` + treeSegment + `$`)
 
 type semVerStandard struct {
-	Major   uint64
-	Minor   uint64
-	Patch   uint64
-	Release uint64
+	Major           uint64
+	Minor           uint64
+	Patch           uint64
+	Release         uint64
+	RC              uint64 // The release candidate number, 1.2.0-rc2 instead of 1.2.0-<release> if set
+	lastRC          uint64 // The release candidate this release follows, see Increase
+	Prefix          string // The tag prefix, see Manager.TagPrefix
+	BranchSeparator string
+	BranchSlash     string
+	StableBranches  []string
 }
 
 func newSemVerStandard(major, minor, patch, rel uint64) *semVerStandard {
@@ -370,77 +941,185 @@ func newSemVerStandard(major, minor, patch, rel uint64) *semVerStandard {
 }
 
 func (c *semVerStandard) String() string {
-	return fmt.Sprintf("Release: %d.%d.%d-%d", c.Major, c.Minor, c.Patch, c.Release)
+	return fmt.Sprintf("Release: %d.%d.%d-%s", c.Major, c.Minor, c.Patch, c.releaseSegment())
+}
+
+// releaseSegment returns what follows the version, the release number or the
+// release candidate (rc2)
+func (c *semVerStandard) releaseSegment() string {
+	if c.RC > 0 {
+		return fmt.Sprintf("rc%d", c.RC)
+	}
+	return strconv.FormatUint(c.Release, 10)
 }
 
 func (c *semVerStandard) FormatRelease(release string, branch string) string {
-	prefix := ""
-	if !(branch == "master" || branch == "main") {
-		prefix = fmt.Sprintf("%s-", branch)
+	prefix := c.Prefix
+	stable := c.StableBranches
+	if len(stable) == 0 {
+		stable = defaultStableBranches
+	}
+	isStable := false
+	for _, name := range stable {
+		isStable = isStable || name == branch
+	}
+	if !isStable {
+		separator := c.BranchSeparator
+		if separator == "" {
+			separator = "-"
+		}
+		if c.BranchSlash != "" {
+			branch = strings.ReplaceAll(branch, "/", c.BranchSlash)
+		}
+		prefix += branch + separator
 	}
 
 	if release == "" {
-		return fmt.Sprintf("%s%d.%d.%d-%d", prefix, c.Major, c.Minor, c.Patch, c.Release)
+		return fmt.Sprintf("%s%d.%d.%d-%s", prefix, c.Major, c.Minor, c.Patch, c.releaseSegment())
+	}
+	return fmt.Sprintf("%s%d.%d.%d-%s-%s", prefix, c.Major, c.Minor, c.Patch, c.releaseSegment(), release)
+}
+
+// key orders versions, release candidates come before the numbered releases
+// of their version
+func (c *semVerStandard) key() []uint64 {
+	if c.RC > 0 {
+		return []uint64{c.Major, c.Minor, c.Patch, 0, c.RC}
 	}
-	return fmt.Sprintf("%s%d.%d.%d-%d-%s", prefix, c.Major, c.Minor, c.Patch, c.Release, release)
+	return []uint64{c.Major, c.Minor, c.Patch, 1, c.Release}
 }
 
 func (c *semVerStandard) IsAfter(other *semVerStandard) bool {
-	// Check to see of the other is greater than us, return the opposite of that
-	return !(other.Major > c.Major ||
-		(other.Major <= c.Major && other.Minor > c.Minor) ||
-		(other.Major <= c.Major && other.Minor <= c.Minor && other.Patch > c.Patch) ||
-		(other.Major <= c.Major && other.Minor <= c.Minor && other.Patch <= c.Patch && other.Release > c.Release))
+	return compareKeys(c.key(), other.key()) >= 0
 }
 
+// Increase moves on to the next release, the release after a release
+// candidate is the first numbered (final) release of its version
 func (c *semVerStandard) Increase() *semVerStandard {
+	if c.RC > 0 {
+		c.lastRC, c.RC = c.RC, 0
+	}
 	c.Release++
 	return c
 }
 
-func (c *semVerStandard) IncrementVersion(incMajor, incMinor, incPatch bool) {
-	if incMajor {
-		c.Major++
-		c.Release = 1
+// IncrementRC turns the next release into a release candidate: the first one
+// of the version after a major, minor or patch increment (bumped) or of a
+// version without releases, otherwise the one after the latest release
+// candidate. A version that already has a numbered release can't get more
+// release candidates.
+func (c *semVerStandard) IncrementRC(bumped bool) error {
+	switch {
+	case bumped, c.lastRC == 0 && c.Release == 1:
+		c.RC = 1
+	case c.lastRC > 0:
+		c.RC = c.lastRC + 1
+	default:
+		return fmt.Errorf("%d.%d.%d already has a numbered release, increment the major, minor or patch version to start release candidates of the next one", c.Major, c.Minor, c.Patch)
 	}
-	if incMinor {
+	c.Release = 0
+	return nil
+}
+
+// IncrementVersion applies a single major, minor or patch increment, BumpNone
+// leaves the version alone
+func (c *semVerStandard) IncrementVersion(bump Bump) {
+	switch bump {
+	case BumpMajor:
+		c.Major++
+	case BumpMinor:
 		c.Minor++
-		c.Release = 1
-	}
-	if incPatch {
+	case BumpPatch:
 		c.Patch++
-		c.Release = 1
+	default:
+		return
 	}
+	c.Release = 1
 }
 
-func (r *Manager) getNextSemVersion() *semVerStandard {
+// getLatestSemVersion returns the highest semantic version found in the
+// existing tags along with the tag it was parsed from, the tag will be empty
+// if there are no semver releases yet
+func (r *Manager) getLatestSemVersion() (*semVerStandard, string) {
 	// Create a new semVerStandard object to use as a baseline comparison. We do
-	// this with a 0 release time so this function can blindly call .Increase()
-	// at the end and not have to deal with a case where we created our own
-	// versus a case where we found another tag. If we find one (say .023) we'll
-	// have to increase it, but I want to reduce the branches so I just set this
-	// to 0, so the default entry will be 001
+	// this with a 0 release time so callers can blindly call .Increase()
+	// and not have to deal with a case where we created our own versus a case
+	// where we found another tag. If we find one (say .023) we'll have to
+	// increase it, but I want to reduce the branches so I just set this to 0,
+	// so the default entry will be 001
 	latest := newSemVerStandard(0, 0, 0, 0)
+	latestTag := ""
 	for _, release := range r.releases {
-		if patSem.MatchString(release.Tag) {
-			results := patSem.FindStringSubmatch(release.Tag)
-			major, _ := strconv.ParseUint(results[1], 10, 64)
-			minor, _ := strconv.ParseUint(results[2], 10, 64)
-			patch, _ := strconv.ParseUint(results[3], 10, 64)
-			relNum, _ := strconv.ParseUint(results[4], 10, 64)
-			rev := newSemVerStandard(major, minor, patch, relNum)
-			if rev.IsAfter(latest) {
-				latest = rev
-			}
+		tag, ok := r.trimTagPrefix(release.Tag)
+		if ok {
+			tag, ok = r.ownSemTag(tag)
+		}
+		if !ok {
+			continue
+		}
+		// Tags that aren't our semver releases (v1.2, 1.2.3-beta) are
+		// skipped, as are numbers too large to compare
+		rev, ok := parseSemTag(tag)
+		if !ok {
+			continue
+		}
+		// The baseline 0.0.0-0 sorts after any release candidate of 0.0.0
+		if latestTag == "" || rev.IsAfter(latest) {
+			latest = rev
+			latestTag = release.Tag
 		}
 	}
+	return latest, latestTag
+}
+
+// parseSemTag parses a semver release tag (without prefix and component),
+// ok is false if it isn't one
+func parseSemTag(tag string) (*semVerStandard, bool) {
+	results := patSem.FindStringSubmatch(tag)
+	if results == nil {
+		return nil, false
+	}
+	numbers := []uint64{}
+	for _, name := range []string{"major", "minor", "patch", "release", "rc"} {
+		value := results[patSem.SubexpIndex(name)]
+		if value == "" {
+			// Either the release or the rc is set
+			numbers = append(numbers, 0)
+			continue
+		}
+		number, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		numbers = append(numbers, number)
+	}
+	rev := newSemVerStandard(numbers[0], numbers[1], numbers[2], numbers[3])
+	rev.RC = numbers[4]
+	return rev, true
+}
+
+func (r *Manager) getNextSemVersion() *semVerStandard {
+	latest, _ := r.getLatestSemVersion()
 
 	// Always increase the release before returning, this way we always get a
 	// unique one.
 	return latest.Increase()
 }
 
+// GetLatestSemTag returns the tag of the highest semantic version released so
+// far, or an empty string if there are no semver releases yet
+func (r *Manager) GetLatestSemTag() string {
+	_, tag := r.getLatestSemVersion()
+	return tag
+}
+
+// defaultStableBranches don't get a branch segment in semver releases
+var defaultStableBranches = []string{"main", "master"}
+
 // GetProposedName returns a proposed name for the next release tag
 func (r *Manager) GetProposedSemName() *semVerStandard {
-	return r.getNextSemVersion()
+	next := r.getNextSemVersion()
+	next.BranchSeparator, next.BranchSlash, next.StableBranches = r.BranchSeparator, r.BranchSlash, r.StableBranches
+	next.Prefix = r.TagPrefix
+	return next
 }
@@ -0,0 +1,885 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/file"
+	"github.com/rs/zerolog"
+)
+
+func TestMain(m *testing.M) {
+	// Only the test results, not the manager's debug logging
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	os.Exit(m.Run())
+}
+
+// testSignature is the author and committer of the commits of test
+// repositories
+var testSignature = &object.Signature{
+	Name:  "Test",
+	Email: "test@example.com",
+	When:  time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+}
+
+// newTestRepo creates a repository with a single commit in a temporary
+// directory
+func newTestRepo(t *testing.T) (string, *git.Repository) {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("failed to create the test repository: %s", err)
+	}
+	commitFile(t, repo, "README", "initial commit")
+	return dir, repo
+}
+
+// commitFile commits a change to name in the repository's worktree and
+// returns the hash of the commit, dated a minute after its parent
+func commitFile(t *testing.T, repo *git.Repository, name, message string) plumbing.Hash {
+	t.Helper()
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("failed to get the worktree: %s", err)
+	}
+	path := filepath.Join(w.Filesystem.Root(), name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create the directory of %s: %s", name, err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("failed to open %s: %s", name, err)
+	}
+	f.WriteString(message + "\n")
+	f.Close()
+	if _, err := w.Add(name); err != nil {
+		t.Fatalf("failed to add %s: %s", name, err)
+	}
+	signature := *testSignature
+	if head, err := repo.Head(); err == nil {
+		if parent, err := repo.CommitObject(head.Hash()); err == nil {
+			signature.When = parent.Committer.When.Add(time.Minute)
+		}
+	}
+	hash, err := w.Commit(message, &git.CommitOptions{Author: &signature, Committer: &signature})
+	if err != nil {
+		t.Fatalf("failed to commit %s: %s", name, err)
+	}
+	return hash
+}
+
+// tagHead tags HEAD of the repository, annotated if message isn't empty
+func tagHead(t *testing.T, repo *git.Repository, name, message string) {
+	t.Helper()
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatalf("failed to resolve HEAD: %s", err)
+	}
+	var opts *git.CreateTagOptions
+	if message != "" {
+		opts = &git.CreateTagOptions{Message: message, Tagger: testSignature}
+	}
+	if _, err := repo.CreateTag(name, head.Hash(), opts); err != nil {
+		t.Fatalf("failed to create tag %s: %s", name, err)
+	}
+}
+
+// addTestRemote creates a bare repository in a temporary directory and adds
+// it as the remote name of repo, returning the remote repository
+func addTestRemote(t *testing.T, repo *git.Repository, name string) *git.Repository {
+	t.Helper()
+	dir := t.TempDir()
+	remote, err := git.PlainInit(dir, true)
+	if err != nil {
+		t.Fatalf("failed to create the remote repository: %s", err)
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: name, URLs: []string{dir}}); err != nil {
+		t.Fatalf("failed to add remote %s: %s", name, err)
+	}
+	return remote
+}
+
+// pushTags pushes every tag of repo to the remote
+func pushTags(t *testing.T, repo *git.Repository, remote string) {
+	t.Helper()
+	err := repo.Push(&git.PushOptions{RemoteName: remote, RefSpecs: []config.RefSpec{"refs/tags/*:refs/tags/*"}})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		t.Fatalf("failed to push the tags to %s: %s", remote, err)
+	}
+}
+
+// newTestManager opens a release manager for dir with the default formats
+func newTestManager(t *testing.T, dir string) *Manager {
+	t.Helper()
+	rm, err := NewManager(dir, DefaultDateFormat, "%03d")
+	if err != nil {
+		t.Fatalf("failed to set up the release manager: %s", err)
+	}
+	return rm
+}
+
+func TestNewManager(t *testing.T) {
+	repoDir, _ := newTestRepo(t)
+	subDir := filepath.Join(repoDir, "sub", "dir")
+	if err := os.MkdirAll(subDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		dir     string
+		timeFmt string
+		incFmt  string
+		wantErr string
+	}{
+		{name: "repository root", dir: repoDir, timeFmt: DefaultDateFormat, incFmt: "%03d"},
+		{name: "subdirectory", dir: subDir, timeFmt: DefaultDateFormat, incFmt: "%d"},
+		{name: "not a repository", dir: t.TempDir(), timeFmt: DefaultDateFormat, incFmt: "%03d", wantErr: "failed to find repo dir"},
+		{name: "date format without year", dir: repoDir, timeFmt: "%m.", incFmt: "%03d", wantErr: "year"},
+		{name: "empty date format", dir: repoDir, timeFmt: "", incFmt: "%03d", wantErr: "empty"},
+		{name: "space padded increment", dir: repoDir, timeFmt: DefaultDateFormat, incFmt: "%3d", wantErr: "single integer directive"},
+		{name: "increment with text", dir: repoDir, timeFmt: DefaultDateFormat, incFmt: "b%03d", wantErr: "single integer directive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm, err := NewManager(tt.dir, tt.timeFmt, tt.incFmt)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("NewManager() error = %s", err)
+				}
+				if rm.repoDir != repoDir {
+					t.Errorf("repoDir = %s, want %s", rm.repoDir, repoDir)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("NewManager() error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateTag(t *testing.T) {
+	tests := []struct {
+		name           string
+		existing       string // Tag at HEAD before the manager is opened
+		tag            string
+		comment        string
+		user, email    string
+		target         string
+		allowDuplicate bool
+		wantErr        error
+		wantErrText    string
+	}{
+		{name: "lightweight", tag: "2024.06.001"},
+		{name: "annotated", tag: "2024.06.001", comment: "release", user: "B", email: "b@example.com"},
+		{name: "annotated without user", tag: "2024.06.001", comment: "release", email: "b@example.com", wantErrText: "both user and email are required"},
+		{name: "annotated without email", tag: "2024.06.001", comment: "release", user: "B", wantErrText: "both user and email are required"},
+		{name: "unknown target", tag: "2024.06.001", target: "nope", wantErrText: "unable to resolve nope"},
+		{name: "duplicate", existing: "2024.06.001", tag: "2024.06.002", wantErr: ErrAlreadyReleased},
+		{name: "duplicate allowed", existing: "2024.06.001", tag: "2024.06.002", allowDuplicate: true},
+		{name: "other component", existing: "2024.06.001-api", tag: "2024.06.002-web"},
+		{name: "existing tag", existing: "2024.06.001", tag: "2024.06.001", wantErr: git.ErrTagExists},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			if tt.existing != "" {
+				tagHead(t, repo, tt.existing, "")
+			}
+			rm := newTestManager(t, dir)
+			rm.Target = tt.target
+			rm.AllowDuplicate = tt.allowDuplicate
+			ref, err := rm.CreateTag(tt.tag, tt.comment, tt.user, tt.email)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("CreateTag() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantErrText != "":
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrText) {
+					t.Fatalf("CreateTag() error = %v, want one containing %q", err, tt.wantErrText)
+				}
+				return
+			case err != nil:
+				t.Fatalf("CreateTag() error = %s", err)
+			}
+			if ref.Name().Short() != tt.tag {
+				t.Errorf("created %s, want %s", ref.Name().Short(), tt.tag)
+			}
+			_, err = repo.TagObject(ref.Hash())
+			if annotated := err == nil; annotated != (tt.comment != "") {
+				t.Errorf("annotated = %t, want %t", annotated, tt.comment != "")
+			}
+		})
+	}
+}
+
+func TestCreateTagReproducible(t *testing.T) {
+	pinned := time.Date(2024, 6, 1, 12, 0, 0, 0, time.FixedZone("", 2*60*60))
+	tests := []struct {
+		name      string
+		first     time.Time
+		second    time.Time
+		wantEqual bool
+	}{
+		{name: "pinned", first: pinned, second: pinned, wantEqual: true},
+		{name: "same instant in another zone", first: pinned, second: pinned.UTC()},
+		{name: "different dates", first: pinned, second: pinned.Add(time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hashes := []plumbing.Hash{}
+			for _, date := range []time.Time{tt.first, tt.second} {
+				// A fresh repository each time, with the same commit
+				dir, _ := newTestRepo(t)
+				rm := newTestManager(t, dir)
+				rm.TagDate = date
+				ref, err := rm.CreateTag("2024.06.001", "Release 2024.06.001", "B", "b@example.com")
+				if err != nil {
+					t.Fatalf("CreateTag() error = %s", err)
+				}
+				hashes = append(hashes, ref.Hash())
+			}
+			if equal := hashes[0] == hashes[1]; equal != tt.wantEqual {
+				t.Errorf("tag objects %s and %s, want equal = %t", hashes[0], hashes[1], tt.wantEqual)
+			}
+		})
+	}
+}
+
+func TestCreateTags(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	tagHead(t, repo, "2024.06.001-api", "")
+	rm := newTestManager(t, dir)
+	specs := []TagSpec{
+		{Name: "2024.06.002-web"},
+		{Name: "2024.06.002-api"}, // Already released as 2024.06.001-api
+		{Name: "2024.06.002-db", Message: "release"},
+		{Name: "2024.06.002-cli"},
+	}
+	results := rm.CreateTags(specs, "B", "b@example.com", 3)
+	if len(results) != len(specs) {
+		t.Fatalf("got %d results, want %d", len(results), len(specs))
+	}
+	for idx, result := range results {
+		if result.Tag != specs[idx].Name {
+			t.Errorf("result %d is for %s, want %s", idx, result.Tag, specs[idx].Name)
+		}
+		wantDuplicate := specs[idx].Name == "2024.06.002-api"
+		if gotDuplicate := errors.Is(result.Err, ErrAlreadyReleased); gotDuplicate != wantDuplicate || (!wantDuplicate && result.Err != nil) {
+			t.Errorf("%s: error = %v", result.Tag, result.Err)
+		}
+	}
+}
+
+func TestNextDateVersionPastPaddedWidth(t *testing.T) {
+	june := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		existing []string
+		incFmt   string
+		want     string
+	}{
+		{name: "first release", incFmt: "%03d", want: "2024.06.001"},
+		{name: "unpadded before padded", existing: []string{"2024.06.9", "2024.06.010"}, incFmt: "%03d", want: "2024.06.011"},
+		{name: "padded before unpadded", existing: []string{"2024.06.010", "2024.06.11"}, incFmt: "%d", want: "2024.06.12"},
+		{name: "past the width", existing: []string{"2024.06.999"}, incFmt: "%03d", want: "2024.06.1000"},
+		{name: "wider padding", existing: []string{"2024.06.0042-api"}, incFmt: "%03d", want: "2024.06.043"},
+		{name: "other period", existing: []string{"2024.05.100"}, incFmt: "%03d", want: "2024.06.001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			for _, tag := range tt.existing {
+				tagHead(t, repo, tag, "")
+			}
+			rm, err := NewManager(dir, DefaultDateFormat, tt.incFmt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := rm.getNextDateString("", june); got != tt.want {
+				t.Errorf("next release = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckNumberWidth(t *testing.T) {
+	tests := []struct {
+		name    string
+		latest  uint64
+		incFmt  string
+		wantErr bool
+	}{
+		{name: "fits", latest: 998, incFmt: "%03d"},
+		{name: "overflows", latest: 999, incFmt: "%03d", wantErr: true},
+		{name: "wider format", latest: 999, incFmt: "%04d"},
+		{name: "no width", latest: 99999, incFmt: "%d"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			rm := newTestManager(t, dir)
+			tagHead(t, repo, fmt.Sprintf("%s%d", rm.datePeriod(rm.Now()), tt.latest), "")
+			rm, err := NewManager(dir, DefaultDateFormat, tt.incFmt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := rm.CheckNumberWidth(); (err != nil) != tt.wantErr {
+				t.Errorf("CheckNumberWidth() error = %v, want error %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateIncrementFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		wantErr bool
+	}{
+		{format: "%d"},
+		{format: "%03d"},
+		{format: "%010d"},
+		{format: "%3d", wantErr: true},
+		{format: "%00d", wantErr: true},
+		{format: "%03x", wantErr: true},
+		{format: "%03d%d", wantErr: true},
+		{format: "b%03d", wantErr: true},
+		{format: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			if err := ValidateIncrementFormat(tt.format); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateIncrementFormat(%q) error = %v, want error %t", tt.format, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseSemTag(t *testing.T) {
+	tests := []struct {
+		tag    string
+		want   *semVerStandard // nil if it isn't a semver release
+		wantRC uint64
+	}{
+		{tag: "1.2.3-1", want: newSemVerStandard(1, 2, 3, 1)},
+		{tag: "0.0.0-12", want: newSemVerStandard(0, 0, 0, 12)},
+		{tag: "1.2.0-rc1", want: newSemVerStandard(1, 2, 0, 0), wantRC: 1},
+		{tag: "1.2.3-4-tabc1234", want: newSemVerStandard(1, 2, 3, 4)},
+		{tag: "v1.2"},
+		{tag: "1.2"},
+		{tag: "1.2.3"},
+		{tag: "1.2.3-beta"},
+		{tag: "release-old"},
+		{tag: "01.2.3-1"},
+		{tag: "1.02.3-1"},
+		{tag: "1.2.03-1"},
+		{tag: "18446744073709551616.0.0-1"},
+		{tag: "1.2.3-18446744073709551616"},
+		{tag: "18446744073709551615.0.0-1", want: newSemVerStandard(18446744073709551615, 0, 0, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, ok := parseSemTag(tt.tag)
+			if tt.want == nil {
+				if ok {
+					t.Fatalf("parseSemTag(%q) = %s, want no semver release", tt.tag, got)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("parseSemTag(%q) isn't a semver release, want %s", tt.tag, tt.want)
+			}
+			tt.want.RC = tt.wantRC
+			if compareKeys(got.key(), tt.want.key()) != 0 {
+				t.Errorf("parseSemTag(%q) = %s, want %s", tt.tag, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatestSemVersion(t *testing.T) {
+	tests := []struct {
+		name   string
+		tags   []string
+		prefix string
+		want   string // Latest semver release tag
+		next   string // The release after it
+	}{
+		{name: "no releases", tags: []string{"v1.2", "release-old"}, want: "", next: "0.0.0-1"},
+		{name: "messy tags skipped", tags: []string{"v1.2", "1.2.3-beta", "release-old", "1.0.0-2", "01.9.9-1"}, want: "1.0.0-2", next: "1.0.0-3"},
+		{name: "release candidate before its release", tags: []string{"1.2.0-1", "1.2.0-rc1"}, want: "1.2.0-1", next: "1.2.0-2"},
+		{name: "latest release candidate", tags: []string{"1.1.0-4", "1.2.0-rc1", "1.2.0-rc2"}, want: "1.2.0-rc2", next: "1.2.0-1"},
+		{name: "numbers compared, not strings", tags: []string{"1.9.0-1", "1.10.0-1", "1.2.0-10"}, want: "1.10.0-1", next: "1.10.0-2"},
+		{name: "overflow skipped", tags: []string{"1.0.0-1", "99999999999999999999.0.0-1"}, want: "1.0.0-1", next: "1.0.0-2"},
+		{name: "prefix", tags: []string{"1.5.0-1", "v1.2.0-3"}, prefix: "v", want: "v1.2.0-3", next: "v1.2.0-4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			for _, tag := range tt.tags {
+				tagHead(t, repo, tag, "")
+			}
+			rm := newTestManager(t, dir)
+			rm.SemVer = true
+			rm.TagPrefix = tt.prefix
+			if got := rm.GetLatestSemTag(); got != tt.want {
+				t.Errorf("GetLatestSemTag() = %q, want %q", got, tt.want)
+			}
+			if got := rm.GetProposedSemName().FormatRelease("", "main"); got != tt.next {
+				t.Errorf("next release = %s, want %s", got, tt.next)
+			}
+		})
+	}
+}
+
+func TestSemverBranchSegment(t *testing.T) {
+	tests := []struct {
+		name      string
+		tags      []string
+		branch    string
+		slash     string
+		separator string
+		stable    []string
+		want      string
+	}{
+		{name: "slash kept", branch: "feature/x", want: "feature/x-0.0.0-1"},
+		{name: "slash to dash", branch: "feature/x", slash: "-", want: "feature-x-0.0.0-1"},
+		{name: "slash to dot", branch: "feature/x", slash: ".", want: "feature.x-0.0.0-1"},
+		{name: "listed with dashes", tags: []string{"1.2.0-1", "feature-x-1.2.0-1"}, branch: "feature/x", slash: "-", want: "feature-x-1.2.0-2"},
+		{name: "listed with dots", tags: []string{"1.2.0-1", "feature.x-1.2.0-1"}, branch: "feature/x", slash: ".", want: "feature.x-1.2.0-2"},
+		{name: "separator", tags: []string{"1.2.0-3", "feature-x_1.2.0-3"}, branch: "feature/x", slash: "-", separator: "_", want: "feature-x_1.2.0-4"},
+		{name: "default stable branch", tags: []string{"1.2.0-1"}, branch: "main", slash: "-", want: "1.2.0-2"},
+		{name: "configured stable branch", tags: []string{"1.2.0-1"}, branch: "release/1.x", slash: "-", stable: []string{"release/1.x"}, want: "1.2.0-2"},
+		{name: "main no longer stable", branch: "main", stable: []string{"trunk"}, want: "main-0.0.0-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			for _, tag := range tt.tags {
+				tagHead(t, repo, tag, "")
+			}
+			rm := newTestManager(t, dir)
+			rm.SemVer = true
+			rm.BranchSlash, rm.BranchSeparator, rm.StableBranches = tt.slash, tt.separator, tt.stable
+			got := rm.GetProposedSemName().FormatRelease("", tt.branch)
+			if got != tt.want {
+				t.Errorf("next release = %s, want %s", got, tt.want)
+			}
+			// The number comes from the stable releases, but the releases
+			// with a branch segment are listed with them
+			if releases := rm.ListReleases(""); len(releases) != len(tt.tags) {
+				t.Errorf("ListReleases() = %v, want all of %q", releases, tt.tags)
+			}
+			if _, ok := rm.parseVersion(got); !ok {
+				t.Errorf("%s isn't parsed back as a release", got)
+			}
+		})
+	}
+}
+
+func TestGetBranch(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, repo *git.Repository)
+		want    string
+		wantErr error
+	}{
+		{name: "on a branch", want: "master"},
+		{
+			name: "other branch",
+			setup: func(t *testing.T, repo *git.Repository) {
+				head, _ := repo.Head()
+				ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName("feature/x"), head.Hash())
+				if err := repo.Storer.SetReference(ref); err != nil {
+					t.Fatal(err)
+				}
+				if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, ref.Name())); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: "feature/x",
+		},
+		{
+			name: "detached",
+			setup: func(t *testing.T, repo *git.Repository) {
+				head, _ := repo.Head()
+				if err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.HEAD, head.Hash())); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantErr: ErrDetachedHead,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			if tt.setup != nil {
+				tt.setup(t, repo)
+			}
+			rm := newTestManager(t, dir)
+			got, err := rm.GetBranch()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetBranch() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetBranch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewManagerWithoutCommits(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewManager(dir, DefaultDateFormat, "%03d"); !errors.Is(err, ErrNoCommits) {
+		t.Fatalf("NewManager() error = %v, want %v", err, ErrNoCommits)
+	}
+}
+
+// countingTransport is the file transport, counting how many pushes run at
+// the same time. Every push is held for a moment so concurrent ones overlap.
+type countingTransport struct {
+	mu             sync.Mutex
+	active, maxRun int
+}
+
+func (c *countingTransport) fileEndpoint(ep *transport.Endpoint) *transport.Endpoint {
+	file := *ep
+	file.Protocol = "file"
+	return &file
+}
+
+func (c *countingTransport) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	return file.DefaultClient.NewUploadPackSession(c.fileEndpoint(ep), auth)
+}
+
+func (c *countingTransport) NewReceivePackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
+	session, err := file.DefaultClient.NewReceivePackSession(c.fileEndpoint(ep), auth)
+	if err != nil {
+		return nil, err
+	}
+	return &countingSession{ReceivePackSession: session, transport: c}, nil
+}
+
+type countingSession struct {
+	transport.ReceivePackSession
+	transport *countingTransport
+}
+
+func (s *countingSession) ReceivePack(ctx context.Context, req *packp.ReferenceUpdateRequest) (*packp.ReportStatus, error) {
+	c := s.transport
+	c.mu.Lock()
+	c.active++
+	if c.active > c.maxRun {
+		c.maxRun = c.active
+	}
+	c.mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	defer func() {
+		c.mu.Lock()
+		c.active--
+		c.mu.Unlock()
+	}()
+	return s.ReceivePackSession.ReceivePack(ctx, req)
+}
+
+func TestPushTagsToRemoteJobs(t *testing.T) {
+	counting := &countingTransport{}
+	client.InstallProtocol("counting", counting)
+	t.Cleanup(func() { client.InstallProtocol("counting", nil) })
+
+	tags := []string{"2024.06.001-a", "2024.06.001-b", "2024.06.001-c", "2024.06.001-d", "2024.06.001-e", "2024.06.001-f"}
+	for _, jobs := range []int{1, 2, 4} {
+		t.Run(fmt.Sprint(jobs), func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			remoteDir := t.TempDir()
+			remote, err := git.PlainInit(remoteDir, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"counting://" + remoteDir}}); err != nil {
+				t.Fatal(err)
+			}
+			for _, tag := range tags {
+				tagHead(t, repo, tag, "")
+			}
+			counting.maxRun = 0
+
+			rm := newTestManager(t, dir)
+			results := rm.PushTagsToRemote(tags, "origin", nil, jobs)
+			for idx, result := range results {
+				if result.Tag != tags[idx] {
+					t.Errorf("result %d is for %s, want %s", idx, result.Tag, tags[idx])
+				}
+				if result.Err != nil || !result.Pushed {
+					t.Errorf("push of %s failed: %s: %v", result.Tag, result.Message, result.Err)
+				}
+				if _, err := remote.Tag(tags[idx]); err != nil {
+					t.Errorf("remote doesn't have %s: %s", tags[idx], err)
+				}
+			}
+			if counting.maxRun > jobs {
+				t.Errorf("%d pushes ran at once, want at most %d", counting.maxRun, jobs)
+			}
+			if jobs > 1 && counting.maxRun < 2 {
+				t.Errorf("pushes ran one at a time with %d jobs", jobs)
+			}
+		})
+	}
+}
+
+func TestOnlyReachableFrom(t *testing.T) {
+	tests := []struct {
+		name       string
+		semver     bool
+		mainTag    string // On the branch HEAD is on
+		branchTag  string // Higher, on an unrelated branch
+		reachable  bool
+		wantLatest string
+		wantNext   string // Semver only, date releases depend on today
+	}{
+		{name: "semver all tags", semver: true, mainTag: "1.0.0-1", branchTag: "1.5.0-1", wantLatest: "1.5.0-1", wantNext: "1.5.0-2"},
+		{name: "semver reachable only", semver: true, mainTag: "1.0.0-1", branchTag: "1.5.0-1", reachable: true, wantLatest: "1.0.0-1", wantNext: "1.0.0-2"},
+		{name: "date all tags", mainTag: "2024.06.002", branchTag: "2024.06.005", wantLatest: "2024.06.005"},
+		{name: "date reachable only", mainTag: "2024.06.002", branchTag: "2024.06.005", reachable: true, wantLatest: "2024.06.002"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			base := commitFile(t, repo, "file.txt", "Fix the build")
+			tagHead(t, repo, tt.mainTag, "")
+			commitFile(t, repo, "branch.txt", "Work on the branch")
+			tagHead(t, repo, tt.branchTag, "")
+			w, err := repo.Worktree()
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Back to main, the branch is only reachable through its tag
+			if err := w.Reset(&git.ResetOptions{Commit: base, Mode: git.HardReset}); err != nil {
+				t.Fatal(err)
+			}
+			commitFile(t, repo, "file.txt", "Fix the build again")
+
+			rm := newTestManager(t, dir)
+			rm.SemVer = tt.semver
+			if tt.reachable {
+				if err := rm.OnlyReachableFrom("HEAD"); err != nil {
+					t.Fatalf("OnlyReachableFrom() error = %s", err)
+				}
+			}
+			latest, err := rm.GetLatestRelease("")
+			if err != nil {
+				t.Fatalf("GetLatestRelease() error = %s", err)
+			}
+			if latest != tt.wantLatest {
+				t.Errorf("GetLatestRelease() = %s, want %s", latest, tt.wantLatest)
+			}
+			if tt.wantNext != "" {
+				if got := rm.GetProposedSemName().FormatRelease("", "main"); got != tt.wantNext {
+					t.Errorf("next release = %s, want %s", got, tt.wantNext)
+				}
+			}
+		})
+	}
+}
+
+// trackBranch makes branch track the same branch in remote
+func trackBranch(t *testing.T, repo *git.Repository, branch, remote string) {
+	t.Helper()
+	cfg, err := repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Branches[branch] = &config.Branch{Name: branch, Remote: remote, Merge: plumbing.NewBranchReferenceName(branch)}
+	if err := repo.SetConfig(cfg); err != nil {
+		t.Fatalf("failed to set the upstream of %s: %s", branch, err)
+	}
+}
+
+func TestUpstreamRemote(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T, repo *git.Repository)
+		want  string
+	}{
+		{name: "no upstream"},
+		{
+			name:  "upstream",
+			setup: func(t *testing.T, repo *git.Repository) { trackBranch(t, repo, "master", "upstream") },
+			want:  "upstream",
+		},
+		{
+			name: "other branch tracks",
+			setup: func(t *testing.T, repo *git.Repository) {
+				trackBranch(t, repo, "master", "upstream")
+				branchOff(t, repo, "feature")
+			},
+		},
+		{
+			name: "feature branch",
+			setup: func(t *testing.T, repo *git.Repository) {
+				branchOff(t, repo, "feature")
+				trackBranch(t, repo, "feature", "fork")
+			},
+			want: "fork",
+		},
+		{
+			name: "detached",
+			setup: func(t *testing.T, repo *git.Repository) {
+				trackBranch(t, repo, "master", "upstream")
+				head, err := repo.Head()
+				if err != nil {
+					t.Fatal(err)
+				}
+				w, err := repo.Worktree()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Checkout(&git.CheckoutOptions{Hash: head.Hash()}); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			addTestRemote(t, repo, "origin")
+			addTestRemote(t, repo, "upstream")
+			if tt.setup != nil {
+				tt.setup(t, repo)
+			}
+			rm := newTestManager(t, dir)
+			got, err := rm.UpstreamRemote()
+			if err != nil {
+				t.Fatalf("UpstreamRemote() error = %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("UpstreamRemote() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPushTagToRemote(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteTag  string // "same" or "different" target than the local tag, empty for none
+		overwrite  bool
+		wantMsg    string
+		wantErr    error
+		wantTarget string // "local" or "remote", the commit the remote tag ends up on
+	}{
+		{name: "new tag", wantMsg: "pushed tag 2024.06.001 to remote origin", wantTarget: "local"},
+		{name: "same target", remoteTag: "same", wantMsg: "nothing pushed, tag 2024.06.001 already exists in remote origin with the same target", wantTarget: "local"},
+		{name: "different target", remoteTag: "different", wantMsg: "tag 2024.06.001 already exists in remote origin pointing at", wantErr: ErrRemoteTagConflict, wantTarget: "remote"},
+		{name: "different target overwritten", remoteTag: "different", overwrite: true, wantMsg: "overwrote tag 2024.06.001 in remote origin", wantTarget: "local"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			remote := addTestRemote(t, repo, "origin")
+			remoteCommit := commitFile(t, repo, "file.txt", "Fix the build")
+			if tt.remoteTag != "" {
+				tagHead(t, repo, "2024.06.001", "")
+				pushTags(t, repo, "origin")
+			}
+			localCommit := remoteCommit
+			if tt.remoteTag != "same" {
+				localCommit = commitFile(t, repo, "file.txt", "Fix the build again")
+				if tt.remoteTag == "different" {
+					if err := repo.DeleteTag("2024.06.001"); err != nil {
+						t.Fatal(err)
+					}
+				}
+				tagHead(t, repo, "2024.06.001", "")
+			}
+
+			rm := newTestManager(t, dir)
+			rm.OverwriteRemoteTags = tt.overwrite
+			msg, err := rm.PushTagToRemote("2024.06.001", "origin", nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PushTagToRemote() error = %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(msg, tt.wantMsg) {
+				t.Errorf("PushTagToRemote() = %q, want %q", msg, tt.wantMsg)
+			}
+			ref, err := remote.Tag("2024.06.001")
+			if err != nil {
+				t.Fatalf("remote doesn't have the tag: %s", err)
+			}
+			want := localCommit
+			if tt.wantTarget == "remote" {
+				want = remoteCommit
+			}
+			if ref.Hash() != want {
+				t.Errorf("remote tag points at %s, want the %s commit %s", ref.Hash(), tt.wantTarget, want)
+			}
+		})
+	}
+}
+
+func TestNumberPrefixRoundTrip(t *testing.T) {
+	june := time.Date(2024, 6, 15, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		prefix, suffix string
+		existing       []string // Ignored, named without the prefix and suffix
+		wantFirst      string
+		wantNext       string
+	}{
+		{name: "prefix", prefix: "b", existing: []string{"2024.06.007"}, wantFirst: "2024.06.b001", wantNext: "2024.06.b002"},
+		{name: "suffix", suffix: "-build", existing: []string{"2024.06.007"}, wantFirst: "2024.06.001-build", wantNext: "2024.06.002-build"},
+		{name: "both", prefix: "r", suffix: "_x", existing: []string{"2024.06.r009"}, wantFirst: "2024.06.r001_x", wantNext: "2024.06.r002_x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			for _, tag := range tt.existing {
+				tagHead(t, repo, tag, "")
+			}
+			open := func() *Manager {
+				rm := newTestManager(t, dir)
+				rm.NumberPrefix, rm.NumberSuffix = tt.prefix, tt.suffix
+				return rm
+			}
+
+			rm := open()
+			first := rm.getNextDateString("", june)
+			if first != tt.wantFirst {
+				t.Fatalf("first release = %s, want %s", first, tt.wantFirst)
+			}
+			if _, err := rm.CreateTag(first, "", "", ""); err != nil {
+				t.Fatalf("CreateTag() error = %s", err)
+			}
+			commitFile(t, repo, "file.txt", "Fix the build")
+
+			rm = open()
+			if latest, err := rm.GetLatestRelease(""); err != nil || latest != first {
+				t.Errorf("GetLatestRelease() = %s, %v, want %s", latest, err, first)
+			}
+			if next := rm.getNextDateString("", june); next != tt.wantNext {
+				t.Errorf("next release = %s, want %s", next, tt.wantNext)
+			}
+		})
+	}
+}
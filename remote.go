@@ -0,0 +1,129 @@
+package release
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// RemoteTags lists the tags in the remote (like git ls-remote --tags), mapping
+// each tag name to the hash it points at. This is a read-only operation so
+// auth may be nil for remotes that allow anonymous reads.
+func (r *Manager) RemoteTags(remote string, auth transport.AuthMethod) (map[string]string, error) {
+	rem, err := r.repo.Remote(remote)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := rem.List(&git.ListOptions{Auth: auth})
+	if err == transport.ErrEmptyRemoteRepository {
+		return map[string]string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	tags := map[string]string{}
+	for _, ref := range refs {
+		if ref.Name().IsTag() {
+			tags[ref.Name().Short()] = ref.Hash().String()
+		}
+	}
+	return tags, nil
+}
+
+// TagSync is the difference between the local and remote release tags
+type TagSync struct {
+	MissingRemote []string // Local tags the remote doesn't have
+	MissingLocal  []string // Remote tags that haven't been fetched
+	Differ        []string // Tags that exist on both sides but point at different objects
+}
+
+// InSync is true if both sides have exactly the same release tags
+func (t *TagSync) InSync() bool {
+	return len(t.MissingRemote) == 0 && len(t.MissingLocal) == 0 && len(t.Differ) == 0
+}
+
+// CompareRemoteTags diffs the release tags (of the manager's scheme) in the
+// local repository against those in the remote, each list is ordered newest
+// first
+func (r *Manager) CompareRemoteTags(remote string, auth transport.AuthMethod) (*TagSync, error) {
+	remoteTags, err := r.RemoteTags(remote, auth)
+	if err != nil {
+		return nil, err
+	}
+	localTags := map[string]string{}
+	refs, err := r.repo.Tags()
+	if err != nil {
+		return nil, err
+	}
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		localTags[ref.Name().Short()] = ref.Hash().String()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	all := []string{}
+	for tag := range localTags {
+		all = append(all, tag)
+	}
+	for tag := range remoteTags {
+		if _, ok := localTags[tag]; !ok {
+			all = append(all, tag)
+		}
+	}
+	sync := &TagSync{}
+	for _, tag := range r.SortTags(all, "") {
+		localHash, local := localTags[tag]
+		remoteHash, remote := remoteTags[tag]
+		switch {
+		case !remote:
+			sync.MissingRemote = append(sync.MissingRemote, tag)
+		case !local:
+			sync.MissingLocal = append(sync.MissingLocal, tag)
+		case localHash != remoteHash:
+			sync.Differ = append(sync.Differ, tag)
+		}
+	}
+	return sync, nil
+}
+
+// RemoteConflicts returns the tags that already exist in the remote, for
+// tags that are about to be created this means someone else got there first
+func (r *Manager) RemoteConflicts(tags []string, remote string, auth transport.AuthMethod) ([]string, error) {
+	remoteTags, err := r.RemoteTags(remote, auth)
+	if err != nil {
+		return nil, err
+	}
+	conflicts := []string{}
+	for _, tag := range tags {
+		if _, ok := remoteTags[tag]; ok {
+			conflicts = append(conflicts, tag)
+		}
+	}
+	return conflicts, nil
+}
+
+// FetchTags fetches the given tags from the remote, existing local tags are
+// never overwritten
+func (r *Manager) FetchTags(tags []string, remote string, auth transport.AuthMethod) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	refSpecs := []config.RefSpec{}
+	for _, tag := range tags {
+		refSpecs = append(refSpecs, config.RefSpec(fmt.Sprintf("refs/tags/%s:refs/tags/%s", tag, tag)))
+	}
+	err := r.repo.Fetch(&git.FetchOptions{
+		RemoteName: remote,
+		RefSpecs:   refSpecs,
+		Auth:       auth,
+		Tags:       git.NoTags,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return r.loadGitTags()
+}
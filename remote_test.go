@@ -0,0 +1,130 @@
+package release
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// seedSyncRepo creates a repository with the releases 2024.06.001 to
+// 2024.06.003 on consecutive commits and a non-release tag, all pushed to
+// the remote "origin" which is returned with the commits of the releases
+func seedSyncRepo(t *testing.T) (string, *git.Repository, []plumbing.Hash) {
+	t.Helper()
+	dir, repo := newTestRepo(t)
+	remote := addTestRemote(t, repo, "origin")
+	commits := []plumbing.Hash{}
+	for _, tag := range []string{"2024.06.001", "2024.06.002", "2024.06.003"} {
+		commits = append(commits, commitFile(t, repo, "file.txt", "Work for "+tag))
+		tagHead(t, repo, tag, "")
+	}
+	tagHead(t, repo, "not-a-release", "")
+	pushTags(t, repo, "origin")
+	return dir, remote, commits
+}
+
+// setRemoteTag points tag at commit in the remote, removing it if commit is
+// the zero hash
+func setRemoteTag(t *testing.T, remote *git.Repository, tag string, commit plumbing.Hash) {
+	t.Helper()
+	name := plumbing.NewTagReferenceName(tag)
+	var err error
+	if commit.IsZero() {
+		err = remote.Storer.RemoveReference(name)
+	} else {
+		err = remote.Storer.SetReference(plumbing.NewHashReference(name, commit))
+	}
+	if err != nil {
+		t.Fatalf("failed to set %s in the remote: %s", tag, err)
+	}
+}
+
+func TestCompareRemoteTags(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(t *testing.T, remote *git.Repository, commits []plumbing.Hash)
+		want   TagSync
+		inSync bool
+	}{
+		{name: "in sync", inSync: true},
+		{
+			name: "missing from the remote",
+			setup: func(t *testing.T, remote *git.Repository, commits []plumbing.Hash) {
+				setRemoteTag(t, remote, "2024.06.002", plumbing.ZeroHash)
+				setRemoteTag(t, remote, "2024.06.003", plumbing.ZeroHash)
+			},
+			want: TagSync{MissingRemote: []string{"2024.06.003", "2024.06.002"}},
+		},
+		{
+			name: "missing locally",
+			setup: func(t *testing.T, remote *git.Repository, commits []plumbing.Hash) {
+				setRemoteTag(t, remote, "2024.06.004", commits[2])
+				setRemoteTag(t, remote, "other-remote-tag", commits[2])
+			},
+			want: TagSync{MissingLocal: []string{"2024.06.004"}},
+		},
+		{
+			name: "diverged",
+			setup: func(t *testing.T, remote *git.Repository, commits []plumbing.Hash) {
+				setRemoteTag(t, remote, "2024.06.001", plumbing.ZeroHash)
+				setRemoteTag(t, remote, "2024.06.003", commits[1])
+				setRemoteTag(t, remote, "2024.06.005", commits[2])
+			},
+			want: TagSync{MissingRemote: []string{"2024.06.001"}, MissingLocal: []string{"2024.06.005"}, Differ: []string{"2024.06.003"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, remote, commits := seedSyncRepo(t)
+			if tt.setup != nil {
+				tt.setup(t, remote, commits)
+			}
+			rm := newTestManager(t, dir)
+			got, err := rm.CompareRemoteTags("origin", nil)
+			if err != nil {
+				t.Fatalf("CompareRemoteTags() error = %s", err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("CompareRemoteTags() = %+v, want %+v", *got, tt.want)
+			}
+			if got.InSync() != tt.inSync {
+				t.Errorf("InSync() = %t, want %t", got.InSync(), tt.inSync)
+			}
+		})
+	}
+}
+
+func TestReconcileRemoteTags(t *testing.T) {
+	dir, remote, commits := seedSyncRepo(t)
+	setRemoteTag(t, remote, "2024.06.002", plumbing.ZeroHash)
+	setRemoteTag(t, remote, "2024.06.004", commits[2])
+	setRemoteTag(t, remote, "2024.06.003", commits[1])
+
+	rm := newTestManager(t, dir)
+	diff, err := rm.CompareRemoteTags("origin", nil)
+	if err != nil {
+		t.Fatalf("CompareRemoteTags() error = %s", err)
+	}
+	if err := rm.FetchTags(diff.MissingLocal, "origin", nil); err != nil {
+		t.Fatalf("FetchTags() error = %s", err)
+	}
+	if releases := rm.ListReleases(""); len(releases) != 4 || releases[0].Tag != "2024.06.004" {
+		t.Errorf("ListReleases() = %v after the fetch, want 2024.06.004 first of 4", releases)
+	}
+	for _, result := range rm.PushTagsToRemote(diff.MissingRemote, "origin", nil, 1) {
+		if result.Err != nil {
+			t.Fatalf("failed to push %s: %s", result.Tag, result.Err)
+		}
+	}
+
+	got, err := rm.CompareRemoteTags("origin", nil)
+	if err != nil {
+		t.Fatalf("CompareRemoteTags() error = %s", err)
+	}
+	// Tags that point at different objects are never overwritten
+	if want := (TagSync{Differ: []string{"2024.06.003"}}); !reflect.DeepEqual(*got, want) {
+		t.Errorf("CompareRemoteTags() after reconciling = %+v, want %+v", *got, want)
+	}
+}
@@ -0,0 +1,123 @@
+package release
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RepoConfigFile is the name of the per-repository defaults file, looked for
+// in the working directory and its parents up to the repository root
+const RepoConfigFile = ".release.yaml"
+
+// RepoConfig holds the defaults a team can commit in RepoConfigFile, flags
+// given on the command line override them:
+//
+//	format: "%Y.%m."
+//	tz: UTC
+//	prefix: v
+//	semver: false
+//	remote: upstream
+//	ssh_key: ~/.ssh/release_ed25519
+//	components: [api, ui]
+//	pre_hook: make test
+//	component_schemes:
+//	  api: {semver: true}
+//	  assets: {format: "%Y.%j.", prefix: assets-}
+type RepoConfig struct {
+	Path       string   `yaml:"-"`          // The file the config was loaded from, empty if there is none
+	Format     string   `yaml:"format"`     // strftime format of date releases, see --fmt
+	Prefix     string   `yaml:"prefix"`     // Starts date and semver release names, see --prefix
+	TZ         string   `yaml:"tz"`         // Time zone the date of releases is taken in, see --tz
+	SemVer     bool     `yaml:"semver"`     // Use semantic versioning
+	Remote     string   `yaml:"remote"`     // The remote to push to
+	SSHKey     string   `yaml:"ssh_key"`    // The ssh key to push with, relative to the config file
+	Components []string `yaml:"components"` // Known components, in addition to the ones configured in git config
+	PreHook    string   `yaml:"pre_hook"`   // Command to run before creating the tags, see --pre-hook
+
+	// ComponentSchemes name some components differently from the rest
+	ComponentSchemes map[string]ComponentScheme `yaml:"component_schemes"`
+}
+
+// LoadRepoConfig reads the nearest RepoConfigFile from cwd up to the root of
+// the repository containing it. Not finding one isn't an error, the zero
+// RepoConfig is returned.
+func LoadRepoConfig(cwd string) (RepoConfig, error) {
+	cfg := RepoConfig{}
+	dir := filepath.Clean(cwd)
+	for {
+		path := filepath.Join(dir, RepoConfigFile)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			cfg.Path = path
+			return cfg, cfg.parse(data)
+		} else if !os.IsNotExist(err) {
+			return cfg, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		// Don't pick up a file from outside of the repository
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return cfg, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return cfg, nil
+		}
+		dir = parent
+	}
+}
+
+func (c *RepoConfig) parse(data []byte) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	// A misspelled setting would otherwise be silently ignored
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse %s: %w", c.Path, err)
+	}
+	if c.Format != "" {
+		if err := validateDateFormat(c.Format); err != nil {
+			return fmt.Errorf("bad format in %s: %w", c.Path, err)
+		}
+	}
+	if c.TZ != "" {
+		if _, err := time.LoadLocation(c.TZ); err != nil {
+			return fmt.Errorf("bad tz in %s: %w", c.Path, err)
+		}
+	}
+	if err := ValidateTagPrefix(c.Prefix); err != nil {
+		return fmt.Errorf("bad prefix in %s: %w", c.Path, err)
+	}
+	for _, component := range c.Components {
+		if !validComponent(component) {
+			return fmt.Errorf("bad component %q in %s, it has to be usable in a tag name", component, c.Path)
+		}
+	}
+	for component, scheme := range c.ComponentSchemes {
+		if !validComponent(component) {
+			return fmt.Errorf("bad component %q in component_schemes of %s, it has to be usable in a tag name", component, c.Path)
+		}
+		if err := scheme.validate(); err != nil {
+			return fmt.Errorf("bad scheme for component %s in %s: %w", component, c.Path, err)
+		}
+	}
+	if strings.HasPrefix(c.SSHKey, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("failed to expand ssh_key %s in %s: %w", c.SSHKey, c.Path, err)
+		}
+		c.SSHKey = filepath.Join(home, c.SSHKey[2:])
+	} else if c.SSHKey != "" && !filepath.IsAbs(c.SSHKey) {
+		c.SSHKey = filepath.Join(filepath.Dir(c.Path), c.SSHKey)
+	}
+	return nil
+}
+
+func validComponent(component string) bool {
+	return component != "" && !strings.ContainsAny(component, " ~^:?*[\\")
+}
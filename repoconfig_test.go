@@ -0,0 +1,115 @@
+package release
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadRepoConfig(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := `format: "%Y.%j."
+tz: UTC
+prefix: v
+semver: true
+remote: upstream
+ssh_key: keys/release_ed25519
+components: [api, ui]
+pre_hook: make test
+component_schemes:
+  api: {semver: true}
+  assets: {format: "%Y.%m.", prefix: assets-}
+`
+	tests := []struct {
+		name    string
+		files   map[string]string // Path relative to the temporary directory, the repository is in repo
+		cwd     string
+		want    RepoConfig // Path is made absolute
+		wantMsg string
+	}{
+		{name: "none", cwd: "repo"},
+		{
+			name:  "everything",
+			files: map[string]string{"repo/.release.yaml": full},
+			cwd:   "repo",
+			want: RepoConfig{
+				Path:       "repo/.release.yaml",
+				Format:     "%Y.%j.",
+				TZ:         "UTC",
+				Prefix:     "v",
+				SemVer:     true,
+				Remote:     "upstream",
+				SSHKey:     "repo/keys/release_ed25519",
+				Components: []string{"api", "ui"},
+				PreHook:    "make test",
+				ComponentSchemes: map[string]ComponentScheme{
+					"api":    {SemVer: true},
+					"assets": {Format: "%Y.%m.", Prefix: "assets-"},
+				},
+			},
+		},
+		{name: "from a subdirectory", files: map[string]string{"repo/.release.yaml": "remote: upstream\n", "repo/services/api/main.go": ""}, cwd: "repo/services/api", want: RepoConfig{Path: "repo/.release.yaml", Remote: "upstream"}},
+		{name: "nearest wins", files: map[string]string{"repo/.release.yaml": "remote: upstream\n", "repo/services/.release.yaml": "remote: mirror\n"}, cwd: "repo/services", want: RepoConfig{Path: "repo/services/.release.yaml", Remote: "mirror"}},
+		{name: "not outside the repository", files: map[string]string{".release.yaml": "remote: upstream\n"}, cwd: "repo"},
+		{name: "empty", files: map[string]string{"repo/.release.yaml": ""}, cwd: "repo", want: RepoConfig{Path: "repo/.release.yaml"}},
+		{name: "ssh key in the home directory", files: map[string]string{"repo/.release.yaml": "ssh_key: ~/.ssh/id_ed25519\n"}, cwd: "repo", want: RepoConfig{Path: "repo/.release.yaml", SSHKey: filepath.Join(home, ".ssh", "id_ed25519")}},
+		{name: "absolute ssh key", files: map[string]string{"repo/.release.yaml": "ssh_key: /etc/release/key\n"}, cwd: "repo", want: RepoConfig{Path: "repo/.release.yaml", SSHKey: "/etc/release/key"}},
+		{name: "malformed", files: map[string]string{"repo/.release.yaml": "components: [api\n"}, cwd: "repo", wantMsg: "failed to parse"},
+		{name: "misspelled setting", files: map[string]string{"repo/.release.yaml": "remtoe: upstream\n"}, cwd: "repo", wantMsg: "field remtoe not found"},
+		{name: "wrong type", files: map[string]string{"repo/.release.yaml": "semver: sometimes\n"}, cwd: "repo", wantMsg: "failed to parse"},
+		{name: "bad format", files: map[string]string{"repo/.release.yaml": "format: \"%Y.%H.\"\n"}, cwd: "repo", wantMsg: "bad format"},
+		{name: "bad tz", files: map[string]string{"repo/.release.yaml": "tz: Mars/Olympus\n"}, cwd: "repo", wantMsg: "bad tz"},
+		{name: "bad prefix", files: map[string]string{"repo/.release.yaml": "prefix: v1\n"}, cwd: "repo", wantMsg: "bad prefix"},
+		{name: "bad component", files: map[string]string{"repo/.release.yaml": "components: [\"my api\"]\n"}, cwd: "repo", wantMsg: "bad component \"my api\""},
+		{name: "bad component scheme", files: map[string]string{"repo/.release.yaml": "component_schemes:\n  api: {semver: true, format: \"%Y.\"}\n"}, cwd: "repo", wantMsg: "bad scheme for component api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := t.TempDir()
+			if err := os.MkdirAll(filepath.Join(base, "repo", ".git"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			for name, content := range tt.files {
+				path := filepath.Join(base, name)
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.MkdirAll(filepath.Join(base, tt.cwd), 0755); err != nil {
+				t.Fatal(err)
+			}
+			got, err := LoadRepoConfig(filepath.Join(base, tt.cwd))
+			if tt.wantMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantMsg) {
+					t.Fatalf("LoadRepoConfig() error = %v, want one containing %q", err, tt.wantMsg)
+				}
+				// The error names the file
+				if !strings.Contains(err.Error(), ".release.yaml") {
+					t.Errorf("LoadRepoConfig() error = %s, want it to name the file", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadRepoConfig() error = %s", err)
+			}
+			want := tt.want
+			if want.Path != "" {
+				want.Path = filepath.Join(base, want.Path)
+			}
+			if strings.HasPrefix(want.SSHKey, "repo/") {
+				want.SSHKey = filepath.Join(base, want.SSHKey)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("LoadRepoConfig() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
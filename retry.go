@@ -0,0 +1,96 @@
+package release
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/rs/zerolog/log"
+)
+
+// DefaultPushRetryDelay is the wait before the first retry of a failed push if
+// PushRetryDelay isn't set, it doubles with every further retry
+const DefaultPushRetryDelay = time.Second
+
+// transientMessages are (parts of) the messages of errors worth retrying.
+// go-git and the ssh package flatten most network errors into strings.
+var transientMessages = []string{
+	"handshake failed",
+	"connection reset",
+	"connection refused",
+	"broken pipe",
+	"i/o timeout",
+	"unexpected EOF",
+}
+
+// IsTransientPushError is true if a push failed with an error that may go
+// away when trying again, like a dropped connection, a timeout or an http 5xx.
+// Conflicting tags, rejected refs and authentication failures fail the same
+// way every time.
+func IsTransientPushError(err error) bool {
+	switch {
+	case err == nil,
+		errors.Is(err, ErrRemoteTagConflict),
+		errors.Is(err, ErrTagNotSigned),
+		errors.Is(err, transport.ErrAuthenticationRequired),
+		errors.Is(err, transport.ErrAuthorizationFailed),
+		errors.Is(err, transport.ErrInvalidAuthMethod),
+		errors.Is(err, transport.ErrRepositoryNotFound):
+		return false
+	}
+	// go-git wraps the http errors without unwrapping them
+	var unexpected *plumbing.UnexpectedError
+	if errors.As(err, &unexpected) {
+		err = unexpected.Err
+	}
+	var permanent *plumbing.PermanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	var httpErr *githttp.Err
+	if errors.As(err, &httpErr) {
+		code := httpErr.StatusCode()
+		return code == 429 || code >= 500
+	}
+	// The ssh handshake error wraps the authentication failure
+	if strings.Contains(err.Error(), "unable to authenticate") {
+		return false
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	for _, msg := range transientMessages {
+		if strings.Contains(err.Error(), msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// retryPush runs push, and again up to PushRetries times while it fails with a
+// transient error, waiting PushRetryDelay before the first retry and twice as
+// long before each one after that
+func (r *Manager) retryPush(tag, remote string, push func() (string, bool, error)) (msg string, pushed bool, err error) {
+	delay := r.PushRetryDelay
+	if delay <= 0 {
+		delay = DefaultPushRetryDelay
+	}
+	msg, pushed, err = push()
+	for attempt := 1; attempt <= r.PushRetries && IsTransientPushError(err); attempt++ {
+		log.Info().Err(err).Msgf("pushing tag %s to remote %s failed, retrying in %s (%d/%d)", tag, remote, delay, attempt, r.PushRetries)
+		time.Sleep(delay)
+		delay *= 2
+		msg, pushed, err = push()
+		// A failed attempt can still have gotten the tag to the remote, it
+		// was pushed by this run all the same
+		pushed = pushed || err == nil
+	}
+	return msg, pushed, err
+}
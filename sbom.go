@@ -0,0 +1,32 @@
+package release
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// SBOMTrailer returns the value of the TrailerSBOM trailer for the SBOM at
+// path, its file name and sha256 digest (sbom.json sha256:9f86d0...), so the
+// SBOM a release shipped with can be verified later
+func SBOMTrailer(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+	digest := sha256.New()
+	if _, err := io.Copy(digest, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s sha256:%x", filepath.Base(path), digest.Sum(nil)), nil
+}
@@ -0,0 +1,42 @@
+package release
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSBOMTrailer(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sbom.json"), []byte("test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantMsg string
+	}{
+		{name: "file", path: filepath.Join(dir, "sbom.json"), want: "sbom.json sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+		{name: "missing", path: filepath.Join(dir, "missing.json"), wantMsg: "no such file"},
+		{name: "directory", path: dir, wantMsg: "is a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SBOMTrailer(tt.path)
+			if tt.wantMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantMsg) {
+					t.Fatalf("SBOMTrailer() error = %v, want one containing %q", err, tt.wantMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SBOMTrailer() error = %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("SBOMTrailer() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package release
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ComponentScheme overrides how the releases of a single component are named,
+// so one repository can have semver and date released components side by side.
+// An empty Format or Prefix keeps the manager's.
+type ComponentScheme struct {
+	SemVer bool   `yaml:"semver"` // Use semantic versioning instead of date releases
+	Format string `yaml:"format"` // strftime format of date releases, see --fmt
+	Prefix string `yaml:"prefix"` // Starts the release names, see TagPrefix
+}
+
+func (s ComponentScheme) validate() error {
+	if s.SemVer && s.Format != "" {
+		return fmt.Errorf("a semver component can't have a date format")
+	}
+	if s.Format != "" {
+		if err := validateDateFormat(s.Format); err != nil {
+			return err
+		}
+	}
+	return ValidateTagPrefix(s.Prefix)
+}
+
+// ForComponent returns a manager naming, scanning and listing releases with
+// the component's scheme in ComponentSchemes, or r itself if the component
+// doesn't have one. The semver releases of a component with its own scheme
+// are counted from its own tags only. Warnings are recorded in r.
+func (r *Manager) ForComponent(component string) *Manager {
+	scheme, ok := r.ComponentSchemes[component]
+	if !ok || component == "" {
+		return r
+	}
+	mgr := *r
+	mgr.parent = r
+	mgr.SemVer = scheme.SemVer
+	mgr.scanComponent = component
+	if scheme.Format != "" {
+		mgr.timeFmt = scheme.Format
+	}
+	if scheme.Prefix != "" {
+		mgr.TagPrefix = scheme.Prefix
+	}
+	return &mgr
+}
+
+// ownSemTag returns the semver release of a tag without the component suffix
+// of the manager's component (1.2.0-3 for 1.2.0-3-api), ok is false if the tag
+// belongs to another component. Without a component of its own every tag is
+// passed through.
+func (r *Manager) ownSemTag(tag string) (name string, ok bool) {
+	if r.scanComponent == "" {
+		return tag, true
+	}
+	suffix := "-" + r.scanComponent
+	if !strings.HasSuffix(tag, suffix) {
+		return tag, false
+	}
+	return strings.TrimSuffix(tag, suffix), true
+}
@@ -0,0 +1,95 @@
+package release
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// LoadSigningKey loads the private key to sign tags with (see SignKey). key
+// is either the path to an armored secret key (gpg --export-secret-keys
+// --armor) or a key ID or email that is exported from the user's gpg keyring,
+// the way git looks up user.signingkey. passphrase is only called if the key
+// is encrypted.
+func LoadSigningKey(key string, passphrase func() ([]byte, error)) (*openpgp.Entity, error) {
+	armored, err := os.ReadFile(key)
+	if os.IsNotExist(err) {
+		if armored, err = exportSecretKey(key); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(armored))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key %s: %w", key, err)
+	}
+	for _, entity := range entities {
+		if entity.PrivateKey == nil {
+			continue
+		}
+		if err := decryptEntity(entity, passphrase); err != nil {
+			return nil, fmt.Errorf("failed to decrypt signing key %s: %w", key, err)
+		}
+		return entity, nil
+	}
+	return nil, fmt.Errorf("%s has no private key to sign with", key)
+}
+
+// CheckSigningKey signs a dummy payload with key, a key that loaded can
+// still be unable to sign (expired, no signing subkey)
+func CheckSigningKey(key *openpgp.Entity) error {
+	if err := openpgp.DetachSign(io.Discard, key, strings.NewReader("release signing check"), nil); err != nil {
+		return fmt.Errorf("test signature with the signing key failed: %w", err)
+	}
+	return nil
+}
+
+// exportSecretKey exports a secret key from the user's gpg keyring, gpg-agent
+// may ask for the passphrase
+func exportSecretKey(key string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("gpg", "--batch", "--export-secret-keys", "--armor", key)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to export signing key %s from gpg: %w: %s", key, err, strings.TrimSpace(stderr.String()))
+	}
+	if len(output) == 0 {
+		return nil, fmt.Errorf("no secret key %s in the gpg keyring", key)
+	}
+	return output, nil
+}
+
+// decryptEntity decrypts the primary key and subkeys of entity, go-git signs
+// with whichever of them is the signing key
+func decryptEntity(entity *openpgp.Entity, passphrase func() ([]byte, error)) error {
+	keys := []*packet.PrivateKey{entity.PrivateKey}
+	for _, subkey := range entity.Subkeys {
+		if subkey.PrivateKey != nil {
+			keys = append(keys, subkey.PrivateKey)
+		}
+	}
+	var secret []byte
+	for _, key := range keys {
+		if !key.Encrypted {
+			continue
+		}
+		if secret == nil {
+			var err error
+			if secret, err = passphrase(); err != nil {
+				return err
+			}
+		}
+		if err := key.Decrypt(secret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
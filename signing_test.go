@@ -0,0 +1,140 @@
+package release
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+// writeTestSigningKey writes a new armored secret key to a temporary file,
+// encrypted if passphrase isn't empty, and returns its path
+func writeTestSigningKey(t *testing.T, passphrase string) string {
+	t.Helper()
+	entity, err := openpgp.NewEntity("Test", "", "test@example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to generate the signing key: %s", err)
+	}
+	path := filepath.Join(t.TempDir(), "key.asc")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w, err := armor.Encode(f, openpgp.PrivateKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The self signatures are made when the entity is generated, they have
+	// to be serialized before the keys are encrypted
+	if passphrase != "" {
+		if err := entity.PrivateKey.Encrypt([]byte(passphrase)); err != nil {
+			t.Fatal(err)
+		}
+		for _, subkey := range entity.Subkeys {
+			if err := subkey.PrivateKey.Encrypt([]byte(passphrase)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := entity.SerializePrivateWithoutSigning(w, nil); err != nil {
+		t.Fatalf("failed to write the signing key: %s", err)
+	}
+	w.Close()
+	return path
+}
+
+func TestLoadSigningKey(t *testing.T) {
+	errNoPassphrase := errors.New("no passphrase")
+	tests := []struct {
+		name       string
+		encryptAs  string
+		passphrase func() ([]byte, error)
+		wantErr    string
+	}{
+		{name: "unencrypted", passphrase: func() ([]byte, error) { return nil, errNoPassphrase }},
+		{name: "right passphrase", encryptAs: "secret", passphrase: func() ([]byte, error) { return []byte("secret"), nil }},
+		{name: "bad passphrase", encryptAs: "secret", passphrase: func() ([]byte, error) { return []byte("wrong"), nil }, wantErr: "failed to decrypt signing key"},
+		{name: "no passphrase", encryptAs: "secret", passphrase: func() ([]byte, error) { return nil, errNoPassphrase }, wantErr: "no passphrase"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestSigningKey(t, tt.encryptAs)
+			key, err := LoadSigningKey(path, tt.passphrase)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadSigningKey() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadSigningKey() error = %s", err)
+			}
+			// The check --dry-run --sign runs
+			if err := CheckSigningKey(key); err != nil {
+				t.Errorf("CheckSigningKey() error = %s", err)
+			}
+		})
+	}
+}
+
+func TestCheckSigningKeyEncrypted(t *testing.T) {
+	path := writeTestSigningKey(t, "secret")
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	entities, err := openpgp.ReadArmoredKeyRing(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Still encrypted, signing has to fail rather than produce nothing
+	if err := CheckSigningKey(entities[0]); err == nil {
+		t.Fatal("CheckSigningKey() of an encrypted key succeeded")
+	}
+}
+
+func TestSignedTag(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	key, err := LoadSigningKey(writeTestSigningKey(t, ""), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rm := newTestManager(t, dir)
+	rm.SignKey = key
+	ref, err := rm.CreateTag("2024.06.001", "release", "B", "b@example.com")
+	if err != nil {
+		t.Fatalf("CreateTag() error = %s", err)
+	}
+	tag, err := repo.TagObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.PGPSignature == "" {
+		t.Fatal("the tag isn't signed")
+	}
+	if _, err := tag.Verify(armoredPublicKey(t, key)); err != nil {
+		t.Errorf("the tag signature doesn't verify: %s", err)
+	}
+}
+
+// armoredPublicKey returns the armored public key of entity, a keyring to
+// verify its signatures with
+func armoredPublicKey(t *testing.T, entity *openpgp.Entity) string {
+	t.Helper()
+	var b strings.Builder
+	w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return b.String()
+}
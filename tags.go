@@ -0,0 +1,169 @@
+package release
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// RenameTag renames an existing tag, the new tag points at the same commit. A
+// lightweight tag is simply re-pointed, an annotated tag gets a new tag object
+// with the same target, tagger (including the original date) and message.
+// Signatures cover the tag name so they can't be carried over, a warning is
+// logged if one is dropped.
+func (r *Manager) RenameTag(oldName, newName string) error {
+	oldRef, err := r.repo.Tag(oldName)
+	if err != nil {
+		return fmt.Errorf("tag %s does not exist: %w", oldName, err)
+	}
+	if _, err := r.repo.Tag(newName); err == nil {
+		return fmt.Errorf("tag %s already exists", newName)
+	}
+
+	target := oldRef.Hash()
+	tag, err := r.repo.TagObject(oldRef.Hash())
+	if err == nil {
+		// Annotated, rebuild the tag object under the new name
+		if tag.PGPSignature != "" {
+			r.Warnf("tag %s is signed, the signature can't be preserved when renaming and will need to be re-applied", oldName)
+		}
+		tag.Name = newName
+		tag.PGPSignature = ""
+		obj := r.repo.Storer.NewEncodedObject()
+		if err := tag.Encode(obj); err != nil {
+			return err
+		}
+		if target, err = r.repo.Storer.SetEncodedObject(obj); err != nil {
+			return err
+		}
+	} else if err != plumbing.ErrObjectNotFound {
+		return err
+	}
+
+	ref := plumbing.NewHashReference(plumbing.NewTagReferenceName(newName), target)
+	if err := r.repo.Storer.SetReference(ref); err != nil {
+		return err
+	}
+	if err := r.repo.DeleteTag(oldName); err != nil {
+		return err
+	}
+	return r.loadGitTags()
+}
+
+// PushTagRename mirrors a local rename on the remote by pushing the new tag and
+// deleting the old one in a single push
+func (r *Manager) PushTagRename(oldName, newName, remote string, auth transport.AuthMethod) (string, error) {
+	options := &git.PushOptions{
+		RemoteName: remote,
+		RefSpecs: []config.RefSpec{
+			tagToRefspec(newName),
+			config.RefSpec(fmt.Sprintf(":refs/tags/%s", oldName)),
+		},
+		Auth: auth,
+	}
+	err := r.repo.Push(options)
+	if err == git.NoErrAlreadyUpToDate {
+		return fmt.Sprintf("nothing pushed, remote %s already had tag %s and not %s", remote, newName, oldName), nil
+	} else if err != nil {
+		return fmt.Sprintf("failed to rename tag %s to %s in remote %s", oldName, newName, remote), err
+	}
+	return fmt.Sprintf("renamed tag %s to %s in remote %s", oldName, newName, remote), nil
+}
+
+// MatchGlob compiles a glob pattern for matching tag names the way git tag -l
+// does, * and ? match any characters (including /) and [...] matches a
+// character class
+func MatchGlob(pattern string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for idx := 0; idx < len(pattern); idx++ {
+		switch ch := pattern[idx]; ch {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		case '[':
+			end := strings.IndexByte(pattern[idx+1:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid pattern '%s', unterminated [", pattern)
+			}
+			class := pattern[idx+1 : idx+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr.WriteString("[" + class + "]")
+			idx += end + 1
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+// TagsMatching returns the tags that match the glob pattern (see MatchGlob),
+// newest first
+func (r *Manager) TagsMatching(pattern string) ([]string, error) {
+	glob, err := MatchGlob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	tags := []string{}
+	for _, release := range r.releases {
+		if glob.MatchString(release.Tag) {
+			tags = append(tags, release.Tag)
+		}
+	}
+	return tags, nil
+}
+
+// IsReleaseTag is true if the tag is named like a release of either scheme
+// (with the manager's tag prefix, date format and separators), of a component
+// scheme or a nightly release
+func (r *Manager) IsReleaseTag(name string) bool {
+	if strings.HasPrefix(name, NightlyPrefix+"-") || r.isSchemeTag(name) {
+		return true
+	}
+	for component := range r.ComponentSchemes {
+		if r.ForComponent(component).isSchemeTag(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Manager) isSchemeTag(name string) bool {
+	name, ok := r.trimTagPrefix(name)
+	return ok && (r.dateVersionPattern().MatchString(name) || r.semVersionPattern().MatchString(name))
+}
+
+// DeleteTag deletes a local tag
+func (r *Manager) DeleteTag(name string) error {
+	if err := r.repo.DeleteTag(name); err != nil {
+		return fmt.Errorf("failed to delete tag %s: %w", name, err)
+	}
+	return r.loadGitTags()
+}
+
+// DeleteRemoteTag deletes a tag from the remote, the local tag is left alone
+func (r *Manager) DeleteRemoteTag(name, remote string, auth transport.AuthMethod) (string, error) {
+	options := &git.PushOptions{
+		RemoteName: remote,
+		RefSpecs: []config.RefSpec{
+			config.RefSpec(fmt.Sprintf(":refs/tags/%s", name)),
+		},
+		Auth: auth,
+	}
+	err := r.repo.Push(options)
+	if err == git.NoErrAlreadyUpToDate {
+		return fmt.Sprintf("nothing deleted, remote %s doesn't have tag %s", remote, name), nil
+	} else if err != nil {
+		return fmt.Sprintf("failed to delete tag %s from remote %s", name, remote), err
+	}
+	return fmt.Sprintf("deleted tag %s from remote %s", name, remote), nil
+}
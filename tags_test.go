@@ -0,0 +1,136 @@
+package release
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestRenameTag(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string // Annotated if not empty
+		oldName  string
+		newName  string
+		existing string // Another tag in the repository
+		wantErr  string
+	}{
+		{name: "lightweight", oldName: "2024.06.001", newName: "2024.06.002"},
+		{name: "annotated", message: "Release 2024.06.001", oldName: "2024.06.001", newName: "2024.06.002"},
+		{name: "missing", oldName: "2024.06.003", newName: "2024.06.004", wantErr: "does not exist"},
+		{name: "new name taken", oldName: "2024.06.001", newName: "2024.05.001", existing: "2024.05.001", wantErr: "already exists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			if tt.existing != "" {
+				tagHead(t, repo, tt.existing, "")
+			}
+			target := commitFile(t, repo, "file.txt", "Fix the build")
+			tagHead(t, repo, "2024.06.001", tt.message)
+			// HEAD moves on, the renamed tag has to stay on its commit
+			commitFile(t, repo, "file.txt", "After the release")
+
+			rm := newTestManager(t, dir)
+			err := rm.RenameTag(tt.oldName, tt.newName)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("RenameTag() error = %v, want one containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RenameTag() error = %s", err)
+			}
+
+			if _, err := repo.Tag(tt.oldName); err != git.ErrTagNotFound {
+				t.Errorf("old tag %s still exists: %v", tt.oldName, err)
+			}
+			ref, err := repo.Tag(tt.newName)
+			if err != nil {
+				t.Fatalf("new tag %s doesn't exist: %s", tt.newName, err)
+			}
+			tag, err := repo.TagObject(ref.Hash())
+			if tt.message == "" {
+				if err != plumbing.ErrObjectNotFound {
+					t.Errorf("lightweight tag became annotated: %v", err)
+				}
+				if ref.Hash() != target {
+					t.Errorf("%s points at %s, want %s", tt.newName, ref.Hash(), target)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("annotated tag lost its tag object: %s", err)
+			}
+			if tag.Target != target {
+				t.Errorf("%s targets %s, want %s", tt.newName, tag.Target, target)
+			}
+			if tag.Name != tt.newName {
+				t.Errorf("tag object name = %s, want %s", tag.Name, tt.newName)
+			}
+			if tag.Message != tt.message+"\n" || !tag.Tagger.When.Equal(testSignature.When) || tag.Tagger.Email != testSignature.Email {
+				t.Errorf("tag object = %q by %s, want %q by %s", tag.Message, tag.Tagger, tt.message, testSignature)
+			}
+		})
+	}
+}
+
+func TestPushTagRename(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	remote := addTestRemote(t, repo, "origin")
+	tagHead(t, repo, "2024.06.001", "")
+	pushTags(t, repo, "origin")
+
+	rm := newTestManager(t, dir)
+	if err := rm.RenameTag("2024.06.001", "2024.06.002"); err != nil {
+		t.Fatalf("RenameTag() error = %s", err)
+	}
+	if _, err := rm.PushTagRename("2024.06.001", "2024.06.002", "origin", nil); err != nil {
+		t.Fatalf("PushTagRename() error = %s", err)
+	}
+	if _, err := remote.Tag("2024.06.001"); err != git.ErrTagNotFound {
+		t.Errorf("remote still has the old tag: %v", err)
+	}
+	if _, err := remote.Tag("2024.06.002"); err != nil {
+		t.Errorf("remote doesn't have the new tag: %s", err)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+		wantErr bool
+	}{
+		{pattern: "2023.*", match: []string{"2023.01.001", "2023.12.004-api", "2023."}, noMatch: []string{"2024.01.001", "x2023.01.001", "2023"}},
+		{pattern: "2024.0?.001", match: []string{"2024.06.001"}, noMatch: []string{"2024.10.001", "2024.6.001"}},
+		{pattern: "*-api", match: []string{"2024.06.001-api", "v1.0.0-1-api"}, noMatch: []string{"2024.06.001-web"}},
+		{pattern: "2024.0[1-3].*", match: []string{"2024.02.001"}, noMatch: []string{"2024.04.001"}},
+		{pattern: "2024.0[!1-3].*", match: []string{"2024.04.001"}, noMatch: []string{"2024.02.001"}},
+		{pattern: "release/*", match: []string{"release/2024/06"}},
+		{pattern: "v1.(2)+", match: []string{"v1.(2)+"}, noMatch: []string{"v1.22"}},
+		{pattern: "2024.[01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			glob, err := MatchGlob(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MatchGlob(%q) error = %v, want error %t", tt.pattern, err, tt.wantErr)
+			}
+			for _, tag := range tt.match {
+				if !glob.MatchString(tag) {
+					t.Errorf("%q doesn't match %s", tt.pattern, tag)
+				}
+			}
+			for _, tag := range tt.noMatch {
+				if glob.MatchString(tag) {
+					t.Errorf("%q matches %s", tt.pattern, tag)
+				}
+			}
+		})
+	}
+}
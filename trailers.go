@@ -0,0 +1,98 @@
+package release
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// TrailerScheme records how the release was versioned (date or semver)
+	TrailerScheme = "Release-Scheme"
+	// TrailerFormat records the format string used to build the release name
+	TrailerFormat = "Release-Format"
+	// TrailerIncrement records the increment used (the number format for date
+	// releases or the bump for semver releases)
+	TrailerIncrement = "Release-Increment"
+	// TrailerSBOM records the file name and digest of the SBOM that goes with
+	// the release
+	TrailerSBOM = "Release-SBOM"
+)
+
+var patTrailer = regexp.MustCompile(`^(?P<key>[A-Za-z0-9][A-Za-z0-9-]*):\s*(?P<value>.*)$`)
+
+// ParseTrailers parses the git trailers ("Key: value" lines in the final
+// paragraph) of a commit or tag message. Keys may be repeated so every value
+// is returned in the order it appeared.
+func ParseTrailers(message string) map[string][]string {
+	trailers := map[string][]string{}
+	paragraphs := strings.Split(strings.TrimSpace(message), "\n\n")
+	// A message that is only a single paragraph is a subject, not trailers
+	if len(paragraphs) < 2 {
+		return trailers
+	}
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		results := patTrailer.FindStringSubmatch(line)
+		if results == nil {
+			// Not a trailer block after all
+			return map[string][]string{}
+		}
+		trailers[results[1]] = append(trailers[results[1]], strings.TrimSpace(results[2]))
+	}
+	return trailers
+}
+
+// AppendTrailers adds the given trailers (in the given key order) to the end
+// of a message, separated from the body by a blank line. If the message
+// already ends in trailers they're added to the same block, git only reads the
+// final paragraph.
+func AppendTrailers(message string, keys []string, trailers map[string]string) string {
+	lines := []string{}
+	for _, key := range keys {
+		if value, ok := trailers[key]; ok {
+			lines = append(lines, fmt.Sprintf("%s: %s", key, value))
+		}
+	}
+	if len(lines) == 0 {
+		return message
+	}
+	separator := "\n\n"
+	if len(ParseTrailers(message)) > 0 {
+		separator = "\n"
+	}
+	return fmt.Sprintf("%s%s%s", strings.TrimSpace(message), separator, strings.Join(lines, "\n"))
+}
+
+// SchemeInfo describes how a release was produced so historical tags can be
+// understood even if the configuration has changed since
+type SchemeInfo struct {
+	Scheme    string // date or semver
+	Format    string // The format string used for the version
+	Increment string // The increment format (date) or bump (semver) used
+}
+
+// AppendTo adds the scheme trailers to an annotated tag message
+func (s SchemeInfo) AppendTo(message string) string {
+	return AppendTrailers(message, []string{TrailerScheme, TrailerFormat, TrailerIncrement}, map[string]string{
+		TrailerScheme:    s.Scheme,
+		TrailerFormat:    s.Format,
+		TrailerIncrement: s.Increment,
+	})
+}
+
+// ParseSchemeInfo reads the scheme trailers back out of a tag message, fields
+// that weren't recorded are left empty
+func ParseSchemeInfo(message string) SchemeInfo {
+	trailers := ParseTrailers(message)
+	first := func(key string) string {
+		if values := trailers[key]; len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	return SchemeInfo{
+		Scheme:    first(TrailerScheme),
+		Format:    first(TrailerFormat),
+		Increment: first(TrailerIncrement),
+	}
+}
@@ -0,0 +1,80 @@
+package release
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTrailers(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    map[string][]string
+	}{
+		{name: "subject only", message: "Key: value", want: map[string][]string{}},
+		{name: "trailers", message: "Subject\n\nBody\n\nSigned-off-by: A\nRelease-Note: fixed it\nSigned-off-by: B\n", want: map[string][]string{"Signed-off-by": {"A", "B"}, "Release-Note": {"fixed it"}}},
+		{name: "last paragraph isn't trailers", message: "Subject\n\nKey: value\nnot a trailer", want: map[string][]string{}},
+		{name: "only the last paragraph", message: "Subject\n\nKey: value\n\nBody", want: map[string][]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseTrailers(tt.message); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTrailers() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemeInfoRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		info    SchemeInfo
+	}{
+		{name: "date", message: "Release 2024.06.001", info: SchemeInfo{Scheme: "date", Format: "%Y.%m.", Increment: "%03d"}},
+		{name: "semver", message: "- feat: one\n- fix: two", info: SchemeInfo{Scheme: "semver", Format: "<major>.<minor>.<patch>-<release>", Increment: "minor"}},
+		{name: "existing trailers", message: "Release 2024.06.001\n\nRelease-SBOM: sbom.json sha256:abc", info: SchemeInfo{Scheme: "date", Format: "%Y.%m.", Increment: "%d"}},
+		{name: "partial", message: "Release", info: SchemeInfo{Scheme: "date"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := tt.info.AppendTo(tt.message)
+			if got := ParseSchemeInfo(message); got != tt.info {
+				t.Errorf("ParseSchemeInfo(%q) = %+v, want %+v", message, got, tt.info)
+			}
+
+			// And through an annotated tag
+			dir, _ := newTestRepo(t)
+			rm := newTestManager(t, dir)
+			if _, err := rm.CreateTag("2024.06.001", message, "B", "b@example.com"); err != nil {
+				t.Fatal(err)
+			}
+			rel, err := newTestManager(t, dir).GetRelease("2024.06.001")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ParseSchemeInfo(rel.Message()); got != tt.info {
+				t.Errorf("scheme of the tag = %+v, want %+v", got, tt.info)
+			}
+		})
+	}
+}
+
+func TestAppendTrailers(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{name: "new block", message: "Release\n", want: "Release\n\nRelease-Scheme: date"},
+		{name: "existing block", message: "Release\n\nSigned-off-by: A", want: "Release\n\nSigned-off-by: A\nRelease-Scheme: date"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AppendTrailers(tt.message, []string{TrailerScheme, TrailerFormat}, map[string]string{TrailerScheme: "date"})
+			if got != tt.want {
+				t.Errorf("AppendTrailers() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package release
+
+import (
+	"net/http"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// defaultTransports are go-git's own transports, restored by UseTransport
+var defaultTransports = func() map[string]transport.Transport {
+	transports := map[string]transport.Transport{}
+	for scheme, t := range client.Protocols {
+		transports[scheme] = t
+	}
+	return transports
+}()
+
+// UseTransport makes every push, fetch and remote listing use t for remotes
+// with the given URL scheme (http, https, ssh, git or file, or a scheme of
+// its own), for example an in-memory transport in tests. go-git looks
+// transports up in a process wide registry and can't be given one per push,
+// so this affects every Manager and has to be called before any of them talk
+// to a remote. A nil transport restores go-git's default for the scheme, or
+// removes a scheme go-git doesn't have.
+func UseTransport(scheme string, t transport.Transport) {
+	if t == nil {
+		t = defaultTransports[scheme]
+	}
+	client.InstallProtocol(scheme, t)
+}
+
+// UseHTTPClient makes http and https remotes use the given client, e.g. one
+// with a custom proxy or TLS configuration, like UseTransport. The default
+// client already honors HTTPS_PROXY and friends through http.DefaultTransport,
+// nil restores it.
+func UseHTTPClient(c *http.Client) {
+	if c == nil {
+		UseTransport("http", nil)
+		UseTransport("https", nil)
+		return
+	}
+	t := githttp.NewClient(c)
+	UseTransport("http", t)
+	UseTransport("https", t)
+}
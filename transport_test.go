@@ -0,0 +1,222 @@
+package release
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/plumbing/transport/file"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// recordingTransport is the file transport, recording the reference updates
+// of every push and whether it carried a packfile
+type recordingTransport struct {
+	commands []*packp.Command
+	packed   bool
+}
+
+func (r *recordingTransport) fileEndpoint(ep *transport.Endpoint) *transport.Endpoint {
+	file := *ep
+	file.Protocol = "file"
+	return &file
+}
+
+func (r *recordingTransport) NewUploadPackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.UploadPackSession, error) {
+	return file.DefaultClient.NewUploadPackSession(r.fileEndpoint(ep), auth)
+}
+
+func (r *recordingTransport) NewReceivePackSession(ep *transport.Endpoint, auth transport.AuthMethod) (transport.ReceivePackSession, error) {
+	session, err := file.DefaultClient.NewReceivePackSession(r.fileEndpoint(ep), auth)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingSession{ReceivePackSession: session, transport: r}, nil
+}
+
+type recordingSession struct {
+	transport.ReceivePackSession
+	transport *recordingTransport
+}
+
+func (s *recordingSession) ReceivePack(ctx context.Context, req *packp.ReferenceUpdateRequest) (*packp.ReportStatus, error) {
+	s.transport.commands = append(s.transport.commands, req.Commands...)
+	if req.Packfile != nil {
+		data, err := io.ReadAll(req.Packfile)
+		if err != nil {
+			return nil, err
+		}
+		s.transport.packed = len(data) > 0
+		req.Packfile = io.NopCloser(strings.NewReader(string(data)))
+	}
+	return s.ReceivePackSession.ReceivePack(ctx, req)
+}
+
+func TestUseTransport(t *testing.T) {
+	recording := &recordingTransport{}
+	UseTransport("recording", recording)
+	t.Cleanup(func() { UseTransport("recording", nil) })
+
+	tests := []struct {
+		name         string
+		annotated    bool
+		pushedBefore bool
+		wantPack     bool
+	}{
+		{name: "lightweight", wantPack: true},
+		{name: "annotated", annotated: true, wantPack: true},
+		{name: "already pushed", pushedBefore: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			remote := addTestRemote(t, repo, "file")
+			if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"recording://" + remoteRoot(t, repo, "file")}}); err != nil {
+				t.Fatal(err)
+			}
+			message := ""
+			if tt.annotated {
+				message = "Release 2024.06.001"
+			}
+			tagHead(t, repo, "2024.06.001", message)
+			ref, err := repo.Tag("2024.06.001")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.pushedBefore {
+				pushTags(t, repo, "file")
+			}
+			recording.commands, recording.packed = nil, false
+
+			rm := newTestManager(t, dir)
+			if _, err := rm.PushTagToRemote("2024.06.001", "origin", nil); err != nil {
+				t.Fatalf("PushTagToRemote() error = %s", err)
+			}
+			if tt.pushedBefore {
+				if len(recording.commands) != 0 {
+					t.Errorf("pushed %v, want nothing for a tag the remote has", recording.commands)
+				}
+				return
+			}
+			if len(recording.commands) != 1 {
+				t.Fatalf("pushed %d reference updates, want 1", len(recording.commands))
+			}
+			command := recording.commands[0]
+			if command.Name != plumbing.NewTagReferenceName("2024.06.001") || !command.Old.IsZero() || command.New != ref.Hash() {
+				t.Errorf("pushed %s %s..%s, want refs/tags/2024.06.001 creating %s", command.Name, command.Old, command.New, ref.Hash())
+			}
+			if recording.packed != tt.wantPack {
+				t.Errorf("packfile sent = %t, want %t", recording.packed, tt.wantPack)
+			}
+			if _, err := remote.Tag("2024.06.001"); err != nil {
+				t.Errorf("remote doesn't have the tag: %s", err)
+			}
+		})
+	}
+}
+
+// remoteRoot returns the path the named remote of repo points at
+func remoteRoot(t *testing.T, repo *git.Repository, name string) string {
+	t.Helper()
+	remote, err := repo.Remote(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return remote.Config().URLs[0]
+}
+
+// recordingRoundTripper records the requests and fails them
+type recordingRoundTripper struct {
+	requests []*http.Request
+}
+
+var errRecorded = errors.New("request recorded")
+
+func (r *recordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.requests = append(r.requests, req)
+	return nil, errRecorded
+}
+
+func TestUseHTTPClient(t *testing.T) {
+	recording := &recordingRoundTripper{}
+	UseHTTPClient(&http.Client{Transport: recording})
+	t.Cleanup(func() { UseHTTPClient(nil) })
+
+	for _, scheme := range []string{"http", "https"} {
+		t.Run(scheme, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			url := scheme + "://git.example.invalid/release.git"
+			if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{url}}); err != nil {
+				t.Fatal(err)
+			}
+			tagHead(t, repo, "2024.06.001", "")
+			recording.requests = nil
+
+			rm := newTestManager(t, dir)
+			if _, err := rm.PushTagToRemote("2024.06.001", "origin", nil); err == nil || !strings.Contains(err.Error(), errRecorded.Error()) {
+				t.Errorf("PushTagToRemote() error = %v, want the client's", err)
+			}
+			if len(recording.requests) == 0 {
+				t.Fatal("the push didn't go through the client")
+			}
+			// The remote's tags are listed before pushing
+			if got, want := recording.requests[0].URL.String(), url+"/info/refs?service=git-upload-pack"; got != want {
+				t.Errorf("first request to %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestUseTransportRestoresDefault(t *testing.T) {
+	recording := &recordingTransport{}
+	tests := []struct {
+		name    string
+		scheme  string
+		restore func()
+		want    transport.Transport // nil if the scheme is removed
+	}{
+		{name: "built in scheme", scheme: "file", restore: func() { UseTransport("file", nil) }, want: file.DefaultClient},
+		{name: "custom scheme", scheme: "recording", restore: func() { UseTransport("recording", nil) }},
+		{name: "http client", scheme: "https", restore: func() { UseHTTPClient(nil) }, want: githttp.DefaultClient},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.scheme == "https" {
+				UseHTTPClient(&http.Client{})
+			} else {
+				UseTransport(tt.scheme, recording)
+			}
+			tt.restore()
+			got, ok := client.Protocols[tt.scheme]
+			if tt.want == nil {
+				if ok {
+					t.Errorf("scheme %s is still installed", tt.scheme)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("scheme %s uses %T after restoring, want go-git's default", tt.scheme, got)
+			}
+		})
+	}
+
+	// Pushes work again with the restored transport
+	dir, repo := newTestRepo(t)
+	remote := addTestRemote(t, repo, "origin")
+	tagHead(t, repo, "2024.06.001", "")
+	if _, err := newTestManager(t, dir).PushTagToRemote("2024.06.001", "origin", nil); err != nil {
+		t.Fatalf("PushTagToRemote() error = %s", err)
+	}
+	if _, err := remote.Tag("2024.06.001"); err != nil {
+		t.Errorf("remote doesn't have the tag: %s", err)
+	}
+}
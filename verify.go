@@ -0,0 +1,125 @@
+package release
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+const (
+	// SignatureValid means the tag is signed by a key in the keyring
+	SignatureValid = "valid"
+	// SignatureMissing means the tag isn't signed (including lightweight tags)
+	SignatureMissing = "unsigned"
+	// SignatureInvalid means the tag is signed but the signature doesn't
+	// check out, it was tampered with or signed by an unknown key
+	SignatureInvalid = "invalid"
+)
+
+// TagVerification is the result of verifying the signature of a tag
+type TagVerification struct {
+	Tag    string `json:"tag"`
+	Status string `json:"status"`           // One of SignatureValid, SignatureMissing or SignatureInvalid
+	Signer string `json:"signer,omitempty"` // The key ID of the signer for valid (and parseable invalid) signatures
+	Detail string `json:"detail,omitempty"` // Why an invalid signature failed, or the signer's identity if valid
+}
+
+// LoadKeyRing reads a file of armored public keys (gpg --export --armor) to
+// verify tags against, making sure it parses so a bad keyring isn't reported
+// as every tag having an invalid signature
+func LoadKeyRing(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := openpgp.ReadArmoredKeyRing(strings.NewReader(string(data))); err != nil {
+		return "", fmt.Errorf("failed to parse keyring %s: %w", path, err)
+	}
+	return string(data), nil
+}
+
+// VerifyTag checks the signature of a tag against the armored public keys in
+// keyRing
+func (r *Manager) VerifyTag(name, keyRing string) (TagVerification, error) {
+	result := TagVerification{Tag: name, Status: SignatureMissing}
+	ref, err := r.repo.Tag(name)
+	if err != nil {
+		return result, fmt.Errorf("tag %s does not exist: %w", name, err)
+	}
+	tag, err := r.repo.TagObject(ref.Hash())
+	if err == plumbing.ErrObjectNotFound {
+		// Lightweight, there is nothing to sign
+		return result, nil
+	} else if err != nil {
+		return result, err
+	}
+	if tag.PGPSignature == "" {
+		return result, nil
+	}
+	result.Signer = (&Release{PGPSignature: tag.PGPSignature}).SignerKeyID()
+	if tag.Name != name {
+		// The signature covers the name inside the tag object, a signed tag
+		// copied to another ref still verifies but isn't what was signed
+		result.Status = SignatureInvalid
+		result.Detail = fmt.Sprintf("the signed tag object is named %s", tag.Name)
+		return result, nil
+	}
+	entity, err := tag.Verify(keyRing)
+	if err != nil {
+		result.Status = SignatureInvalid
+		result.Detail = err.Error()
+		return result, nil
+	}
+	result.Status = SignatureValid
+	// Keys can have several identities, always show the same one
+	if identity := entity.PrimaryIdentity(); identity != nil {
+		result.Detail = identity.Name
+	}
+	return result, nil
+}
+
+// ErrTagNotSigned is returned when pushing a tag without a valid signature
+// while RequireSigned is set
+var ErrTagNotSigned = errors.New("tag is not validly signed")
+
+// checkSigned returns ErrTagNotSigned unless the tag verifies against the
+// manager's SignedKeyRing, or the public half of SignKey without one
+func (r *Manager) checkSigned(tag string) (string, error) {
+	keyRing := r.SignedKeyRing
+	if keyRing == "" && r.SignKey != nil {
+		var buf bytes.Buffer
+		w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+		if err != nil {
+			return fmt.Sprintf("failed to export the signing key to verify tag %s", tag), err
+		}
+		if err := r.SignKey.Serialize(w); err != nil {
+			return fmt.Sprintf("failed to export the signing key to verify tag %s", tag), err
+		}
+		// Closing writes the checksum and footer of the armored key
+		if err := w.Close(); err != nil {
+			return fmt.Sprintf("failed to export the signing key to verify tag %s", tag), err
+		}
+		keyRing = buf.String()
+	}
+	if keyRing == "" {
+		return fmt.Sprintf("refusing to push tag %s, signed tags are required but there are no keys to verify it with", tag), ErrTagNotSigned
+	}
+	result, err := r.VerifyTag(tag, keyRing)
+	if err != nil {
+		return fmt.Sprintf("failed to verify tag %s", tag), err
+	}
+	if result.Status != SignatureValid {
+		msg := fmt.Sprintf("refusing to push tag %s, signed tags are required and it is %s", tag, result.Status)
+		if result.Detail != "" {
+			msg += ": " + result.Detail
+		}
+		return msg, ErrTagNotSigned
+	}
+	return "", nil
+}
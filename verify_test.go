@@ -0,0 +1,240 @@
+package release
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// tamperTag rewrites the message of an annotated tag, keeping its signature
+func tamperTag(t *testing.T, repo *git.Repository, name string) {
+	t.Helper()
+	ref, err := repo.Tag(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag, err := repo.TagObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tag.Message = "Not what was signed\n"
+	obj := repo.Storer.NewEncodedObject()
+	if err := tag.Encode(obj); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(ref.Name(), hash)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyTag(t *testing.T) {
+	dir, repo := newTestRepo(t)
+	key, err := LoadSigningKey(writeTestSigningKey(t, ""), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := LoadSigningKey(writeTestSigningKey(t, ""), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rm := newTestManager(t, dir)
+	rm.SignKey = key
+	for _, name := range []string{"2024.06.001", "2024.06.002"} {
+		if _, err := rm.CreateTag(name, "Release "+name, "Test", "test@example.com"); err != nil {
+			t.Fatalf("CreateTag() error = %s", err)
+		}
+	}
+	tamperTag(t, repo, "2024.06.002")
+	rm.SignKey = other
+	if _, err := rm.CreateTag("2024.06.003", "Release 2024.06.003", "Test", "test@example.com"); err != nil {
+		t.Fatalf("CreateTag() error = %s", err)
+	}
+	tagHead(t, repo, "2024.06.004", "Release 2024.06.004")
+	tagHead(t, repo, "2024.06.005", "")
+	// A copy of a signed tag under another name
+	signed, err := repo.Tag("2024.06.001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(plumbing.NewTagReferenceName("2024.06.006"), signed.Hash())); err != nil {
+		t.Fatal(err)
+	}
+
+	keyRing := armoredPublicKey(t, key)
+	tests := []struct {
+		tag        string
+		wantStatus string
+		wantSigner bool
+		wantDetail string
+	}{
+		{tag: "2024.06.001", wantStatus: SignatureValid, wantSigner: true, wantDetail: "Test <test@example.com>"},
+		{tag: "2024.06.002", wantStatus: SignatureInvalid, wantSigner: true, wantDetail: "signature"},
+		{tag: "2024.06.003", wantStatus: SignatureInvalid, wantSigner: true},
+		{tag: "2024.06.004", wantStatus: SignatureMissing},
+		{tag: "2024.06.005", wantStatus: SignatureMissing},
+		{tag: "2024.06.006", wantStatus: SignatureInvalid, wantSigner: true, wantDetail: "the signed tag object is named 2024.06.001"},
+	}
+	rm = newTestManager(t, dir)
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			got, err := rm.VerifyTag(tt.tag, keyRing)
+			if err != nil {
+				t.Fatalf("VerifyTag() error = %s", err)
+			}
+			if got.Tag != tt.tag || got.Status != tt.wantStatus {
+				t.Errorf("VerifyTag() = %+v, want status %s", got, tt.wantStatus)
+			}
+			if (got.Signer != "") != tt.wantSigner {
+				t.Errorf("signer = %q, want one %t", got.Signer, tt.wantSigner)
+			}
+			if !strings.Contains(got.Detail, tt.wantDetail) {
+				t.Errorf("detail = %q, want it to contain %q", got.Detail, tt.wantDetail)
+			}
+		})
+	}
+
+	if _, err := rm.VerifyTag("2024.06.007", keyRing); err == nil {
+		t.Error("VerifyTag() of a missing tag succeeded")
+	}
+}
+
+func TestLoadKeyRing(t *testing.T) {
+	key, err := LoadSigningKey(writeTestSigningKey(t, ""), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.asc")
+	if err := os.WriteFile(good, []byte(armoredPublicKey(t, key)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	bad := filepath.Join(dir, "bad.asc")
+	if err := os.WriteFile(bad, []byte("not a key"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeyRing(good); err != nil {
+		t.Errorf("LoadKeyRing() error = %s", err)
+	}
+	if _, err := LoadKeyRing(bad); err == nil || !strings.Contains(err.Error(), "failed to parse keyring") {
+		t.Errorf("LoadKeyRing() of a bad keyring error = %v", err)
+	}
+	if _, err := LoadKeyRing(filepath.Join(dir, "missing.asc")); err == nil {
+		t.Error("LoadKeyRing() of a missing file succeeded")
+	}
+}
+
+func TestVerifyTagPrimaryIdentity(t *testing.T) {
+	dir, _ := newTestRepo(t)
+	key, err := openpgp.NewEntity("Release Bot", "", "release@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"bot@example.com", "ci@example.com", "builds@example.com"} {
+		if err := key.AddUserId("Release Bot", "", email, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rm := newTestManager(t, dir)
+	rm.SignKey = key
+	if _, err := rm.CreateTag("2024.06.001", "Release 2024.06.001", "Test", "test@example.com"); err != nil {
+		t.Fatalf("CreateTag() error = %s", err)
+	}
+	keyRing := armoredPublicKey(t, key)
+	// Identities are a map, a random one would show up now and then
+	for i := 0; i < 20; i++ {
+		got, err := rm.VerifyTag("2024.06.001", keyRing)
+		if err != nil {
+			t.Fatalf("VerifyTag() error = %s", err)
+		}
+		if want := "Release Bot <release@example.com>"; got.Status != SignatureValid || got.Detail != want {
+			t.Fatalf("VerifyTag() = %+v, want a valid signature by the primary identity %s", got, want)
+		}
+	}
+}
+
+func TestRequireSigned(t *testing.T) {
+	key, err := LoadSigningKey(writeTestSigningKey(t, ""), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := LoadSigningKey(writeTestSigningKey(t, ""), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		signedBy   *openpgp.Entity // Signs the release, unsigned if nil
+		annotated  bool
+		tamper     bool
+		signKey    *openpgp.Entity // SignKey when pushing
+		keyRing    *openpgp.Entity // SignedKeyRing when pushing
+		notEnforce bool
+		wantPushed bool
+		wantMsg    string
+	}{
+		{name: "signed", signedBy: key, keyRing: key, wantPushed: true},
+		{name: "signed, verified with the signing key", signedBy: key, signKey: key, wantPushed: true},
+		{name: "keyring over the signing key", signedBy: key, signKey: other, keyRing: key, wantPushed: true},
+		{name: "lightweight", keyRing: key, wantMsg: "it is unsigned"},
+		{name: "annotated", annotated: true, keyRing: key, wantMsg: "it is unsigned"},
+		{name: "other key", signedBy: other, keyRing: key, wantMsg: "it is invalid"},
+		{name: "tampered", signedBy: key, tamper: true, keyRing: key, wantMsg: "it is invalid"},
+		{name: "no keys", signedBy: key, wantMsg: "there are no keys to verify it with"},
+		{name: "not required", notEnforce: true, wantPushed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, repo := newTestRepo(t)
+			remote := addTestRemote(t, repo, "origin")
+			rm := newTestManager(t, dir)
+			message := ""
+			if tt.annotated || tt.signedBy != nil {
+				message = "Release 2024.06.001"
+			}
+			rm.SignKey = tt.signedBy
+			if _, err := rm.CreateTag("2024.06.001", message, "Test", "test@example.com"); err != nil {
+				t.Fatalf("CreateTag() error = %s", err)
+			}
+			if tt.tamper {
+				tamperTag(t, repo, "2024.06.001")
+			}
+
+			rm = newTestManager(t, dir)
+			rm.RequireSigned = !tt.notEnforce
+			rm.SignKey = tt.signKey
+			if tt.keyRing != nil {
+				rm.SignedKeyRing = armoredPublicKey(t, tt.keyRing)
+			}
+			msg, err := rm.PushTagToRemote("2024.06.001", "origin", nil)
+			results := rm.PushTagsToRemote([]string{"2024.06.001"}, "origin", nil, 1)
+			if tt.wantPushed {
+				if err != nil || results[0].Err != nil {
+					t.Fatalf("push error = %v and %v, want the tag pushed", err, results[0].Err)
+				}
+				if _, err := remote.Tag("2024.06.001"); err != nil {
+					t.Errorf("the remote doesn't have the tag: %s", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrTagNotSigned) || !errors.Is(results[0].Err, ErrTagNotSigned) {
+				t.Fatalf("push error = %v and %v, want ErrTagNotSigned", err, results[0].Err)
+			}
+			if !strings.Contains(msg, tt.wantMsg) || !strings.Contains(results[0].Message, tt.wantMsg) {
+				t.Errorf("messages %q and %q, want them to contain %q", msg, results[0].Message, tt.wantMsg)
+			}
+			if _, err := remote.Tag("2024.06.001"); err == nil {
+				t.Error("the tag was pushed anyway")
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package release
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// DirtyFiles returns the modified, staged and untracked files of the working
+// tree in git status --short form (" M main.go", "?? notes.txt"), sorted by
+// path. Ignored files aren't included.
+func (r *Manager) DirtyFiles() ([]string, error) {
+	w, err := r.repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repo work tree: %w", err)
+	}
+	status, err := w.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the working tree status: %w", err)
+	}
+	paths := []string{}
+	for path, fileStatus := range status {
+		if fileStatus.Staging == git.Unmodified && fileStatus.Worktree == git.Unmodified {
+			continue
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	files := []string{}
+	for _, path := range paths {
+		fileStatus := status[path]
+		files = append(files, fmt.Sprintf("%c%c %s", fileStatus.Staging, fileStatus.Worktree, path))
+	}
+	return files, nil
+}
+
+// IsClean is true if the working tree has no uncommitted changes or
+// untracked files, a release of a dirty tree doesn't contain what's checked
+// out
+func (r *Manager) IsClean() (bool, error) {
+	dirty, err := r.DirtyFiles()
+	return len(dirty) == 0, err
+}